@@ -0,0 +1,213 @@
+package access
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/websub"
+)
+
+// PUBLIC_PATHS are left open for monitoring and for the browsers rendering the feeds
+var PUBLIC_PATHS = []string{"/api/healthcheck", "/metrics", "/rss-style.xsl", "/atom-style.xsl"}
+
+const AUTHENTICATE_HEADER = `Bearer realm="rss-banquet"`
+
+type moduleScope struct {
+	module string
+	values url.Values
+}
+
+type token struct {
+	name    string
+	secret  string
+	feeds   bool
+	api     bool
+	modules []moduleScope
+}
+
+// Controller restricts the access to the feeds and API endpoints to the
+// tokens of the access config
+type Controller struct {
+	config  *config.AccessConfig
+	matcher *parser.RouteMatcher
+	tokens  []*token
+}
+
+func parseScope(t *token, scope string) error {
+	switch scope {
+	case config.ACCESS_SCOPE_FEEDS:
+		t.feeds = true
+	case config.ACCESS_SCOPE_API:
+		t.api = true
+	default:
+		module, query, _ := strings.Cut(strings.TrimPrefix(scope, config.ACCESS_SCOPE_MODULE_PREFIX), "?")
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return fmt.Errorf("invalid scope %s of token %s: %w", scope, t.name, err)
+		}
+		t.modules = append(t.modules, moduleScope{module: module, values: values})
+	}
+	return nil
+}
+
+func New(c *config.AccessConfig, matcher *parser.RouteMatcher) (*Controller, error) {
+	ctl := &Controller{config: c, matcher: matcher}
+	for _, tc := range c.Tokens {
+		t := &token{name: tc.Name, secret: tc.Token}
+		for _, scope := range tc.Scopes {
+			if err := parseScope(t, scope); err != nil {
+				return nil, err
+			}
+		}
+		ctl.tokens = append(ctl.tokens, t)
+	}
+	return ctl, nil
+}
+
+func (ctl *Controller) getToken(secret string) *token {
+	if secret == "" {
+		return nil
+	}
+	var found *token
+	// every token is compared, in constant time
+	for _, t := range ctl.tokens {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(t.secret)) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+func (ctl *Controller) isPublicModule(module string) bool {
+	access, ok := ctl.config.Modules[module]
+	if !ok {
+		access = ctl.config.Default
+	}
+	return access != config.ACCESS_TOKEN
+}
+
+func getOptionValues(match *parser.RouteMatch) map[string]string {
+	values := match.Values()
+	for _, option := range match.Options {
+		if _, ok := values[option.Flag]; !ok {
+			values[option.Flag] = option.Default
+		}
+	}
+	return values
+}
+
+func (t *token) allowsFeed(match *parser.RouteMatch) bool {
+	if t.feeds {
+		return true
+	}
+	values := getOptionValues(match)
+	for _, scope := range t.modules {
+		if scope.module != match.Module {
+			continue
+		}
+		allowed := true
+		for flag := range scope.values {
+			if values[flag] != scope.values.Get(flag) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func deny(c *gin.Context, t *token) {
+	if t == nil {
+		c.Header("WWW-Authenticate", AUTHENTICATE_HEADER)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"status": "error", "error": "missing or invalid token"})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"status": "error", "error": fmt.Sprintf("token %s can't access this resource", t.name)})
+}
+
+func allow(c *gin.Context, t *token) {
+	if t != nil {
+		c.Set(parser.TOKEN_NAME_KEY, t.name)
+		c.Request = c.Request.WithContext(parser.WithLogFields(c.Request.Context(), map[string]string{"token": t.name}))
+	}
+	c.Next()
+}
+
+// getFeedUrl returns the feed requested: the one served or streamed, or the
+// topic of a WebSub subscription
+func getFeedUrl(c *gin.Context) (string, bool) {
+	if c.Request.URL.Path == websub.HUB_PATH {
+		return c.PostForm("hub.topic"), true
+	}
+	if strings.Contains(c.Request.URL.Path, "/feed/") {
+		return c.Request.URL.RequestURI(), true
+	}
+	return "", false
+}
+
+func (ctl *Controller) checkFeed(c *gin.Context, feedUrl string) {
+	match, err := ctl.matcher.Match(feedUrl)
+	if err != nil {
+		// unknown feeds are left to the routes
+		c.Next()
+		return
+	}
+	if ctl.isPublicModule(match.Module) {
+		c.Next()
+		return
+	}
+	secret := parser.GetRequestToken(c)
+	if secret == "" {
+		if u, err := url.Parse(feedUrl); err == nil {
+			secret = u.Query().Get(parser.TOKEN_PARAMETER)
+		}
+	}
+	t := ctl.getToken(secret)
+	if t == nil || !t.allowsFeed(match) {
+		deny(c, t)
+		return
+	}
+	allow(c, t)
+}
+
+// Middleware checks the token of the requests to token-only modules, and to
+// the API endpoints when they are restricted
+func (ctl *Controller) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, p := range PUBLIC_PATHS {
+			if path == p {
+				c.Next()
+				return
+			}
+		}
+		// protected by its own token
+		if strings.HasPrefix(path, "/api/debug/") {
+			c.Next()
+			return
+		}
+		if feedUrl, ok := getFeedUrl(c); ok {
+			ctl.checkFeed(c, feedUrl)
+			return
+		}
+		if ctl.config.Api != config.ACCESS_TOKEN {
+			c.Next()
+			return
+		}
+		t := ctl.getToken(parser.GetRequestToken(c))
+		if t == nil || !t.api {
+			deny(c, t)
+			return
+		}
+		allow(c, t)
+	}
+}
@@ -0,0 +1,118 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	name string
+}
+
+func (p testParser) Parse(*parser.Options) (*feeds.Feed, error) {
+	return &feeds.Feed{Title: p.name}, nil
+}
+func (p testParser) String() string { return p.name }
+func (p testParser) GetOptions() parser.Options {
+	return parser.Options{
+		OptionsList: parser.OptionsList{
+			{Flag: "id", Type: "string", Required: true},
+			{Flag: "lang", Type: "string", Default: "en"},
+		},
+		Parser: p,
+	}
+}
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	modules := map[string]parser.Parser{"open": testParser{"open"}, "closed": testParser{"closed"}}
+	ctl, err := New(&config.AccessConfig{
+		Default: config.ACCESS_TOKEN,
+		Modules: map[string]string{"open": config.ACCESS_PUBLIC},
+		Api:     config.ACCESS_TOKEN,
+		Tokens: []config.TokenConfig{
+			{Name: "all", Token: "all-secret", Scopes: []string{"feeds", "api"}},
+			{Name: "french", Token: "fr-secret", Scopes: []string{"module:closed?lang=fr"}},
+		},
+	}, parser.NewRouteMatcher(modules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.Use(ctl.Middleware())
+	for _, p := range modules {
+		o := parser.GetFullOptions(p)
+		r.GET(parser.RoutePath(o), func(c *gin.Context) { c.String(200, "feed") })
+	}
+	r.GET("/api/modules/list", func(c *gin.Context) { c.String(200, "modules") })
+	r.GET("/api/healthcheck", func(c *gin.Context) { c.String(200, "ok") })
+	r.POST("/websub/hub", func(c *gin.Context) { c.String(202, c.PostForm("hub.topic")) })
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newTestRouter(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+	}{
+		{"public module", "/feed/open/1", "", 200},
+		{"token-only module without token", "/feed/closed/1", "", 401},
+		{"token-only module with invalid token", "/feed/closed/1?token=wrong", "", 401},
+		{"feeds scope", "/feed/closed/1?token=all-secret", "", 200},
+		{"bearer token", "/feed/closed/1", "Bearer all-secret", 200},
+		{"option scope", "/feed/closed/1?lang=fr&token=fr-secret", "", 200},
+		{"option scope with other value", "/feed/closed/1?token=fr-secret", "", 403},
+		{"api without token", "/api/modules/list", "", 401},
+		{"api without api scope", "/api/modules/list?token=fr-secret", "", 403},
+		{"api scope", "/api/modules/list?token=all-secret", "", 200},
+		{"public path", "/api/healthcheck", "", 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestWebSubTopic(t *testing.T) {
+	r := newTestRouter(t)
+	for topic, status := range map[string]int{
+		"https://example.com/feed/open/1":                           202,
+		"https://example.com/feed/closed/1":                         401,
+		"https://example.com/feed/closed/1?token=fr-secret":         403,
+		"https://example.com/feed/closed/1?lang=fr&token=fr-secret": 202,
+	} {
+		form := url.Values{"hub.topic": {topic}}
+		req := httptest.NewRequest(http.MethodPost, "/websub/hub", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != status {
+			t.Errorf("subscription to %s: status %d, want %d", topic, w.Code, status)
+		}
+		if status == 202 && w.Body.String() != topic {
+			t.Errorf("the topic should be left to the hub, got %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const DEFAULT_CONCURRENCY = 4
+const DEFAULT_TIMEOUT = 60 * time.Second
+
+// MAX_FUTURE_DRIFT is how far in the future item dates are tolerated, for timezone mishandlings
+const MAX_FUTURE_DRIFT = 24 * time.Hour
+
+const (
+	STATUS_PASS    = "pass"
+	STATUS_FAIL    = "fail"
+	STATUS_ERROR   = "error"
+	STATUS_SKIPPED = "skipped"
+)
+
+// Target is a feed to check, either a module with its sample options or a feed URL
+type Target struct {
+	Name   string
+	Module string
+	Parse  func(ctx context.Context) (*feeds.Feed, *parser.Options, error)
+	// SkipReason skips the check of the target when set
+	SkipReason string
+}
+
+type Result struct {
+	Name       string   `json:"name"`
+	Module     string   `json:"module"`
+	Status     string   `json:"status"`
+	DurationMs int64    `json:"durationMs"`
+	Items      int      `json:"items"`
+	Error      string   `json:"error,omitempty"`
+	ErrorClass string   `json:"errorClass,omitempty"`
+	Failures   []string `json:"failures,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+
+	duration time.Duration
+}
+
+// ModuleTarget checks the module with its sample options, or its defaults
+func ModuleTarget(name string, p parser.Parser) Target {
+	t := Target{Name: name, Module: p.String()}
+	o, ok := parser.GetSampleOptions(p)
+	if !ok {
+		t.SkipReason = "no sample options"
+		return t
+	}
+	t.Parse = func(ctx context.Context) (*feeds.Feed, *parser.Options, error) {
+		o.SetContext(ctx)
+		feed, err := parser.ParseFeed(p, o, nil)
+		return feed, o, err
+	}
+	return t
+}
+
+// UrlTarget checks the feed served on the url
+func UrlTarget(matcher *parser.RouteMatcher, url string) Target {
+	t := Target{Name: url}
+	match, err := matcher.Match(url)
+	if err != nil {
+		t.Parse = func(context.Context) (*feeds.Feed, *parser.Options, error) {
+			return nil, nil, err
+		}
+		return t
+	}
+	t.Module = match.Parser.String()
+	t.Parse = match.ParseWithContext
+	return t
+}
+
+// CheckFeed applies the sanity rules to the feed, returning the failures and warnings found
+func CheckFeed(f *feeds.Feed, o *parser.Options) ([]string, []string) {
+	var failures, warnings []string
+	if f.Title == "" {
+		failures = append(failures, "feed has no title")
+	}
+	if f.Link == nil || f.Link.Href == "" {
+		warnings = append(warnings, "feed has no link")
+	}
+	if len(f.Items) == 0 {
+		failures = append(failures, "feed has no items")
+	}
+
+	var untitled, unlinked, undated, future int
+	guids := make(map[string]bool, len(f.Items))
+	duplicates := 0
+	for _, item := range f.Items {
+		if item.Title == "" {
+			untitled++
+		}
+		if item.Link == nil || item.Link.Href == "" {
+			unlinked++
+		}
+		date := item.Created
+		if date.IsZero() {
+			date = item.Updated
+		}
+		if date.IsZero() {
+			undated++
+		} else if date.After(time.Now().Add(MAX_FUTURE_DRIFT)) {
+			future++
+		}
+		guid := parser.GetItemGuid(item)
+		if guids[guid] {
+			duplicates++
+		}
+		guids[guid] = true
+	}
+	if untitled > 0 {
+		failures = append(failures, fmt.Sprintf("%d item(s) without title", untitled))
+	}
+	if len(f.Items) > 0 && unlinked == len(f.Items) {
+		failures = append(failures, "no item has a link")
+	} else if unlinked > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d item(s) without link", unlinked))
+	}
+	if len(f.Items) > 0 && undated == len(f.Items) {
+		failures = append(failures, "no item has a date")
+	} else if undated > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d item(s) without date", undated))
+	}
+	if future > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d item(s) dated in the future", future))
+	}
+	if duplicates > 0 {
+		failures = append(failures, fmt.Sprintf("%d item(s) with a duplicate guid", duplicates))
+	}
+
+	for _, format := range []string{"rss", "atom", "json"} {
+		if _, _, err := parser.RenderFeed(f, o, format); err != nil {
+			failures = append(failures, fmt.Sprintf("unable to render as %s: %s", format, err))
+		}
+	}
+	return failures, warnings
+}
+
+func runTarget(t Target, timeout time.Duration) *Result {
+	r := &Result{Name: t.Name, Module: t.Module}
+	if t.SkipReason != "" {
+		r.Status = STATUS_SKIPPED
+		r.Error = t.SkipReason
+		return r
+	}
+
+	type parseResult struct {
+		feed *feeds.Feed
+		o    *parser.Options
+		err  error
+	}
+	done := make(chan parseResult, 1)
+	start := time.Now()
+	// the upstream requests stop with the context, the modules ignoring it
+	// keep running in the background
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		feed, o, err := t.Parse(ctx)
+		done <- parseResult{feed, o, err}
+	}()
+	var res parseResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+	r.duration = time.Since(start)
+	r.DurationMs = r.duration.Milliseconds()
+
+	if res.err != nil {
+		r.Status = STATUS_ERROR
+		r.Error = res.err.Error()
+		r.ErrorClass = parser.GetErrorClass(res.err)
+		return r
+	}
+	r.Items = len(res.feed.Items)
+	r.Failures, r.Warnings = CheckFeed(res.feed, res.o)
+	r.Status = STATUS_PASS
+	if len(r.Failures) > 0 {
+		r.Status = STATUS_FAIL
+	}
+	return r
+}
+
+// Run checks the targets, at most concurrency at a time, returning their results sorted by name
+func Run(targets []Target, concurrency int, timeout time.Duration) *Report {
+	report := &Report{Time: time.Now()}
+	results := make([]*Result, len(targets))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(concurrency, 1))
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runTarget(t, timeout)
+		}()
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	report.Results = results
+	report.duration = time.Since(report.Time)
+	return report
+}
@@ -0,0 +1,110 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	name  string
+	items []*feeds.Item
+	err   error
+}
+
+func (p testParser) Parse(*parser.Options) (*feeds.Feed, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &feeds.Feed{Title: p.name, Link: &feeds.Link{Href: "https://example.com"}, Items: p.items}, nil
+}
+func (p testParser) String() string { return p.name }
+func (p testParser) GetOptions() parser.Options {
+	return parser.Options{OptionsList: parser.OptionsList{}, Parser: p}
+}
+
+func newItem(id string, dated bool) *feeds.Item {
+	item := &feeds.Item{Id: id, Title: "item " + id, Link: &feeds.Link{Href: "https://example.com/" + id}}
+	if dated {
+		item.Created = time.Now()
+	}
+	return item
+}
+
+func TestRun(t *testing.T) {
+	targets := []Target{
+		ModuleTarget("good", testParser{name: "good", items: []*feeds.Item{newItem("1", true), newItem("2", false)}}),
+		ModuleTarget("empty", testParser{name: "empty"}),
+		ModuleTarget("duplicates", testParser{name: "duplicates", items: []*feeds.Item{newItem("1", true), newItem("1", true)}}),
+		ModuleTarget("broken", testParser{name: "broken", err: errors.New("unexpected markup")}),
+		{Name: "skipped", SkipReason: "no sample options"},
+	}
+	report := Run(targets, 2, time.Second)
+
+	want := map[string]string{
+		"good":       STATUS_PASS,
+		"empty":      STATUS_FAIL,
+		"duplicates": STATUS_FAIL,
+		"broken":     STATUS_ERROR,
+		"skipped":    STATUS_SKIPPED,
+	}
+	for _, r := range report.Results {
+		if r.Status != want[r.Name] {
+			t.Errorf("%s: status %s, want %s (%v)", r.Name, r.Status, want[r.Name], r.Failures)
+		}
+		if r.Name == "good" && (len(r.Warnings) != 1 || r.Items != 2) {
+			t.Errorf("good: unexpected result %+v", r)
+		}
+	}
+	if report.Results[0].Name != "broken" {
+		t.Errorf("results aren't sorted: %s first", report.Results[0].Name)
+	}
+	if !report.Failed() {
+		t.Error("report should have failed")
+	}
+
+	var junit bytes.Buffer
+	if err := report.WriteJUnit(&junit); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`tests="5" failures="2" errors="1" skipped="1"`, `<error message="parse">unexpected markup</error>`, `<failure message="feed has no items">`} {
+		if !strings.Contains(junit.String(), s) {
+			t.Errorf("JUnit report misses %s:\n%s", s, junit.String())
+		}
+	}
+
+	var summary bytes.Buffer
+	report.WriteSummary(&summary)
+	if !strings.Contains(summary.String(), "1 passed, 2 failed, 1 errored, 1 skipped") {
+		t.Errorf("unexpected summary:\n%s", summary.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	target := Target{Name: "slow", Parse: func(ctx context.Context) (*feeds.Feed, *parser.Options, error) {
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, nil, ctx.Err()
+		case <-time.After(time.Second):
+			return nil, nil, nil
+		}
+	}}
+	report := Run([]Target{target}, 1, 10*time.Millisecond)
+	if r := report.Results[0]; r.Status != STATUS_ERROR || r.ErrorClass != "timeout" {
+		t.Errorf("unexpected result %+v", r)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("the parse should be cancelled on timeout")
+	}
+}
@@ -0,0 +1,130 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Report struct {
+	Time    time.Time `json:"time"`
+	Results []*Result `json:"results"`
+
+	duration time.Duration
+}
+
+// Counts returns the number of results by status
+func (r *Report) Counts() map[string]int {
+	counts := map[string]int{STATUS_PASS: 0, STATUS_FAIL: 0, STATUS_ERROR: 0, STATUS_SKIPPED: 0}
+	for _, result := range r.Results {
+		counts[result.Status]++
+	}
+	return counts
+}
+
+func (r *Report) Failed() bool {
+	counts := r.Counts()
+	return counts[STATUS_FAIL] > 0 || counts[STATUS_ERROR] > 0
+}
+
+func (r *Report) WriteSummary(w io.Writer) {
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "%-7s %s (%dms, %d items)\n", strings.ToUpper(result.Status), result.Name, result.DurationMs, result.Items)
+		if result.Error != "" {
+			fmt.Fprintf(w, "        %s\n", result.Error)
+		}
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "        failure: %s\n", failure)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(w, "        warning: %s\n", warning)
+		}
+	}
+	counts := r.Counts()
+	fmt.Fprintf(w, "\n%d checked in %s: %d passed, %d failed, %d errored, %d skipped\n",
+		len(r.Results), r.duration.Round(time.Millisecond), counts[STATUS_PASS], counts[STATUS_FAIL], counts[STATUS_ERROR], counts[STATUS_SKIPPED])
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func (r *Report) WriteJUnit(w io.Writer) error {
+	counts := r.Counts()
+	suite := junitTestSuite{
+		Name:      "rss-banquet",
+		Tests:     len(r.Results),
+		Failures:  counts[STATUS_FAIL],
+		Errors:    counts[STATUS_ERROR],
+		Skipped:   counts[STATUS_SKIPPED],
+		Time:      seconds(r.duration),
+		Timestamp: r.Time.Format("2006-01-02T15:04:05"),
+	}
+	for _, result := range r.Results {
+		classname := result.Module
+		if classname == "" {
+			classname = "unknown"
+		}
+		tc := junitTestCase{
+			Name:      result.Name,
+			Classname: classname,
+			Time:      seconds(result.duration),
+			SystemOut: strings.Join(result.Warnings, "\n"),
+		}
+		switch result.Status {
+		case STATUS_FAIL:
+			tc.Failure = &junitMessage{Message: result.Failures[0], Body: strings.Join(result.Failures, "\n")}
+		case STATUS_ERROR:
+			tc.Error = &junitMessage{Message: result.ErrorClass, Body: result.Error}
+		case STATUS_SKIPPED:
+			tc.Skipped = &junitMessage{Message: result.Error}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
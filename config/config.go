@@ -22,18 +22,198 @@ var CONFIG_OPTIONS = []ConfigOption{
 		Scope:       "GLOBAL",
 		Description: "Log level (trace, debug, info, warn, error, fatal, panic, disabled)",
 	},
+	{
+		Name:        "CACHE_DIR",
+		Value:       "",
+		Scope:       "GLOBAL",
+		Description: "Directory where the modules caches and sessions are persisted across restarts",
+	},
+	{
+		Name:        "HOST_CONCURRENCY",
+		Value:       "4",
+		Scope:       "GLOBAL",
+		Description: "Maximum concurrent requests per upstream host, 0 for no limit",
+	},
+	{
+		Name:        "HOST_DELAY",
+		Value:       "0s",
+		Scope:       "GLOBAL",
+		Description: "Minimum delay between two requests to an upstream host",
+	},
+	{
+		Name:        "ROBOTS_TXT",
+		Value:       "false",
+		Scope:       "GLOBAL",
+		Description: "Honor the crawl-delay of the upstream hosts robots.txt",
+	},
+	{
+		Name:        "PROXY",
+		Value:       "",
+		Scope:       "GLOBAL",
+		Description: "Proxy of the upstream requests, as a http://, https:// or socks5:// URL with optional user:password@ credentials, the standard HTTP_PROXY and HTTPS_PROXY variables being used when empty",
+	},
+	{
+		Name:        "LOG_FORMAT",
+		Value:       "console",
+		Scope:       "GLOBAL",
+		Description: "Log format (console, json)",
+	},
+	{
+		Name:        "TRACING_EXPORTER",
+		Value:       "",
+		Scope:       "GLOBAL",
+		Description: "OpenTelemetry traces exporter (otlp, stdout), tracing is disabled when empty. The otlp exporter is configured with the standard `OTEL_EXPORTER_OTLP_*` environment variables",
+	},
+	{
+		Name:        "TRACING_SAMPLE_RATIO",
+		Value:       "1",
+		Scope:       "GLOBAL",
+		Description: "Ratio of the traces sampled, when not sampled by the caller",
+	},
 	{
 		Name:        "USER_AGENT",
 		Value:       "",
 		Scope:       "GLOBAL",
 		Description: "User agent to use for HTTP requests",
 	},
+	{
+		Name:        "CONFIG_FILE",
+		Value:       "",
+		Scope:       "GLOBAL",
+		Description: "Path to a YAML file listing the feeds served by the instance, its settings and modules (see config.sample.yaml)",
+	},
+	{
+		Name:        "CONFIG_WATCH_INTERVAL",
+		Value:       "10s",
+		Scope:       "SERVER",
+		Description: "Interval the CONFIG_FILE is checked for changes at, 0s reloading it on SIGHUP only",
+	},
 	{
 		Name:        "SERVER_PORT",
 		Value:       "8080",
 		Scope:       "SERVER",
 		Description: "Port to listen on in server mode",
 	},
+	{
+		Name:        "READ_HEADER_TIMEOUT",
+		Value:       "10s",
+		Scope:       "SERVER",
+		Description: "Maximum duration to read the request headers",
+	},
+	{
+		Name:        "READ_TIMEOUT",
+		Value:       "30s",
+		Scope:       "SERVER",
+		Description: "Maximum duration to read the whole request",
+	},
+	{
+		Name:        "WRITE_TIMEOUT",
+		Value:       "5m",
+		Scope:       "SERVER",
+		Description: "Maximum duration to parse and write a response, streams excepted",
+	},
+	{
+		Name:        "IDLE_TIMEOUT",
+		Value:       "2m",
+		Scope:       "SERVER",
+		Description: "Maximum duration a keep-alive connection is kept idle",
+	},
+	{
+		Name:        "SHUTDOWN_TIMEOUT",
+		Value:       "30s",
+		Scope:       "SERVER",
+		Description: "Maximum duration waited for the in-flight requests on shutdown",
+	},
+	{
+		Name:        "TLS_CERT_FILE",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Certificate file to serve HTTPS, along with TLS_KEY_FILE",
+	},
+	{
+		Name:        "TLS_KEY_FILE",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Private key file of TLS_CERT_FILE",
+	},
+	{
+		Name:        "UNIX_SOCKET",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Unix socket path to listen on instead of the server port",
+	},
+	{
+		Name:        "BASE_URL",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Public URL of the server used in exported links, guessed from the request if empty",
+	},
+	{
+		Name:        "WEBSUB",
+		Value:       "false",
+		Scope:       "SERVER",
+		Description: "Act as a WebSub hub for the served feeds, pushing their new items to subscribers",
+	},
+	{
+		Name:        "WEBSUB_INTERVAL",
+		Value:       "15m",
+		Scope:       "SERVER",
+		Description: "Interval between two parses of the feeds having WebSub subscribers",
+	},
+	{
+		Name:        "WEBSUB_STATE_FILE",
+		Value:       "websub-state.json",
+		Scope:       "SERVER",
+		Description: "File storing the WebSub subscriptions",
+	},
+	{
+		Name:        "STREAM_INTERVAL",
+		Value:       "5m",
+		Scope:       "SERVER",
+		Description: "Interval between two parses of the feeds streamed as Server-Sent Events",
+	},
+	{
+		Name:        "STREAM_STATE_FILE",
+		Value:       "stream-state.json",
+		Scope:       "SERVER",
+		Description: "File storing the recent events of the streamed feeds, for Last-Event-ID resumes",
+	},
+	{
+		Name:        "BASELINES_FILE",
+		Value:       "baselines.json",
+		Scope:       "SERVER",
+		Description: "File storing the item count and completeness baselines of the served feeds, used to detect scraper breakages. Empty to keep them in memory",
+	},
+	{
+		Name:        "BREAKAGE_ITEM",
+		Value:       "false",
+		Scope:       "SERVER",
+		Description: "Add an item to the feeds showing a possible scraper breakage",
+	},
+	{
+		Name:        "FEED_CACHE_TTL",
+		Value:       "0s",
+		Scope:       "SERVER",
+		Description: "Duration the served feeds are cached for, 0s disabling the cache",
+	},
+	{
+		Name:        "RATE_LIMIT",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Feed requests allowed per client and module, as <requests>/<duration> (eg 60/1h), expensive modules having stricter defaults",
+	},
+	{
+		Name:        "RATE_LIMIT_MODULE",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Feed requests allowed per module across all clients, as <requests>/<duration>",
+	},
+	{
+		Name:        "DEBUG_TOKEN",
+		Value:       "",
+		Scope:       "SERVER",
+		Description: "Token required by the /api/debug/feed endpoint, disabled when empty",
+	},
 }
 
 func ReadmeText() string {
@@ -47,32 +227,40 @@ func ReadmeText() string {
 		}
 		s += "\n"
 	}
+	s += fmt.Sprintf("\nThe modules settings and the defaults of their options can be set with `%s_%s_<MODULE>_<NAME>` variables (eg `%s`), or read from the file named by `%s_%s_<MODULE>_<NAME>%s` (eg a Docker secret).\n", ENV_PREFIX, MODULE_SCOPE, ModuleEnvName("goodreads", "language"), ENV_PREFIX, MODULE_SCOPE, FILE_SUFFIX)
 	return s
 }
 
 func InitConfig() {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if defaultValues == nil {
+		defaultValues = make(map[string]string, len(CONFIG_OPTIONS))
+		for _, option := range CONFIG_OPTIONS {
+			defaultValues[option.Name] = option.Value
+		}
+	}
+	envValues = make(map[string]string)
 	for i := range CONFIG_OPTIONS {
 		env := os.Getenv(strings.Join([]string{ENV_PREFIX, CONFIG_OPTIONS[i].Scope, CONFIG_OPTIONS[i].Name}, "_"))
 		if env != "" {
 			CONFIG_OPTIONS[i].Value = env
+			envValues[CONFIG_OPTIONS[i].Name] = env
 		}
 	}
+	s := *current.Load()
+	s.Values = nil
+	publish(&s)
 }
 
 func GetConfigOptionIfExists(name string) (string, error) {
-	for _, option := range CONFIG_OPTIONS {
-		if option.Name == name {
-			return option.Value, nil
-		}
+	value, ok := Current().Values[name]
+	if !ok {
+		return "", fmt.Errorf("option not found")
 	}
-	return "", fmt.Errorf("option not found")
+	return value, nil
 }
 
 func GetConfigOption(name string) string {
-	for _, option := range CONFIG_OPTIONS {
-		if option.Name == name {
-			return option.Value
-		}
-	}
-	return ""
+	return Current().Values[name]
 }
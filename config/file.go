@@ -0,0 +1,358 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ACCESS_PUBLIC              = "public"
+	ACCESS_TOKEN               = "token"
+	ACCESS_SCOPE_FEEDS         = "feeds"
+	ACCESS_SCOPE_API           = "api"
+	ACCESS_SCOPE_MODULE_PREFIX = "module:"
+)
+
+type FeedConfig struct {
+	Name    string         `yaml:"name"`
+	Module  string         `yaml:"module"`
+	Options map[string]any `yaml:"options,omitempty"`
+	Private bool           `yaml:"private,omitempty"`
+	// Notify lists the notifier targets receiving the feed new items
+	Notify []string `yaml:"notify,omitempty"`
+}
+
+// STATIC_BUILD_OPTIONS are the feed options of the static site builds: the
+// title and file name of the feed, and private feeds left out of the index.
+// The modules not having them as options ignore them.
+var STATIC_BUILD_OPTIONS = []string{"title", "filename", "private"}
+
+// IsPrivate returns whether the feed is left out of the index and the OPML export
+func (f FeedConfig) IsPrivate() bool {
+	return f.Private || fmt.Sprint(f.Options["private"]) == "true"
+}
+
+type TargetConfig struct {
+	Name string `yaml:"name"`
+	// Type is one of webhook, slack, discord, matrix, ntfy, gotify
+	Type    string            `yaml:"type"`
+	Url     string            `yaml:"url"`
+	Token   string            `yaml:"token,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Template is the Go template of the webhook request body
+	Template string `yaml:"template,omitempty"`
+	Retries  *int   `yaml:"retries,omitempty"`
+}
+
+type NotifierConfig struct {
+	Interval       time.Duration  `yaml:"interval,omitempty"`
+	StateFile      string         `yaml:"state_file,omitempty"`
+	DeadLetterFile string         `yaml:"dead_letter_file,omitempty"`
+	RetryDelay     time.Duration  `yaml:"retry_delay,omitempty"`
+	Targets        []TargetConfig `yaml:"targets,omitempty"`
+}
+
+type TokenConfig struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	// Scopes lists what the token gives access to: `feeds` (every feed),
+	// `module:<name>` (the feeds of a module), `module:<name>?<option>=<value>`
+	// (the feeds of a module with these option values) and `api` (API and help endpoints)
+	Scopes []string `yaml:"scopes"`
+}
+
+type AccessConfig struct {
+	// Default is the access of the modules not listed in Modules, public or token
+	Default string            `yaml:"default,omitempty"`
+	Modules map[string]string `yaml:"modules,omitempty"`
+	// Api is the access of the API and help endpoints and the generator UI
+	Api    string        `yaml:"api,omitempty"`
+	Tokens []TokenConfig `yaml:"tokens,omitempty"`
+}
+
+// RateLimitConfig overrides the rate limits of a module, as <requests>/<duration>
+// or none
+type RateLimitConfig struct {
+	Client string `yaml:"client,omitempty"`
+	Module string `yaml:"module,omitempty"`
+}
+
+// PolitenessConfig limits the requests to an upstream host: concurrent
+// requests, and delay between the start of two requests
+type PolitenessConfig struct {
+	Concurrency int           `yaml:"concurrency,omitempty"`
+	Delay       time.Duration `yaml:"delay,omitempty"`
+}
+
+type UpstreamsConfig struct {
+	// Hosts are exact host names, or *.domain patterns
+	Hosts   map[string]PolitenessConfig `yaml:"hosts,omitempty"`
+	Modules map[string]PolitenessConfig `yaml:"modules,omitempty"`
+	// Proxies are the proxies of the host patterns, overriding the modules and global ones
+	Proxies map[string]string `yaml:"proxies,omitempty"`
+}
+
+// PROXY_DIRECT disables the proxy of a host or module
+const PROXY_DIRECT = "direct"
+
+// ValidateProxy checks a proxy URL: http, https or socks5, or direct
+func ValidateProxy(proxy string) error {
+	if proxy == "" || proxy == PROXY_DIRECT {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		// the error would show the credentials
+		return fmt.Errorf("invalid proxy url")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("invalid proxy %s: http, https or socks5 expected", u.Redacted())
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy %s: missing host", u.Redacted())
+	}
+	return nil
+}
+
+type ModuleConfig struct {
+	// Enabled defaults to true
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Defaults overrides the default values of the module options
+	Defaults map[string]string `yaml:"defaults,omitempty"`
+	// Settings sets the module settings, <NAME>_FILE reading one from a file
+	Settings map[string]string `yaml:"settings,omitempty"`
+}
+
+func (m ModuleConfig) IsEnabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+type FileConfig struct {
+	Feeds      []FeedConfig               `yaml:"feeds"`
+	Notifier   *NotifierConfig            `yaml:"notifier,omitempty"`
+	Access     *AccessConfig              `yaml:"access,omitempty"`
+	RateLimits map[string]RateLimitConfig `yaml:"rate_limits,omitempty"`
+	Upstreams  *UpstreamsConfig           `yaml:"upstreams,omitempty"`
+	// Settings sets the options, overridden by their environment variables
+	Settings map[string]string       `yaml:"settings,omitempty"`
+	Modules  map[string]ModuleConfig `yaml:"modules,omitempty"`
+	// OutputPath and BuildIndex are the settings of the static site builds
+	OutputPath string `yaml:"output_path,omitempty"`
+	BuildIndex bool   `yaml:"build_index,omitempty"`
+}
+
+// GetOptions returns the feed options as strings, as they would be read from an URL
+func (f FeedConfig) GetOptions() map[string]string {
+	options := make(map[string]string, len(f.Options))
+	for k, v := range f.Options {
+		options[k] = fmt.Sprint(v)
+	}
+	return options
+}
+
+func LoadConfigFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c FileConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+func (c *FileConfig) validate() error {
+	targets := make(map[string]bool)
+	if c.Notifier != nil {
+		for i, target := range c.Notifier.Targets {
+			if target.Name == "" || target.Url == "" {
+				return fmt.Errorf("notifier target %d needs a name and an url", i)
+			}
+			targets[target.Name] = true
+		}
+	}
+	if c.Access != nil {
+		if err := c.Access.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Upstreams != nil {
+		for host, p := range c.Upstreams.Hosts {
+			if p.Concurrency < 0 || p.Delay < 0 {
+				return fmt.Errorf("upstream host %s has a negative concurrency or delay", host)
+			}
+		}
+		for module, p := range c.Upstreams.Modules {
+			if p.Concurrency < 0 || p.Delay < 0 {
+				return fmt.Errorf("upstream module %s has a negative concurrency or delay", module)
+			}
+		}
+		for host, proxy := range c.Upstreams.Proxies {
+			if err := ValidateProxy(proxy); err != nil {
+				return fmt.Errorf("upstream host %s: %w", host, err)
+			}
+		}
+	}
+	for i, feed := range c.Feeds {
+		if feed.Module == "" {
+			return fmt.Errorf("feed %d (%s) has no module", i, feed.Name)
+		}
+		for _, target := range feed.Notify {
+			if !targets[target] {
+				return fmt.Errorf("feed %s notifies unknown target %s", feed.Name, target)
+			}
+		}
+	}
+	return nil
+}
+
+func validateAccess(access string) error {
+	switch access {
+	case "", ACCESS_PUBLIC, ACCESS_TOKEN:
+		return nil
+	default:
+		return fmt.Errorf("invalid access %s: %s or %s expected", access, ACCESS_PUBLIC, ACCESS_TOKEN)
+	}
+}
+
+func (a *AccessConfig) validate() error {
+	if err := validateAccess(a.Default); err != nil {
+		return err
+	}
+	if err := validateAccess(a.Api); err != nil {
+		return err
+	}
+	for module, access := range a.Modules {
+		if err := validateAccess(access); err != nil {
+			return fmt.Errorf("module %s: %w", module, err)
+		}
+	}
+	tokens := make(map[string]bool)
+	for i, token := range a.Tokens {
+		if token.Name == "" || token.Token == "" {
+			return fmt.Errorf("access token %d needs a name and a token", i)
+		}
+		if tokens[token.Token] {
+			return fmt.Errorf("access token %s is not unique", token.Name)
+		}
+		tokens[token.Token] = true
+		for _, scope := range token.Scopes {
+			if scope != ACCESS_SCOPE_FEEDS && scope != ACCESS_SCOPE_API && !strings.HasPrefix(scope, ACCESS_SCOPE_MODULE_PREFIX) {
+				return fmt.Errorf("access token %s has an invalid scope %s", token.Name, scope)
+			}
+		}
+	}
+	return nil
+}
+
+// GetConfigFile loads the CONFIG_FILE, returning an empty config if none is set
+func GetConfigFile() (*FileConfig, error) {
+	path := GetConfigOption("CONFIG_FILE")
+	if path == "" {
+		return &FileConfig{}, nil
+	}
+	return LoadConfigFile(path)
+}
+
+// FileChange is a change of an entry of the config file, named by its path
+type FileChange struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (c FileChange) String() string {
+	// the module settings and proxy urls may hold credentials
+	secret := IsSecret(strings.ToUpper(c.Path)) || strings.Contains(c.Path, ".settings.") || strings.HasPrefix(c.Path, "upstreams.proxies.")
+	switch {
+	case c.Old == "":
+		if secret {
+			return fmt.Sprintf("%s added", c.Path)
+		}
+		return fmt.Sprintf("%s added: %q", c.Path, c.New)
+	case c.New == "":
+		return fmt.Sprintf("%s removed", c.Path)
+	case secret:
+		return fmt.Sprintf("%s changed", c.Path)
+	default:
+		return fmt.Sprintf("%s: %q -> %q", c.Path, c.Old, c.New)
+	}
+}
+
+// flattenEntries adds the values of a decoded YAML document to entries, by
+// path. The list items having a name are keyed by it, the other lists are
+// single values.
+func flattenEntries(entries map[string]string, path string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			flattenEntries(entries, path+"."+key, value)
+		}
+	case []any:
+		named := make(map[string]any, len(v))
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				if name, ok := m["name"].(string); ok {
+					named[name] = item
+				}
+			}
+		}
+		if len(named) != len(v) {
+			data, _ := yaml.Marshal(v)
+			entries[path] = strings.TrimSpace(string(data))
+			return
+		}
+		for name, item := range named {
+			flattenEntries(entries, path+"."+name, item)
+		}
+	default:
+		if s := fmt.Sprint(v); v != nil && s != "" {
+			entries[path] = s
+		}
+	}
+}
+
+func getReloadedEntries(c *FileConfig) map[string]string {
+	// the notifier is applied on restart, the settings are diffed as options
+	sections := FileConfig{Feeds: c.Feeds, Access: c.Access, RateLimits: c.RateLimits, Upstreams: c.Upstreams, Modules: c.Modules}
+	entries := make(map[string]string)
+	var doc map[string]any
+	data, err := yaml.Marshal(sections)
+	if err == nil && yaml.Unmarshal(data, &doc) == nil {
+		for section, value := range doc {
+			flattenEntries(entries, section, value)
+		}
+	}
+	return entries
+}
+
+// DiffFileConfig returns the changes of the feeds, access, rate_limits,
+// upstreams and modules sections, sorted by path
+func DiffFileConfig(old *FileConfig, new *FileConfig) []FileChange {
+	oldEntries, newEntries := getReloadedEntries(old), getReloadedEntries(new)
+	var changes []FileChange
+	for path, value := range newEntries {
+		if oldEntries[path] != value {
+			changes = append(changes, FileChange{Path: path, Old: oldEntries[path], New: value})
+		}
+	}
+	for path, value := range oldEntries {
+		if _, ok := newEntries[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Old: value})
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes
+}
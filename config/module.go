@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MODULE_SCOPE is the scope of the environment variables of the module settings and option defaults
+const MODULE_SCOPE = "MODULE"
+
+// FILE_SUFFIX suffixes the settings read from a file, such as a Docker secret
+const FILE_SUFFIX = "_FILE"
+
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// ModuleEnvName returns the BANQUET_MODULE_<MODULE>_<NAME> environment variable of a module setting
+func ModuleEnvName(module string, name string) string {
+	return strings.Join([]string{ENV_PREFIX, MODULE_SCOPE, envName(module), envName(name)}, "_")
+}
+
+// ReadSecretFile reads a setting from a file, without its trailing newline
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// LookupModuleEnv reads a module setting from its environment variable, or
+// from the file named by its _FILE variable
+func LookupModuleEnv(module string, name string) (string, bool, error) {
+	env := ModuleEnvName(module, name)
+	if value, ok := os.LookupEnv(env); ok {
+		return value, true, nil
+	}
+	path, ok := os.LookupEnv(env + FILE_SUFFIX)
+	if !ok {
+		return "", false, nil
+	}
+	value, err := ReadSecretFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", env+FILE_SUFFIX, err)
+	}
+	return value, true, nil
+}
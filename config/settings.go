@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// RESTART_REQUIRED lists the options only read when the server starts
+var RESTART_REQUIRED = []string{
+	"CONFIG_FILE", "CONFIG_WATCH_INTERVAL", "SERVER_PORT", "UNIX_SOCKET", "TLS_CERT_FILE", "TLS_KEY_FILE",
+	"READ_HEADER_TIMEOUT", "READ_TIMEOUT", "WRITE_TIMEOUT", "IDLE_TIMEOUT", "SHUTDOWN_TIMEOUT",
+	"LOG_FORMAT", "TRACING_EXPORTER", "TRACING_SAMPLE_RATIO",
+	"WEBSUB", "WEBSUB_INTERVAL", "WEBSUB_STATE_FILE", "STREAM_INTERVAL", "STREAM_STATE_FILE",
+	"BASELINES_FILE", "DEBUG_TOKEN",
+}
+
+var (
+	configMu sync.RWMutex
+	// the values of the options before the environment and settings are applied
+	defaultValues map[string]string
+	envValues     map[string]string
+)
+
+func validateDuration(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("positive duration expected")
+	}
+	return nil
+}
+
+func validateBool(v string) error {
+	if v != "true" && v != "false" {
+		return fmt.Errorf("true or false expected")
+	}
+	return nil
+}
+
+// VALIDATORS check the values of the typed options
+var VALIDATORS = map[string]func(string) error{
+	"LOG_LEVEL": func(v string) error {
+		_, err := zerolog.ParseLevel(v)
+		return err
+	},
+	"LOG_FORMAT": func(v string) error {
+		if v != "console" && v != "json" {
+			return fmt.Errorf("console or json expected")
+		}
+		return nil
+	},
+	"TRACING_SAMPLE_RATIO": func(v string) error {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 || r > 1 {
+			return fmt.Errorf("ratio between 0 and 1 expected")
+		}
+		return nil
+	},
+	"HOST_CONCURRENCY": func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("positive number expected")
+		}
+		return nil
+	},
+	"HOST_DELAY":            validateDuration,
+	"PROXY":                 ValidateProxy,
+	"READ_HEADER_TIMEOUT":   validateDuration,
+	"READ_TIMEOUT":          validateDuration,
+	"WRITE_TIMEOUT":         validateDuration,
+	"IDLE_TIMEOUT":          validateDuration,
+	"SHUTDOWN_TIMEOUT":      validateDuration,
+	"CONFIG_WATCH_INTERVAL": validateDuration,
+	"WEBSUB_INTERVAL":       validateDuration,
+	"STREAM_INTERVAL":       validateDuration,
+	"FEED_CACHE_TTL":        validateDuration,
+	"ROBOTS_TXT":            validateBool,
+	"WEBSUB":                validateBool,
+	"BREAKAGE_ITEM":         validateBool,
+}
+
+// OptionChange is the change of an option value on reload
+type OptionChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// IsSecret returns whether the option value must be left out of the logs
+func IsSecret(name string) bool {
+	return strings.Contains(name, "TOKEN") || strings.Contains(name, "SECRET") || strings.Contains(name, "PASSWORD") || strings.Contains(name, "PROXY")
+}
+
+func (c OptionChange) String() string {
+	if IsSecret(c.Name) {
+		return fmt.Sprintf("%s changed", c.Name)
+	}
+	return fmt.Sprintf("%s: %q -> %q", c.Name, c.Old, c.New)
+}
+
+// RequiresRestart returns whether the change only applies once the server restarts
+func (c OptionChange) RequiresRestart() bool {
+	return slices.Contains(RESTART_REQUIRED, c.Name)
+}
+
+// ResolveSettings validates the settings of a config file, returning the
+// values of every option: their environment variable, or setting, or default
+func ResolveSettings(settings map[string]string) (map[string]string, error) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	values := make(map[string]string, len(CONFIG_OPTIONS))
+	for _, option := range CONFIG_OPTIONS {
+		values[option.Name] = option.Value
+		if defaultValue, ok := defaultValues[option.Name]; ok {
+			values[option.Name] = defaultValue
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("unknown setting %s", name)
+		}
+		values[name] = settings[name]
+	}
+	maps.Copy(values, envValues)
+	for name, validate := range VALIDATORS {
+		if err := validate(values[name]); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", name, values[name], err)
+		}
+	}
+	return values, nil
+}
+
+// SetValues swaps the values of the options, returning the changes
+func SetValues(values map[string]string) []OptionChange {
+	configMu.Lock()
+	defer configMu.Unlock()
+	s := *current.Load()
+	s.Values = values
+	return publish(&s)
+}
+
+// GetConfigOptions returns the current values of every option
+func GetConfigOptions() map[string]string {
+	return maps.Clone(Current().Values)
+}
+
+// GetDefaultValues returns the default values of the options, before the settings and environment
+func GetDefaultValues() map[string]string {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if defaultValues != nil {
+		return maps.Clone(defaultValues)
+	}
+	values := make(map[string]string, len(CONFIG_OPTIONS))
+	for _, option := range CONFIG_OPTIONS {
+		values[option.Name] = option.Value
+	}
+	return values
+}
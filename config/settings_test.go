@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestResolveSettings(t *testing.T) {
+	t.Setenv("BANQUET_GLOBAL_USER_AGENT", "from-env")
+	InitConfig()
+	defer func() {
+		SetValues(defaultValues)
+		InitConfig()
+	}()
+
+	values, err := ResolveSettings(map[string]string{"USER_AGENT": "from-file", "LOG_LEVEL": "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["USER_AGENT"] != "from-env" {
+		t.Errorf("environment variables should override the settings: %s", values["USER_AGENT"])
+	}
+	if values["LOG_LEVEL"] != "debug" || values["SERVER_PORT"] != "8080" {
+		t.Errorf("unexpected values %s %s", values["LOG_LEVEL"], values["SERVER_PORT"])
+	}
+
+	for _, invalid := range []map[string]string{
+		{"UNKNOWN": "1"},
+		{"LOG_LEVEL": "loud"},
+		{"FEED_CACHE_TTL": "soon"},
+		{"HOST_CONCURRENCY": "-1"},
+	} {
+		if _, err := ResolveSettings(invalid); err == nil {
+			t.Errorf("ResolveSettings(%v) should fail", invalid)
+		}
+	}
+
+	changes := SetValues(values)
+	if len(changes) != 1 || changes[0].Name != "LOG_LEVEL" || changes[0].RequiresRestart() {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	if GetConfigOption("LOG_LEVEL") != "debug" {
+		t.Errorf("LOG_LEVEL should be applied")
+	}
+	values["SERVER_PORT"] = "9000"
+	values["DEBUG_TOKEN"] = "secret"
+	for _, change := range SetValues(values) {
+		if !change.RequiresRestart() {
+			t.Errorf("%s should require a restart", change.Name)
+		}
+		if change.Name == "DEBUG_TOKEN" && change.String() != "DEBUG_TOKEN changed" {
+			t.Errorf("secrets should be redacted: %s", change)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"maps"
+	"sync/atomic"
+)
+
+// Snapshot is a config applied at once: the options values, the config file,
+// and the state the other packages build from them, such as the resolved
+// modules config. It's published behind a single pointer, so that the readers
+// never see a mix of the config before and after a reload.
+type Snapshot struct {
+	Values map[string]string
+	File   *FileConfig
+	state  map[any]any
+}
+
+var (
+	current        atomic.Pointer[Snapshot]
+	emptyUpstreams = &UpstreamsConfig{}
+)
+
+func init() {
+	values := make(map[string]string, len(CONFIG_OPTIONS))
+	for _, option := range CONFIG_OPTIONS {
+		values[option.Name] = option.Value
+	}
+	current.Store(&Snapshot{Values: values, File: &FileConfig{}})
+}
+
+// Current returns the config currently applied
+func Current() *Snapshot {
+	return current.Load()
+}
+
+// NewSnapshot returns a config to publish, without the state of the other packages
+func NewSnapshot(values map[string]string, file *FileConfig) *Snapshot {
+	return &Snapshot{Values: values, File: file}
+}
+
+// Value returns the state stored under key, like the context values
+func (s *Snapshot) Value(key any) any {
+	return s.state[key]
+}
+
+// WithValue returns a copy of the snapshot storing value under key
+func (s *Snapshot) WithValue(key any, value any) *Snapshot {
+	c := *s
+	c.state = maps.Clone(s.state)
+	if c.state == nil {
+		c.state = make(map[any]any)
+	}
+	c.state[key] = value
+	return &c
+}
+
+// Upstreams returns the upstreams config, empty when unset
+func (s *Snapshot) Upstreams() *UpstreamsConfig {
+	if s.File == nil || s.File.Upstreams == nil {
+		return emptyUpstreams
+	}
+	return s.File.Upstreams
+}
+
+// Publish applies the snapshot, returning the changes of the options values.
+// The options missing from its values keep their current value.
+func Publish(s *Snapshot) []OptionChange {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return publish(s)
+}
+
+func publish(s *Snapshot) []OptionChange {
+	values := make(map[string]string, len(CONFIG_OPTIONS))
+	var changes []OptionChange
+	for i := range CONFIG_OPTIONS {
+		value, ok := s.Values[CONFIG_OPTIONS[i].Name]
+		if ok && value != CONFIG_OPTIONS[i].Value {
+			changes = append(changes, OptionChange{Name: CONFIG_OPTIONS[i].Name, Old: CONFIG_OPTIONS[i].Value, New: value})
+			CONFIG_OPTIONS[i].Value = value
+		}
+		values[CONFIG_OPTIONS[i].Name] = CONFIG_OPTIONS[i].Value
+	}
+	c := *s
+	c.Values = values
+	if c.File == nil {
+		c.File = &FileConfig{}
+	}
+	current.Store(&c)
+	return changes
+}
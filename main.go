@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/check"
 	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/metrics"
+	"github.com/nbr23/rss-banquet/notifier"
+	"github.com/nbr23/rss-banquet/openapi"
 	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/stream"
 	"github.com/nbr23/rss-banquet/style"
+	"github.com/nbr23/rss-banquet/tracing"
+	"github.com/nbr23/rss-banquet/websub"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -39,29 +52,30 @@ func responseLogger() gin.HandlerFunc {
 
 		var logger *zerolog.Event
 		if c.Writer.Status() >= 400 && c.Writer.Status() < 600 {
-			logger = log.Error()
+			logger = parser.GetLogger(c.Request.Context()).Error()
 		} else {
-			logger = log.Info()
+			logger = parser.GetLogger(c.Request.Context()).Info()
 		}
 
+		uri := parser.RedactUrl(c.Request.URL).RequestURI()
 		event := logger.
 			Int("status", c.Writer.Status()).
 			Int("size", c.Writer.Size()).
 			Dur("duration", duration).
 			Str("client_ip", c.ClientIP()).
 			Str("method", c.Request.Method).
-			Str("path", c.Request.RequestURI)
+			Str("path", uri)
 
 		if len(c.Errors) > 0 {
 			event = event.Err(c.Errors.Last())
 		}
-		event.Msgf("%s %s", c.Request.Method, c.Request.RequestURI)
+		event.Msgf("%s %s", c.Request.Method, uri)
 	}
 }
 
 func runServer(args []string) {
 	var f runServerFlags
-	var moduleNames []string
+	var modules []parser.ModuleInfo
 
 	flags := getRunServerFlags(&f)
 	flags.Parse(args)
@@ -75,10 +89,77 @@ func runServer(args []string) {
 		printModulesHelp()
 		return
 	}
+	startConfig := config.Current().File
+	// background polls, stopped on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var background sync.WaitGroup
 
 	r := gin.New()
+	r.Use(parser.RequestIdMiddleware())
+	r.Use(tracing.Middleware())
 	r.Use(responseLogger())
+	r.Use(metrics.Middleware())
 	r.Use(gin.Recovery())
+	s, err := withMiddlewares(config.Current())
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	config.Publish(s)
+	r.Use(configMiddleware())
+	r.Use(snapshotMiddleware(ACCESS_MIDDLEWARE))
+	r.Use(snapshotMiddleware(RATE_LIMIT_MIDDLEWARE))
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		watchConfig(ctx)
+	}()
+
+	r.GET("/metrics", metrics.Handler())
+
+	// started once the config is validated, next to the WebSub hub
+	if startConfig.Notifier != nil {
+		n, err := notifier.New(startConfig.Notifier, startConfig.Feeds, getModule)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			n.Run(ctx)
+		}()
+	}
+
+	if config.GetConfigOption("WEBSUB") == "true" {
+		interval, err := time.ParseDuration(config.GetConfigOption("WEBSUB_INTERVAL"))
+		if err != nil {
+			log.Fatal().Msgf("invalid WEBSUB_INTERVAL: %s", err)
+		}
+		hub, err := websub.NewHub(parser.NewRouteMatcher(getParsers()), interval, config.GetConfigOption("WEBSUB_STATE_FILE"))
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		hub.RegisterRoutes(r)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			hub.Run(ctx)
+		}()
+	}
+
+	streamInterval, err := time.ParseDuration(config.GetConfigOption("STREAM_INTERVAL"))
+	if err != nil {
+		log.Fatal().Msgf("invalid STREAM_INTERVAL: %s", err)
+	}
+	streamer, err := stream.NewStreamer(parser.NewRouteMatcher(getParsers()), streamInterval, config.GetConfigOption("STREAM_STATE_FILE"))
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	streamer.RegisterRoutes(r)
+
+	if token := config.GetConfigOption("DEBUG_TOKEN"); token != "" {
+		r.GET("/api/debug/feed/*path", parser.DebugHandler(parser.NewRouteMatcher(getParsers()), token))
+	}
 
 	r.GET("/api/healthcheck", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -86,27 +167,88 @@ func runServer(args []string) {
 		})
 	})
 
-	for _, module := range Modules {
-		p := module()
-		moduleNames = append(moduleNames, p.String())
+	constructors := parser.GetModules()
+	for _, name := range parser.GetModuleNames() {
+		p := constructors[name]()
+		info := parser.GetModuleInfo(name, p)
+		modules = append(modules, info)
 		parser.Route(r, p, parser.GetFullOptions(p))
 		r.GET(fmt.Sprintf("/api/help/%s", p.String()), func(c *gin.Context) {
+			if !parser.IsModuleEnabled(p.String()) {
+				c.JSON(404, map[string]any{"status": "error", "error": "module disabled"})
+				return
+			}
 			opts := parser.GetFullOptions(p).OptionsList
 			c.JSON(200, map[string]any{
-				"options": opts,
-				"status":  "ok",
+				"info":     info,
+				"options":  opts,
+				"settings": parser.GetSettingsHelp(p),
+				"status":   "ok",
 			})
 		})
 	}
 
+	r.GET("/api/health/modules", func(c *gin.Context) {
+		var parsers []parser.Parser
+		for _, module := range parser.GetModules() {
+			p := module()
+			if m := c.Query("module"); m != "" && m != p.String() || !parser.IsModuleEnabled(p.String()) {
+				continue
+			}
+			parsers = append(parsers, p)
+		}
+		health := parser.GetModulesHealth(parsers, c.Query("canary") == "true")
+		status := parser.GetReadiness(health)
+		code := http.StatusOK
+		if status == "down" {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, map[string]any{
+			"status":  status,
+			"modules": health,
+		})
+	})
+
 	r.GET("/api/modules/list", func(c *gin.Context) {
+		enabled := []string{}
+		details := []parser.ModuleInfo{}
+		for _, info := range modules {
+			if parser.IsModuleEnabled(info.Route) {
+				enabled = append(enabled, info.Route)
+				details = append(details, info)
+			}
+		}
 		c.JSON(200, map[string]any{
-			"modules": moduleNames,
+			"modules": enabled,
+			"details": details,
 		})
 	})
 
+	r.GET(parser.PREVIEW_PATH+"/feed/*path", parser.PreviewHandler(parser.NewRouteMatcher(getParsers())))
+
+	r.GET("/api/openapi.json", func(c *gin.Context) {
+		c.JSON(200, openapi.NewDocument(getParsers(), parser.GetBaseUrl(c)))
+	})
+
+	r.GET("/api/config.schema.json", func(c *gin.Context) {
+		c.JSON(200, openapi.ConfigSchema(getParsers()))
+	})
+
+	r.GET("/api/opml", func(c *gin.Context) {
+		o, err := buildOpml(parser.GetBaseUrl(c), config.Current().File.Feeds, c.Query("examples") != "false").String()
+		if err != nil {
+			c.String(500, "error building opml")
+			return
+		}
+		c.Data(200, "text/x-opml", []byte(o))
+	})
+
 	r.GET("/", func(c *gin.Context) {
-		c.Redirect(http.StatusTemporaryRedirect, "/html/feed_url_generator")
+		target := "/html/feed_url_generator"
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusTemporaryRedirect, target)
 	})
 
 	r.GET("/html/feed_url_generator", func(c *gin.Context) {
@@ -128,7 +270,16 @@ func runServer(args []string) {
 		c.String(200, style.AtomStyle)
 	})
 
-	r.Run(fmt.Sprintf(":%s", f.serverPort))
+	srv := newHttpServer(r)
+	srv.RegisterOnShutdown(streamer.Close)
+	err = serve(srv, f.serverPort)
+	cancel()
+	background.Wait()
+	runShutdownHooks()
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	log.Info().Msg("server stopped")
 }
 
 func runOneShot(args []string) {
@@ -140,11 +291,12 @@ func runOneShot(args []string) {
 
 	m := getModule(args[0])
 	if m == nil {
-		log.Fatal().Msg(fmt.Sprintf("module `%s` not found", args[0]))
+		log.Fatal().Msg(fmt.Sprintf("module `%s` not found or disabled", args[0]))
 	}
 	flags := flag.NewFlagSet(fmt.Sprintf("oneshot %s", m), flag.ExitOnError)
 	o := parser.GetFullOptions(m)
 	o.AddFlags(flags)
+	debug := flags.Bool("debug", false, "Print the upstream requests and parse warnings to stderr")
 
 	flags.Parse(args[1:])
 
@@ -157,25 +309,39 @@ func runOneShot(args []string) {
 		}
 	}
 
-	res, err := m.Parse(o)
+	var capture *parser.DebugCapture
+	if *debug {
+		var ctx context.Context
+		ctx, capture = parser.WithDebugCapture(context.Background())
+		o.SetContext(ctx)
+	}
+	start := time.Now()
+	res, err := parser.ParseFeed(m, o, nil)
+	if capture != nil {
+		values := make(map[string]string)
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name != "debug" {
+				values[f.Name] = f.Value.String()
+			}
+		})
+		printDebugReport(parser.NewDebugReport(m.String(), values, time.Since(start), nil, o, err, capture))
+	}
 
 	if err != nil {
-		fmt.Println(parser.GetFullOptions(m).GetHelp())
+		fmt.Println(parser.GetFullOptions(m).GetHelp() + parser.GetSettingsHelpText(m))
 		log.Fatal().Msg(err.Error())
 		return
 	}
 
-	parser.SortFeedEntries(res)
-
 	var s string
 
 	switch o.Get("feedFormat") {
 	case "rss":
-		s, err = res.ToRss()
+		s, err = parser.ToRss(res, o)
 	case "atom":
-		s, err = res.ToAtom()
+		s, err = parser.ToAtom(res, o)
 	case "json":
-		s, err = res.ToJSON()
+		s, err = parser.ToJSON(res, o)
 	case "text":
 		s = parser.FeedToText(res)
 	default:
@@ -187,6 +353,86 @@ func runOneShot(args []string) {
 	fmt.Println(s)
 }
 
+func printDebugReport(report *parser.DebugReport) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	fmt.Fprintln(os.Stderr, string(data))
+}
+
+func runNotify(args []string) {
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	once := flags.Bool("once", false, "Poll the feeds once and exit")
+	flags.Parse(args)
+
+	fileConfig := config.Current().File
+	if fileConfig.Notifier == nil {
+		log.Fatal().Msg("no notifier configured, set a CONFIG_FILE with a notifier section")
+	}
+	n, err := notifier.New(fileConfig.Notifier, fileConfig.Feeds, getModule)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *once {
+		n.Poll(ctx)
+	} else {
+		n.Run(ctx)
+	}
+	runShutdownHooks()
+}
+
+func writeReport(path string, write func(io.Writer) error) {
+	if path == "" {
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	defer f.Close()
+	if err := write(f); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+}
+
+func runCheck(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	concurrency := flags.Int("concurrency", check.DEFAULT_CONCURRENCY, "Number of feeds checked in parallel")
+	timeout := flags.Duration("timeout", check.DEFAULT_TIMEOUT, "Timeout of each feed parse")
+	junitFile := flags.String("junit", "", "Write a JUnit XML report to the file")
+	jsonFile := flags.String("json", "", "Write a JSON report to the file")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rss-banquet check [options] [feed urls]\n")
+		fmt.Fprintf(os.Stderr, "Checks every module with its sample options, or the given feed urls\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var targets []check.Target
+	if flags.NArg() > 0 {
+		gin.SetMode(gin.ReleaseMode)
+		matcher := parser.NewRouteMatcher(getParsers())
+		for _, u := range flags.Args() {
+			targets = append(targets, check.UrlTarget(matcher, u))
+		}
+	} else {
+		for name, module := range parser.GetModules() {
+			targets = append(targets, check.ModuleTarget(name, module()))
+		}
+	}
+
+	report := check.Run(targets, *concurrency, *timeout)
+	report.WriteSummary(os.Stdout)
+	writeReport(*junitFile, report.WriteJUnit)
+	writeReport(*jsonFile, report.WriteJSON)
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
 func readMe(usage func()) {
 	var serverFlags runServerFlags
 
@@ -210,13 +456,63 @@ A Modular Atom/RSS Feed Generator
 ` + "```")
 	sf.Usage()
 	fmt.Print("```\n\n")
+	fmt.Print("The server listens on the `-p` port, or on the `BANQUET_SERVER_UNIX_SOCKET` Unix socket, and serves HTTPS when `BANQUET_SERVER_TLS_CERT_FILE` and `BANQUET_SERVER_TLS_KEY_FILE` are set. Slow clients are bounded by the `READ_HEADER_TIMEOUT`, `READ_TIMEOUT`, `WRITE_TIMEOUT` (streams excepted) and `IDLE_TIMEOUT` options. On SIGTERM or SIGINT, the server stops accepting connections, ends the streams and waits up to `SHUTDOWN_TIMEOUT` for the in-flight requests, then stops the background polls and lets the modules persist their caches in `BANQUET_GLOBAL_CACHE_DIR` (such as the goodreads book details, restored on the next start).\n\nEach module keeps a session across parses: the cookies set by its upstreams (such as the Google consent cookie of books) and its tokens (such as the nytimes `nyt-token`, refreshed only when an upstream rejects it with a 401 or 403), shared by its concurrent requests and persisted in `BANQUET_GLOBAL_CACHE_DIR`.\n\n")
+	fmt.Print("### Configuration file\n\n")
+	fmt.Print("The optional YAML `BANQUET_GLOBAL_CONFIG_FILE` (see config.sample.yaml) can set the options above in its `settings` section (by name, eg `LOG_LEVEL: debug`, environment variables taking precedence), and disable modules or override the defaults of their options in its `modules` section (by module name as in the feed URLs, eg `hackerone: {defaults: {reports_count: \"50\"}}` or `costco: {enabled: false}`). Disabled modules are hidden from the API, and their feeds return a 404.\n\n")
+	fmt.Print("Some modules also have settings, such as the Docker Hub credentials, set in their `settings` section (`<NAME>_FILE` reading a setting from a file) or with `BANQUET_MODULE_<MODULE>_<NAME>` variables, as are the defaults of their options (eg `BANQUET_MODULE_GOODREADS_LANGUAGE`). The settings are listed with the modules below and on `/api/help/<module>`, secrets being redacted.\n\n")
+	fmt.Print("The server reloads the file on SIGHUP, and when it changes (checked every `BANQUET_SERVER_CONFIG_WATCH_INTERVAL`). The new config is fully validated before being applied, an invalid config being logged and rejected while the current one keeps running. Settings, modules, access, rate limits and upstreams are swapped at once without dropping the in-flight requests, the feed cache is cleared, and the changed options and config file entries are logged, secrets redacted. Options such as the listen address, timeouts, state files and the notifier section only apply on restart, which is logged as a warning.\n\n")
 	fmt.Print("### Oneshot mode\n\nUsage: `rss-banquet oneshot <module> [module options]`\n\n")
+	fmt.Print("### Check mode\n\n")
+	fmt.Print("Usage: `rss-banquet check [-concurrency 4] [-timeout 1m] [-junit report.xml] [-json report.json] [feed urls]` parses every module with its sample options (or its defaults, modules having required options without samples being skipped), or the given feed URLs, and checks the feeds against basic sanity rules: feed title, at least one item, item titles, links and dates, unique guids and successful rendering in every format. A summary is printed, along with optional JUnit XML and JSON reports, and the command exits with a non-zero status when a check failed.\n\n")
+	fmt.Print("### OPML\n\n")
+	fmt.Print("The server exports the feeds listed in the `CONFIG_FILE` (except `private` ones) along with an example URL per module as OPML on `/api/opml`, grouped by module. Examples can be left out with `?examples=false`.\n\n")
+	fmt.Print("Usage: `rss-banquet import <file.opml>` resolves the rss-banquet URLs of an OPML file through the server routes and prints them as a config file `feeds` list, reporting the feeds whose module or options no longer exist.\n\n")
+	fmt.Print("### Notifications\n\n")
+	fmt.Print("When the `CONFIG_FILE` has a `notifier` section, the feeds listing `notify` targets are parsed every `interval` (default: 15m) and their new items are sent to the targets, both in server mode and with `rss-banquet notify [-once]`. The items already seen are stored in `state_file` (default: notifier-state.json), so the first poll of a feed doesn't notify anything.\n\n")
+	fmt.Print("Target types are `webhook` (JSON body, or the Go `template` rendered with the item, a `json` function being available for escaping), `slack`, `discord`, `matrix` (`url` being the room `send/m.room.message` endpoint), `ntfy` (`url` being the topic) and `gotify`. `token` is sent as a bearer token (or Gotify application token), `headers` are added to the requests. Failed deliveries are retried `retries` times (default: 3) with an exponential backoff starting at `retry_delay`, then appended to the `dead_letter_file`.\n\n")
+	fmt.Print("### WebSub\n\n")
+	fmt.Print("With `BANQUET_SERVER_WEBSUB=true`, the server acts as the WebSub hub of its feeds: they advertise `hub` and `self` links, subscribers can subscribe to any feed URL on `/websub/hub`, and the subscribed feeds are parsed every `WEBSUB_INTERVAL` to push their new items, signed with `X-Hub-Signature` when a `hub.secret` was given. Subscriptions are stored in `WEBSUB_STATE_FILE`, their topic stripped of its `token` query parameter, and must be renewed before their lease expires. On shutdown, the pending subscription verifications complete before the server stops.\n\n")
+	fmt.Print("### Streaming\n\n")
+	fmt.Print("Any feed URL can be streamed as Server-Sent Events by prefixing its path with `/stream`, eg `/stream/feed/dockerhub/nbr23/rss-banquet:latest`. While being streamed, the feed is parsed every `STREAM_INTERVAL` and each new item is sent as an `item` event holding its JSON Feed representation. The recent events are kept in `STREAM_STATE_FILE` so that clients can resume with the `Last-Event-ID` header (or `lastEventId` query parameter), until the last client of the feed disconnects (the server restarts excepted). Up to 100 feeds are streamed at once, and query parameters that are neither options nor filters are rejected. Heartbeat comments are sent every 30 seconds.\n\n")
+	fmt.Print("### Access control\n\n")
+	fmt.Print("When the `CONFIG_FILE` has an `access` section, modules can be made token-only: `modules` maps module names to `public` or `token`, the others getting the `default` access (public unless set). With `api: token`, the API and help endpoints and the generator UI require a token too (`/api/healthcheck`, `/metrics` and the feed stylesheets remaining open). `tokens` lists the tokens, each with a `name`, its secret `token` and its `scopes`: `feeds` for every feed, `module:<name>` for the feeds of a module, `module:<name>?<option>=<value>` for the feeds of a module with these option values, and `api` for the API endpoints.\n\n")
+	fmt.Print("Tokens are read from an `Authorization: Bearer` header, a basic auth password, or a `token` query parameter (for feed readers without authentication support). Requests without a valid token get a 401, requests with a token missing the scope a 403. WebSub subscriptions and streams are checked against their feed. The token name is added to the request logs.\n\n")
+	fmt.Print("### Caching and rate limiting\n\n")
+	fmt.Print("With `BANQUET_SERVER_FEED_CACHE_TTL` set (eg `10m`), the served feeds are cached in memory, the responses telling cache hits from misses in their `X-Banquet-Cache` header.\n\n")
+	fmt.Print("`BANQUET_SERVER_RATE_LIMIT` limits the feed requests of each client (its access token, or its IP) per module, and `BANQUET_SERVER_RATE_LIMIT_MODULE` the requests of all the clients per module, as `<requests>/<duration>` (eg `60/1h`). Expensive modules (goodreads, googlebooks, garmin-wearables) are limited to `30/1h` per client by default. The `rate_limits` section of the `CONFIG_FILE` overrides the `client` and `module` limits of specific modules, `none` disabling them. Requests over the limits get a 429 with a `Retry-After` header, feeds served from the cache being exempt, and are counted in `banquet_rate_limited_requests_total`.\n\n")
+	fmt.Print("### Upstream politeness\n\n")
+	fmt.Print("The upstream requests are queued to honor per host limits: at most `BANQUET_GLOBAL_HOST_CONCURRENCY` concurrent requests, started at least `BANQUET_GLOBAL_HOST_DELAY` apart. Modules hammering the same hosts have stricter defaults (goodreads, garmin-wearables). The `upstreams` section of the `CONFIG_FILE` overrides the `concurrency` and `delay` of `hosts` (exact names or `*.domain` patterns) and of `modules`, the module limits applying on top of the limits of the host shared by every module. A request holds its slot until its response body is read. With `BANQUET_GLOBAL_ROBOTS_TXT=true`, the `Crawl-delay` of the hosts `robots.txt` is honored too. The time spent queued is logged with the upstream requests and shown by the debug endpoint.\n\n")
+	fmt.Print("### Proxies\n\n")
+	fmt.Print("The upstream requests can be sent through HTTP(S) or SOCKS5 proxies, as `http://`, `https://` or `socks5://` URLs with optional `user:password@` credentials: `BANQUET_GLOBAL_PROXY` for every request (the standard `HTTP_PROXY` and `HTTPS_PROXY` variables being used otherwise), the `PROXY` setting of a module (eg `BANQUET_MODULE_COSTCO_PROXY`, or `PROXY_FILE` in its config file `settings` to keep the credentials in a secret) for its requests, and the `proxies` of the `upstreams` section of the `CONFIG_FILE` for host patterns (exact names or `*.domain`), in increasing precedence. `direct` bypasses the proxies. The proxy used, credentials redacted, is logged with the upstream requests and shown by the debug endpoint.\n\n")
+	fmt.Print("### Logging\n\n")
+	fmt.Print("Logs are written to stderr, as JSON lines with `BANQUET_GLOBAL_LOG_FORMAT=json`. Each request gets a request ID, taken from its `X-Request-ID` header or generated, and returned in the `X-Request-ID` response header. The request ID, module and feed are added to the logs of the feed parses, including the upstream requests (logged with their URL, status and duration at the debug level).\n\n")
+	fmt.Print("### Tracing\n\n")
+	fmt.Print("With `BANQUET_GLOBAL_TRACING_EXPORTER=otlp` (configured with the standard `OTEL_EXPORTER_OTLP_ENDPOINT` and related variables) or `stdout`, OpenTelemetry traces are recorded with a span per incoming request (continuing the caller `traceparent`), a child span per module parse carrying the module and its options, and spans for every upstream HTTP request and HTML/JSON decoding. The trace ID is added to the request logs.\n\n")
+	fmt.Print("### Debugging\n\n")
+	fmt.Print("When `BANQUET_SERVER_DEBUG_TOKEN` is set, `/api/debug/feed/<module>/...` (taking the same path and query as `/feed/<module>/...`, plus the token in an `Authorization: Bearer` header or a `token` query parameter) parses the feed and returns, as JSON, the parsed feed, the parse duration and error, the warnings logged while parsing, and every upstream request made: URL, status, timing, headers (credentials redacted) and the beginning of the response body, except for the responses carrying credentials such as login tokens. In oneshot mode, `-debug` prints the same report to stderr.\n\n")
+	fmt.Print("### Metrics\n\n")
+	fmt.Print("Prometheus metrics are exposed on `/metrics`: requests count and latency by module (previews included) and status (`banquet_http_requests_total`, `banquet_http_request_duration_seconds`), parse duration and item counts by module (`banquet_parse_duration_seconds`, `banquet_parse_items`), item count and time of the last successful parse of each served feed, labelled by module and by feed path and options like the breakage baselines below (`banquet_feed_items`, `banquet_feed_last_success_timestamp_seconds`), upstream requests by host and status (`banquet_upstream_requests_total`, `banquet_upstream_request_duration_seconds`) and cache lookups (`banquet_cache_requests_total`).\n\n")
+	fmt.Print("### Health\n\n")
+	fmt.Print("`/api/health/modules` reports the outcome of the last parse of each module served by the instance (status, latency, item count and error class). With `?canary=true`, each module is also parsed with its sample options (or its defaults), modules having required options without samples being `skipped`. `?module=<name>` restricts the report to a single module. The aggregate `status` is `ok`, `degraded` when some modules are failing, or `down` (with a 503 status code) when all the reported modules are failing. Served feeds failing on not found or invalid options are client errors, not module failures.\n\n")
+	fmt.Print("### API schemas\n\n")
+	fmt.Print("`/api/openapi.json` is an OpenAPI 3.1 document describing the feed route of every enabled module: path and query parameters with their types, defaults and accepted values (the other values being rejected with a 400), and the response media types. `/api/config.schema.json` is the JSON Schema of the `CONFIG_FILE`, checking the feeds options against their module, for editors supporting YAML validation (eg with a `# yaml-language-server: $schema=<url>` comment). Both are also printed by `rss-banquet schema [-openapi]`.\n\n")
+	fmt.Print("### Feed URL generator\n\n")
+	fmt.Print("The server home page is a feed URL generator: pick a module, fill its options (checked against their types and accepted values), then copy the feed URL in any format, or the matching `CONFIG_FILE` entry. The feed is previewed live through `/api/preview/feed/...`, which parses any feed path as `/feed/...` would and returns its first items as JSON, with the parse duration, warnings and error. The previewed feeds are cached and rate limited as the served ones, and compared with their breakage baseline, but left out of the modules health and of the baselines and feed metrics.\n\n")
+	fmt.Print("### Breakage detection\n\n")
+	fmt.Print("The served feeds are compared, before their filters, with a baseline of their previous parses (item count, share of items having a date and a link, kept for the 1000 most recently served feeds and saved every minute and on shutdown to `BANQUET_SERVER_BASELINES_FILE`). A feed suddenly returning no items, or items all missing their dates or links, is likely a scraper broken by an upstream layout change: it is served with an `X-Banquet-Warning` header, its module is reported as `broken` by `/api/health/modules`, and the `banquet_feed_anomaly` metric of the feed is set. The feeds dropped from the baselines are dropped from these metrics too. With `BANQUET_SERVER_BREAKAGE_ITEM=true`, an item describing the anomaly is also added to the feed, once a day.\n\n")
+	fmt.Print("### Feed filters\n\n")
+	fmt.Print("Items exposing structured attributes (listed in the `_banquet` object of the JSON output) can be filtered on any feed route with `attr.<name>` query parameters, eg `?attr.severity=critical` or `?attr.price<50`. Supported operators are `=`, `!=`, `<`, `<=`, `>`, `>=`.\n\n")
+	fmt.Print("Items can be filtered on their categories with the `category` query parameter, eg `?category=xss,ssrf` keeps the items in any of the listed categories. The `categories` parameter is the same filter, available on every module: on the modules having their own `category` option (currently lego, whose `category` selects the products listed), `category` sets that option instead of filtering the items, the values it doesn't accept being rejected, and the items are filtered with `categories`. The OpenAPI spec describes the `category` parameter of each module accordingly.\n\n")
 	fmt.Print("\n## Modules available:\n\n")
 	printModulesHelp()
 }
 
 func initLogging() {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	if config.GetConfigOption("LOG_FORMAT") == "json" {
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+	} else {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	}
 	logLevel := config.GetConfigOption("LOG_LEVEL")
 	level, err := zerolog.ParseLevel(logLevel)
 	if err != nil {
@@ -228,13 +524,27 @@ func initLogging() {
 
 func main() {
 	config.InitConfig()
+	c, err := loadConfig()
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	config.Publish(c)
 	initLogging()
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	defer shutdownTracing(context.Background())
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Commands:\n")
 		fmt.Fprintf(os.Stderr, "  server: run rss-banquet in server mode\n")
 		fmt.Fprintf(os.Stderr, "  oneshot: run rss-banquet in oneshot mode to fetch a specific module's results\n")
+		fmt.Fprintf(os.Stderr, "  notify: send the new items of the configured feeds to the notifier targets\n")
+		fmt.Fprintf(os.Stderr, "  check: check that the modules, or the given feed urls, return sane feeds\n")
+		fmt.Fprintf(os.Stderr, "  import: resolve the rss-banquet feeds of an OPML file into a config file feeds list\n")
+		fmt.Fprintf(os.Stderr, "  schema: print the JSON Schema of the config file, or the OpenAPI document of the feeds\n")
 	}
 	flag.Parse()
 	if flag.NArg() < 1 {
@@ -247,6 +557,14 @@ func main() {
 		runServer(os.Args[2:])
 	case "oneshot":
 		runOneShot(os.Args[2:])
+	case "notify":
+		runNotify(os.Args[2:])
+	case "check":
+		runCheck(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "schema":
+		runSchema(os.Args[2:])
 	case "readme":
 		readMe(flag.Usage)
 	default:
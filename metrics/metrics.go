@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const NAMESPACE = "banquet"
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by module and status code",
+	}, []string{"module", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP requests latency, by module and status code",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"module", "status"})
+
+	parseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "parse_duration_seconds",
+		Help:      "Feed parse duration, by module and outcome",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"module", "outcome"})
+
+	parseItems = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "parse_items",
+		Help:      "Number of items of the parsed feeds, by module",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250},
+	}, []string{"module"})
+
+	feedItems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "feed_items",
+		Help:      "Number of items of the last successful parse of the served feeds, by module and feed",
+	}, []string{"module", "feed"})
+
+	feedLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "feed_last_success_timestamp_seconds",
+		Help:      "Time of the last successful parse of the served feeds, by module and feed",
+	}, []string{"module", "feed"})
+
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "upstream_requests_total",
+		Help:      "HTTP requests made to upstream hosts, by host and status code",
+	}, []string{"host", "status"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "HTTP requests made to upstream hosts latency, by host",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, by cache and result (hit or miss)",
+	}, []string{"cache", "result"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected by the rate limits, by module and limit (client or module)",
+	}, []string{"module", "limit"})
+)
+
+func ObserveRequest(module string, status int, duration time.Duration) {
+	s := strconv.Itoa(status)
+	requestsTotal.WithLabelValues(module, s).Inc()
+	requestDuration.WithLabelValues(module, s).Observe(duration.Seconds())
+}
+
+func ObserveParse(module string, duration time.Duration, items int, err error) {
+	if err != nil {
+		parseDuration.WithLabelValues(module, "error").Observe(duration.Seconds())
+		return
+	}
+	parseDuration.WithLabelValues(module, "success").Observe(duration.Seconds())
+	parseItems.WithLabelValues(module).Observe(float64(items))
+}
+
+// ObserveFeed records the successful parse of a served feed. The feed URLs
+// are chosen by the clients: feed is a key whose number is bounded, such as
+// the breakage baseline key, the series of the feeds dropped from it being
+// removed with ForgetFeed.
+func ObserveFeed(module string, feed string, items int) {
+	feedItems.WithLabelValues(module, feed).Set(float64(items))
+	feedLastSuccess.WithLabelValues(module, feed).SetToCurrentTime()
+}
+
+// ForgetFeed removes the series of a feed no longer tracked
+func ForgetFeed(feed string) {
+	labels := prometheus.Labels{"feed": feed}
+	feedItems.DeletePartialMatch(labels)
+	feedLastSuccess.DeletePartialMatch(labels)
+	feedAnomaly.DeletePartialMatch(labels)
+}
+
+// ObserveUpstream records a request to an upstream host, status being 0 for network errors
+func ObserveUpstream(host string, status int, duration time.Duration) {
+	s := "error"
+	if status > 0 {
+		s = strconv.Itoa(status)
+	}
+	upstreamRequests.WithLabelValues(host, s).Inc()
+	upstreamDuration.WithLabelValues(host).Observe(duration.Seconds())
+}
+
+func CacheHit(cache string) {
+	cacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+func CacheMiss(cache string) {
+	cacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+func RateLimited(module string, limit string) {
+	rateLimited.WithLabelValues(module, limit).Inc()
+}
+
+// MODULE_KEY is the gin context key of the module of the requests served
+// outside of its feed routes, such as the previews
+const MODULE_KEY = "metricsModule"
+
+// getModule returns the module serving the route, or none for the other endpoints
+func getModule(route string) string {
+	route = strings.TrimPrefix(route, "/stream")
+	if !strings.HasPrefix(route, "/feed/") {
+		return "none"
+	}
+	module, _, _ := strings.Cut(strings.TrimPrefix(route, "/feed/"), "/")
+	return module
+}
+
+// Middleware records the requests count and latency
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		module := c.GetString(MODULE_KEY)
+		if module == "" {
+			module = getModule(c.FullPath())
+		}
+		ObserveRequest(module, c.Writer.Status(), time.Since(start))
+	}
+}
+
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+var feedAnomaly = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: NAMESPACE,
+	Name:      "feed_anomaly",
+	Help:      "Whether the last parse of the served feed shows a possible scraper breakage, by module, feed and kind of anomaly",
+}, []string{"module", "feed", "kind"})
+
+func ObserveFeedAnomalies(module string, feed string, kinds []string, anomalies []string) {
+	for _, kind := range kinds {
+		v := 0.0
+		if slices.Contains(anomalies, kind) {
+			v = 1
+		}
+		feedAnomaly.WithLabelValues(module, feed, kind).Set(v)
+	}
+}
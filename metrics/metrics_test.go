@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// getSeries returns the values of the series of a metric, by their comma separated label values
+func getSeries(t *testing.T, name string) map[string]float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != NAMESPACE+"_"+name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var labels []string
+			for _, label := range m.GetLabel() {
+				labels = append(labels, label.GetValue())
+			}
+			series[strings.Join(labels, ",")] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return series
+}
+
+func TestGetModule(t *testing.T) {
+	tests := map[string]string{
+		"/feed/dockerhub/*image":           "dockerhub",
+		"/feed/bugcrowd":                   "bugcrowd",
+		"/stream/feed/psupdates/:hardware": "psupdates",
+		"/api/healthcheck":                 "none",
+		"":                                 "none",
+	}
+	for route, want := range tests {
+		if got := getModule(route); got != want {
+			t.Errorf("getModule(%q) = %s, want %s", route, got, want)
+		}
+	}
+}
+
+func TestForgetFeed(t *testing.T) {
+	ObserveFeed("metricstest", "/feed/metricstest/a", 3)
+	ObserveFeed("metricstest", "/feed/metricstest/b", 0)
+	ObserveFeedAnomalies("metricstest", "/feed/metricstest/b", []string{"no_items"}, []string{"no_items"})
+	items := getSeries(t, "feed_items")
+	if items["/feed/metricstest/a,metricstest"] != 3 || items["/feed/metricstest/b,metricstest"] != 0 || len(items) != 2 {
+		t.Errorf("the feeds of a module should have their own series: %v", items)
+	}
+
+	ForgetFeed("/feed/metricstest/b")
+	if items := getSeries(t, "feed_items"); len(items) != 1 {
+		t.Errorf("unexpected feed_items series %v", items)
+	}
+	if anomalies := getSeries(t, "feed_anomaly"); len(anomalies) != 0 {
+		t.Errorf("unexpected feed_anomaly series %v", anomalies)
+	}
+}
+
+func TestMiddlewareModuleKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/api/preview/feed/*path", func(c *gin.Context) {
+		c.Set(MODULE_KEY, "metricspreview")
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/preview/feed/lego", nil))
+	if got := getSeries(t, "http_requests_total")["metricspreview,200"]; got != 1 {
+		t.Errorf("the preview should be recorded under its module, got %v", got)
+	}
+}
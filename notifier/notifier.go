@@ -0,0 +1,268 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/rs/zerolog/log"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+const DEFAULT_INTERVAL = 15 * time.Minute
+const DEFAULT_RETRY_DELAY = 2 * time.Second
+const DEFAULT_STATE_FILE = "notifier-state.json"
+
+// SEEN_GUIDS_MAX bounds the number of guids remembered per feed
+const SEEN_GUIDS_MAX = 1000
+
+type Notification struct {
+	Feed        string    `json:"feed"`
+	Module      string    `json:"module"`
+	Id          string    `json:"id"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+type deadLetter struct {
+	Time         time.Time     `json:"time"`
+	Target       string        `json:"target"`
+	Error        string        `json:"error"`
+	Notification *Notification `json:"notification"`
+}
+
+type watchedFeed struct {
+	config  config.FeedConfig
+	parser  parser.Parser
+	targets []*Target
+}
+
+type Notifier struct {
+	feeds          []*watchedFeed
+	interval       time.Duration
+	retryDelay     time.Duration
+	stateFile      string
+	deadLetterFile string
+
+	mu   sync.Mutex
+	seen map[string][]string
+}
+
+func feedKey(f config.FeedConfig) string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Module
+}
+
+// New builds a notifier watching the feeds having notify targets, getModule resolving module names to parsers
+func New(c *config.NotifierConfig, feeds []config.FeedConfig, getModule func(string) parser.Parser) (*Notifier, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	targets := make(map[string]*Target)
+	for _, tc := range c.Targets {
+		t, err := NewTarget(tc, client)
+		if err != nil {
+			return nil, err
+		}
+		targets[tc.Name] = t
+	}
+
+	n := &Notifier{
+		interval:       c.Interval,
+		retryDelay:     c.RetryDelay,
+		stateFile:      c.StateFile,
+		deadLetterFile: c.DeadLetterFile,
+		seen:           make(map[string][]string),
+	}
+	if n.interval <= 0 {
+		n.interval = DEFAULT_INTERVAL
+	}
+	if n.retryDelay <= 0 {
+		n.retryDelay = DEFAULT_RETRY_DELAY
+	}
+	if n.stateFile == "" {
+		n.stateFile = DEFAULT_STATE_FILE
+	}
+
+	for _, f := range feeds {
+		if len(f.Notify) == 0 {
+			continue
+		}
+		p := getModule(f.Module)
+		if p == nil {
+			return nil, fmt.Errorf("feed %s: unknown module %s", feedKey(f), f.Module)
+		}
+		if _, err := parser.NewOptions(p, f.GetOptions()); err != nil {
+			return nil, fmt.Errorf("feed %s: %w", feedKey(f), err)
+		}
+		w := &watchedFeed{config: f, parser: p}
+		for _, name := range f.Notify {
+			t, ok := targets[name]
+			if !ok {
+				return nil, fmt.Errorf("feed %s: unknown target %s", feedKey(f), name)
+			}
+			w.targets = append(w.targets, t)
+		}
+		n.feeds = append(n.feeds, w)
+	}
+
+	if err := utils.ReadJSONFile(n.stateFile, &n.seen); err != nil {
+		return nil, fmt.Errorf("unable to read notifier state %s: %w", n.stateFile, err)
+	}
+	return n, nil
+}
+
+// Run polls the feeds every interval until the context is done
+func (n *Notifier) Run(ctx context.Context) {
+	log.Info().Msgf("notifier watching %d feed(s) every %s", len(n.feeds), n.interval)
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		n.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll parses every watched feed once and sends its new items
+func (n *Notifier) Poll(ctx context.Context) {
+	for _, f := range n.feeds {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := n.pollFeed(ctx, f); err != nil {
+			log.Error().Msgf("notifier: feed %s: %s", feedKey(f.config), err)
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := utils.WriteJSONFile(n.stateFile, n.seen); err != nil {
+		log.Error().Msgf("notifier: unable to save state %s: %s", n.stateFile, err)
+	}
+}
+
+func newNotification(f config.FeedConfig, item *feeds.Item) *Notification {
+	n := &Notification{
+		Feed:        feedKey(f),
+		Module:      f.Module,
+		Id:          parser.GetItemGuid(item),
+		Title:       item.Title,
+		Description: item.Description,
+		Created:     item.Created,
+	}
+	if item.Link != nil {
+		n.Link = item.Link.Href
+	}
+	if item.Author != nil {
+		n.Author = item.Author.Name
+	}
+	return n
+}
+
+func (n *Notifier) pollFeed(ctx context.Context, f *watchedFeed) error {
+	o, err := parser.NewOptions(f.parser, f.config.GetOptions())
+	if err != nil {
+		return err
+	}
+	key := feedKey(f.config)
+	// parsed like the served feeds, with the module session, proxy and politeness
+	o.SetContext(parser.WithLogFields(ctx, map[string]string{"feed": key}))
+	feed, err := parser.ParseFeed(f.parser, o, nil)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	seen, known := n.seen[key]
+	n.mu.Unlock()
+
+	newItems, seen := parser.GetNewItems(feed, seen, SEEN_GUIDS_MAX)
+
+	// the first poll of a feed only records its current items
+	if known {
+		for _, item := range newItems {
+			notification := newNotification(f.config, item)
+			for _, t := range f.targets {
+				n.deliver(ctx, t, notification)
+			}
+		}
+		if len(newItems) > 0 {
+			log.Info().Msgf("notifier: feed %s: %d new item(s)", key, len(newItems))
+		}
+	}
+
+	n.mu.Lock()
+	n.seen[key] = seen
+	n.mu.Unlock()
+	return nil
+}
+
+// deliver sends the notification with retries, recording it in the dead letter log on failure
+func (n *Notifier) deliver(ctx context.Context, t *Target, notification *Notification) {
+	delay := n.retryDelay
+	var err error
+	for attempt := 0; attempt <= t.Retries(); attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-time.After(delay):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			delay *= 2
+		}
+		err = t.Send(ctx, notification)
+		if err == nil {
+			return
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			break
+		}
+		log.Warn().Msgf("notifier: target %s: attempt %d failed: %s", t.Name(), attempt+1, err)
+	}
+	log.Error().Msgf("notifier: target %s: unable to deliver %s: %s", t.Name(), notification.Id, err)
+	n.writeDeadLetter(t, notification, err)
+}
+
+func (n *Notifier) writeDeadLetter(t *Target, notification *Notification, err error) {
+	if n.deadLetterFile == "" {
+		return
+	}
+	line, jsonErr := json.Marshal(deadLetter{
+		Time:         time.Now(),
+		Target:       t.Name(),
+		Error:        err.Error(),
+		Notification: notification,
+	})
+	if jsonErr != nil {
+		log.Error().Msgf("notifier: unable to encode dead letter: %s", jsonErr)
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, fileErr := os.OpenFile(n.deadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if fileErr != nil {
+		log.Error().Msgf("notifier: unable to open dead letter log: %s", fileErr)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
@@ -0,0 +1,157 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	items []*feeds.Item
+	ctx   context.Context
+}
+
+func (p *testParser) Parse(o *parser.Options) (*feeds.Feed, error) {
+	p.ctx = o.Context()
+	return &feeds.Feed{Title: "test", Items: p.items}, nil
+}
+func (p *testParser) String() string { return "test" }
+func (p *testParser) GetOptions() parser.Options {
+	return parser.Options{OptionsList: parser.OptionsList{{Flag: "id", Type: "string"}}, Parser: p}
+}
+func (p *testParser) Validate(o *parser.Options) error {
+	if o.Get("id") == "invalid" {
+		return fmt.Errorf("invalid id")
+	}
+	return nil
+}
+
+func newItem(id string) *feeds.Item {
+	return &feeds.Item{Id: id, Title: "title " + id, Link: &feeds.Link{Href: "https://example.com/" + id}, Created: time.Now()}
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []string
+	status   int
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path+" "+strings.TrimSpace(string(body)))
+	w.WriteHeader(r.status)
+}
+
+func TestNotifier(t *testing.T) {
+	ok := &recorder{status: 200}
+	okServer := httptest.NewServer(ok)
+	defer okServer.Close()
+	failing := &recorder{status: 500}
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+
+	dir := t.TempDir()
+	retries := 2
+	c := &config.NotifierConfig{
+		StateFile:      filepath.Join(dir, "state.json"),
+		DeadLetterFile: filepath.Join(dir, "dead.jsonl"),
+		RetryDelay:     time.Millisecond,
+		Targets: []config.TargetConfig{
+			{Name: "hook", Type: "webhook", Url: okServer.URL + "/hook", Template: `{"text": {{json .Title}}, "feed": "{{.Feed}}"}`},
+			{Name: "chat", Type: "slack", Url: okServer.URL + "/slack"},
+			{Name: "down", Type: "discord", Url: failingServer.URL, Retries: &retries},
+		},
+	}
+	p := &testParser{items: []*feeds.Item{newItem("1")}}
+	feedsConfig := []config.FeedConfig{
+		{Name: "watched", Module: "test", Notify: []string{"hook", "chat", "down"}},
+		{Name: "ignored", Module: "test"},
+	}
+	getModule := func(string) parser.Parser { return p }
+
+	n, err := New(c, feedsConfig, getModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Poll(context.Background())
+	if len(ok.requests) != 0 {
+		t.Fatalf("first poll should not notify, got %v", ok.requests)
+	}
+
+	p.items = append([]*feeds.Item{newItem("2")}, p.items...)
+	// a new notifier reads the state persisted by the previous one
+	n, err = New(c, feedsConfig, getModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Poll(context.Background())
+
+	want := []string{
+		`POST /hook {"text": "title 2", "feed": "watched"}`,
+		`POST /slack {"text":"*watched*: <https://example.com/2|title 2>"}`,
+	}
+	if strings.Join(ok.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected requests:\n%s", strings.Join(ok.requests, "\n"))
+	}
+	if len(failing.requests) != retries+1 {
+		t.Errorf("expected %d attempts, got %d", retries+1, len(failing.requests))
+	}
+	dead, err := os.ReadFile(c.DeadLetterFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(dead), `"target":"down"`) || strings.Count(string(dead), "\n") != 1 {
+		t.Errorf("unexpected dead letter log: %s", dead)
+	}
+
+	n.Poll(context.Background())
+	if len(ok.requests) != 2 {
+		t.Errorf("items should only be notified once, got %d requests", len(ok.requests))
+	}
+}
+
+func TestNewTargetErrors(t *testing.T) {
+	if _, err := NewTarget(config.TargetConfig{Name: "x", Type: "irc", Url: "http://localhost"}, http.DefaultClient); err == nil {
+		t.Errorf("NewTarget() should fail for unknown types")
+	}
+	if _, err := NewTarget(config.TargetConfig{Name: "x", Type: "webhook", Url: "http://localhost", Template: "{{"}, http.DefaultClient); err == nil {
+		t.Errorf("NewTarget() should fail for invalid templates")
+	}
+}
+
+func TestPollFeedPipeline(t *testing.T) {
+	p := &testParser{items: []*feeds.Item{newItem("1")}}
+	c := &config.NotifierConfig{StateFile: filepath.Join(t.TempDir(), "state.json")}
+	n, err := New(c, nil, func(string) parser.Parser { return p })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := n.pollFeed(ctx, &watchedFeed{config: config.FeedConfig{Name: "watched", Module: "test"}, parser: p}); err != nil {
+		t.Fatal(err)
+	}
+	if p.ctx == nil || p.ctx.Err() == nil {
+		t.Errorf("the parse should run with the notifier context")
+	}
+	invalid := config.FeedConfig{Name: "invalid", Module: "test", Options: map[string]any{"id": "invalid"}}
+	if err := n.pollFeed(context.Background(), &watchedFeed{config: invalid, parser: p}); err == nil {
+		t.Errorf("the options should be validated")
+	}
+}
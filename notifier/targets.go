@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const DEFAULT_TARGET_RETRIES = 3
+
+var TARGET_TYPES = []string{"webhook", "slack", "discord", "matrix", "ntfy", "gotify"}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+type Target struct {
+	config   config.TargetConfig
+	template *template.Template
+	client   *http.Client
+}
+
+// permanentError is returned for deliveries that won't succeed on retry
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func NewTarget(c config.TargetConfig, client *http.Client) (*Target, error) {
+	t := &Target{config: c, client: client}
+	switch c.Type {
+	case "webhook":
+		if c.Template != "" {
+			tmpl, err := template.New(c.Name).Funcs(templateFuncs).Parse(c.Template)
+			if err != nil {
+				return nil, fmt.Errorf("target %s: invalid template: %w", c.Name, err)
+			}
+			t.template = tmpl
+		}
+	case "slack", "discord", "matrix", "ntfy", "gotify":
+	default:
+		return nil, fmt.Errorf("target %s: unknown type %s, expected one of %s", c.Name, c.Type, strings.Join(TARGET_TYPES, ", "))
+	}
+	return t, nil
+}
+
+func (t *Target) Name() string {
+	return t.config.Name
+}
+
+func (t *Target) Retries() int {
+	if t.config.Retries != nil {
+		return *t.config.Retries
+	}
+	return DEFAULT_TARGET_RETRIES
+}
+
+func jsonBody(v any) (io.Reader, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+func (n *Notification) text() string {
+	if n.Link == "" {
+		return n.Title
+	}
+	return fmt.Sprintf("%s\n%s", n.Title, n.Link)
+}
+
+func (t *Target) request(ctx context.Context, n *Notification) (*http.Request, error) {
+	method := http.MethodPost
+	url := t.config.Url
+	contentType := "application/json"
+	var body io.Reader
+	var err error
+
+	switch t.config.Type {
+	case "webhook":
+		if t.template != nil {
+			var buf bytes.Buffer
+			if err := t.template.Execute(&buf, n); err != nil {
+				return nil, &permanentError{fmt.Errorf("template error: %w", err)}
+			}
+			body = &buf
+		} else {
+			body, err = jsonBody(n)
+		}
+	case "slack":
+		text := n.Title
+		if n.Link != "" {
+			text = fmt.Sprintf("<%s|%s>", n.Link, n.Title)
+		}
+		body, err = jsonBody(map[string]any{"text": fmt.Sprintf("*%s*: %s", n.Feed, text)})
+	case "discord":
+		body, err = jsonBody(map[string]any{"content": fmt.Sprintf("**%s**: %s", n.Feed, n.text())})
+	case "matrix":
+		// the transaction id makes retries idempotent
+		method = http.MethodPut
+		url = fmt.Sprintf("%s/%s", strings.TrimSuffix(url, "/"), parser.GetGuid([]string{n.Feed, n.Id}))
+		formatted := n.Title
+		if n.Link != "" {
+			formatted = fmt.Sprintf(`<a href="%s">%s</a>`, template.HTMLEscapeString(n.Link), template.HTMLEscapeString(n.Title))
+		}
+		body, err = jsonBody(map[string]any{
+			"msgtype":        "m.text",
+			"body":           fmt.Sprintf("%s: %s", n.Feed, n.text()),
+			"format":         "org.matrix.custom.html",
+			"formatted_body": fmt.Sprintf("<b>%s</b>: %s", template.HTMLEscapeString(n.Feed), formatted),
+		})
+	case "ntfy":
+		contentType = "text/plain; charset=utf-8"
+		body = strings.NewReader(n.text())
+	case "gotify":
+		url = fmt.Sprintf("%s/message", strings.TrimSuffix(url, "/"))
+		message := map[string]any{
+			"title":    fmt.Sprintf("%s: %s", n.Feed, n.Title),
+			"message":  n.text(),
+			"priority": 5,
+		}
+		if n.Link != "" {
+			message["extras"] = map[string]any{
+				"client::notification": map[string]any{"click": map[string]string{"url": n.Link}},
+			}
+		}
+		body, err = jsonBody(message)
+	}
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	switch t.config.Type {
+	case "ntfy":
+		req.Header.Set("Title", mime.QEncoding.Encode("utf-8", fmt.Sprintf("%s: %s", n.Feed, n.Title)))
+		if n.Link != "" {
+			req.Header.Set("Click", n.Link)
+		}
+	case "gotify":
+		if t.config.Token != "" {
+			req.Header.Set("X-Gotify-Key", t.config.Token)
+		}
+	}
+	if t.config.Token != "" && t.config.Type != "gotify" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", t.config.Token))
+	}
+	for k, v := range t.config.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// Send makes a single delivery attempt of the notification
+func (t *Target) Send(ctx context.Context, n *Notification) error {
+	req, err := t.request(ctx, n)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("target %s returned status %d", t.config.Name, resp.StatusCode)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err}
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"runtime/debug"
+	"slices"
+
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const OPENAPI_VERSION = "3.1.0"
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	Url string `json:"url"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type PathItem struct {
+	Get *Operation `json:"get,omitempty"`
+}
+
+type ExternalDocs struct {
+	Url string `json:"url"`
+}
+
+type Operation struct {
+	OperationId  string              `json:"operationId"`
+	Summary      string              `json:"summary,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Tags         []string            `json:"tags,omitempty"`
+	ExternalDocs *ExternalDocs       `json:"externalDocs,omitempty"`
+	Parameters   []Parameter         `json:"parameters,omitempty"`
+	Responses    map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	// Style and Explode describe the comma separated lists
+	Style   string `json:"style,omitempty"`
+	Explode *bool  `json:"explode,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+var routeParameter = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIPath converts a gin route path to an OpenAPI one, eg /feed/x/:id to /feed/x/{id}
+func openAPIPath(path string) string {
+	return routeParameter.ReplaceAllString(path, "{$1}")
+}
+
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func textResponse(description string) Response {
+	return Response{Description: description, Content: map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}}
+}
+
+func parameter(option *parser.Option, in string) Parameter {
+	param := Parameter{Name: option.Flag, In: in, Description: option.Help, Required: in == "path", Schema: OptionSchema(option)}
+	if option.IsPath {
+		param.Description += ", may contain slashes"
+	}
+	if option.Type == "stringSlice" {
+		explode := false
+		param.Style, param.Explode = "form", &explode
+	}
+	return param
+}
+
+func listParameter(name string, description string) Parameter {
+	explode := false
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}, Style: "form", Explode: &explode}
+}
+
+// feedOperation describes the feed route of a module
+func feedOperation(name string, p parser.Parser) (string, *Operation) {
+	o := parser.GetFullOptions(p)
+	info := parser.GetModuleInfo(name, p)
+	op := &Operation{
+		OperationId: name,
+		Summary:     info.Description,
+		Description: fmt.Sprintf("Feed of the %s module. Items can be filtered on their attributes with `%s<name>` query parameters.", name, parser.ATTRIBUTE_FILTER_PREFIX),
+		Responses: map[string]Response{
+			"400": textResponse("Invalid options"),
+			"401": textResponse("Missing or invalid access token"),
+			"404": textResponse("Module disabled, or nothing found"),
+			"429": textResponse("Rate limited"),
+			"500": textResponse("Parse failure"),
+		},
+	}
+	if info.Category != "" {
+		op.Tags = []string{info.Category}
+	}
+	if info.Homepage != "" {
+		op.ExternalDocs = &ExternalDocs{Url: info.Homepage}
+	}
+	for _, option := range o.OptionsList {
+		switch {
+		case option.IsStatic:
+		case option.Required:
+			op.Parameters = append(op.Parameters, parameter(option, "path"))
+		default:
+			param := parameter(option, "query")
+			if option.Flag == "category" {
+				// the generic category filter is left to categories on these modules
+				param.Description += ". Sets the module option rather than filtering the items, use categories to filter them"
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+	op.Parameters = append(op.Parameters, listParameter("categories", "categories of the items kept, comma separated, on every module"))
+	if _, _, err := o.OptionsList.Get("category"); err != nil {
+		op.Parameters = append(op.Parameters, listParameter("category", "alias of categories, on the modules without a category option"))
+	}
+
+	op.Responses["200"] = Response{Description: "The feed, in the feedFormat format", Content: map[string]MediaType{
+		"application/xml":  {Schema: &Schema{Type: "string", Description: "RSS or Atom feed"}},
+		"application/json": {Schema: &Schema{Type: "object", Description: "JSON Feed"}},
+		"text/plain":       {Schema: &Schema{Type: "string"}},
+	}}
+	return openAPIPath(parser.RoutePath(o)), op
+}
+
+// NewDocument describes the feed routes of the enabled modules
+func NewDocument(modules map[string]parser.Parser, serverUrl string) *Document {
+	doc := &Document{
+		OpenAPI: OPENAPI_VERSION,
+		Info: Info{
+			Title:       "rss-banquet",
+			Description: "RSS, Atom and JSON feeds of websites without one",
+			Version:     version(),
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+				"basic":  {Type: "http", Scheme: "basic"},
+				"token":  {Type: "apiKey", In: "query", Name: parser.TOKEN_PARAMETER},
+			},
+		},
+		// the access tokens are only required by the access config
+		Security: []map[string][]string{{}, {"bearer": {}}, {"basic": {}}, {"token": {}}},
+	}
+	if serverUrl != "" {
+		doc.Servers = []Server{{Url: serverUrl}}
+	}
+	tags := make(map[string]bool)
+	for _, name := range slices.Sorted(maps.Keys(modules)) {
+		p := modules[name]
+		if !parser.IsModuleEnabled(p.String()) {
+			continue
+		}
+		path, op := feedOperation(name, p)
+		doc.Paths[path] = PathItem{Get: op}
+		for _, tag := range op.Tags {
+			tags[tag] = true
+		}
+	}
+	for _, tag := range slices.Sorted(maps.Keys(tags)) {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	return doc
+}
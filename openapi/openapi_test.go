@@ -0,0 +1,192 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/parser/bugcrowd"
+	"github.com/nbr23/rss-banquet/parser/costco"
+	"github.com/nbr23/rss-banquet/parser/dockerhub"
+	"github.com/nbr23/rss-banquet/parser/hackerone"
+	"github.com/nbr23/rss-banquet/parser/hackeronePrograms"
+	"github.com/nbr23/rss-banquet/parser/lego"
+	"github.com/nbr23/rss-banquet/parser/psupdates"
+)
+
+var modules = map[string]parser.Parser{
+	"psupdates":         psupdates.PSUpdatesParser(),
+	"bugcrowd":          bugcrowd.BugcrowdParser(),
+	"hackerone":         hackerone.HackeroneParser(),
+	"hackeronePrograms": hackeronePrograms.HackeroneProgramsParser(),
+	"lego":              lego.LegoParser(),
+	"dockerhub":         dockerhub.DockerHubParser(),
+	"costco":            costco.CostcoParser(),
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument(modules, "https://feeds.example.com")
+	item, ok := doc.Paths["/feed/dockerhub/{image}"]
+	if !ok {
+		t.Fatalf("missing dockerhub path: %v", doc.Paths)
+	}
+	params := make(map[string]Parameter)
+	for _, param := range item.Get.Parameters {
+		params[param.Name] = param
+	}
+	if image := params["image"]; image.In != "path" || !image.Required {
+		t.Errorf("image should be a required path parameter: %+v", image)
+	}
+	if format := params["feedFormat"]; format.In != "query" || format.Schema.Default != "rss" || !slices.Contains(format.Schema.Enum, any("atom")) {
+		t.Errorf("unexpected feedFormat parameter: %+v", format.Schema)
+	}
+	if _, ok := params["route"]; ok {
+		t.Errorf("static options shouldn't be parameters")
+	}
+	if _, ok := item.Get.Responses["200"].Content["application/json"]; !ok {
+		t.Errorf("the feeds should be served as JSON")
+	}
+	if _, ok := doc.Paths["/feed/psupdates/{hardware}"]; !ok {
+		t.Errorf("missing psupdates path")
+	}
+	lego := make(map[string]Parameter)
+	for _, param := range doc.Paths["/feed/lego"].Get.Parameters {
+		lego[param.Name] = param
+	}
+	if !strings.Contains(lego["category"].Description, "rather than filtering the items") || lego["categories"].Name == "" {
+		t.Errorf("the category option of lego should point to the categories filter: %+v", lego["category"])
+	}
+	if !strings.Contains(params["category"].Description, "alias of categories") {
+		t.Errorf("category should filter the items of the modules without a category option: %+v", params["category"])
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// validate checks the value against the subset of JSON Schema used by ConfigSchema
+func validate(s *Schema, value any, path string) error {
+	if s.Enum != nil && !slices.Contains(s.Enum, value) {
+		return fmt.Errorf("%s: %v not in %v", path, value, s.Enum)
+	}
+	if s.Const != nil && s.Const != value {
+		return fmt.Errorf("%s: %v is not %v", path, value, s.Const)
+	}
+	types := []string{}
+	switch t := s.Type.(type) {
+	case string:
+		types = append(types, t)
+	case []string:
+		types = t
+	}
+	matches := len(types) == 0
+	for _, t := range types {
+		switch value.(type) {
+		case string:
+			matches = matches || t == "string"
+		case int:
+			matches = matches || t == "integer" || t == "number"
+		case bool:
+			matches = matches || t == "boolean"
+		case []any:
+			matches = matches || t == "array"
+		case map[string]any:
+			matches = matches || t == "object"
+		}
+	}
+	if !matches {
+		return fmt.Errorf("%s: %v is not %v", path, value, s.Type)
+	}
+	if str, ok := value.(string); ok && s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+		return fmt.Errorf("%s: %s doesn't match %s", path, str, s.Pattern)
+	}
+	if items, ok := value.([]any); ok && s.Items != nil {
+		for i, item := range items {
+			if err := validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s: missing %s", path, name)
+		}
+	}
+	for name, v := range object {
+		if s.PropertyNames != nil {
+			if err := validate(s.PropertyNames, name, path+"."+name); err != nil {
+				return err
+			}
+		}
+		property, ok := s.Properties[name]
+		if !ok {
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				return fmt.Errorf("%s: unknown property %s", path, name)
+			case *Schema:
+				property = additional
+			default:
+				continue
+			}
+		}
+		if err := validate(property, v, path+"."+name); err != nil {
+			return err
+		}
+	}
+	for _, sub := range s.AllOf {
+		if sub.If != nil && validate(sub.If, value, path) != nil {
+			continue
+		}
+		if err := validate(sub.Then, value, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestConfigSchema(t *testing.T) {
+	s := ConfigSchema(modules)
+	data, err := os.ReadFile("../config.sample.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sample map[string]any
+	if err := yaml.Unmarshal(data, &sample); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(s, sample, "config"); err != nil {
+		t.Errorf("the sample config should be valid: %s", err)
+	}
+
+	for _, invalid := range []string{
+		"feeds: [{name: a, module: gone}]",
+		"feeds: [{name: a, module: lego, options: {category: old}}]",
+		"feeds: [{name: a, module: dockerhub, options: {platform: linux/arm64}}]",
+		"feeds: [{name: a, module: hackerone, options: {reports_count: many}}]",
+		"modules: {lego: {settings: {TOKEN: a}}}",
+		"settings: {GONE: a}",
+		"notifier: {interval: often}",
+	} {
+		var c map[string]any
+		if err := yaml.Unmarshal([]byte(invalid), &c); err != nil {
+			t.Fatal(err)
+		}
+		if err := validate(s, c, "config"); err == nil {
+			t.Errorf("%s should be invalid", invalid)
+		}
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,270 @@
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/notifier"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const JSON_SCHEMA_DIALECT = "https://json-schema.org/draft/2020-12/schema"
+
+// DURATION_PATTERN matches the Go durations, eg 1h30m
+const DURATION_PATTERN = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// Schema is a JSON Schema, as used by the OpenAPI 3.1 documents
+type Schema struct {
+	Dialect     string `json:"$schema,omitempty"`
+	Id          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is a type name, or a list of them
+	Type       any                `json:"type,omitempty"`
+	Default    any                `json:"default,omitempty"`
+	Enum       []any              `json:"enum,omitempty"`
+	Const      any                `json:"const,omitempty"`
+	Pattern    string             `json:"pattern,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties is a *Schema, or false
+	AdditionalProperties any       `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema   `json:"propertyNames,omitempty"`
+	Required             []string  `json:"required,omitempty"`
+	AllOf                []*Schema `json:"allOf,omitempty"`
+	If                   *Schema   `json:"if,omitempty"`
+	Then                 *Schema   `json:"then,omitempty"`
+}
+
+func enum(values []string) []any {
+	var e []any
+	for _, v := range values {
+		e = append(e, v)
+	}
+	return e
+}
+
+// OptionSchema describes the values of a module option, as read from the feed URLs
+func OptionSchema(option *parser.Option) *Schema {
+	s := &Schema{Description: option.Help}
+	switch option.Type {
+	case "int":
+		s.Type = "integer"
+		if d, err := strconv.Atoi(option.Default); err == nil {
+			s.Default = d
+		}
+	case "bool":
+		s.Type = "boolean"
+		if option.Default != "" {
+			s.Default = option.Default == "true" || option.Default == "1"
+		}
+	case "stringSlice":
+		s.Type = "array"
+		s.Items = &Schema{Type: "string", Enum: enum(option.Enum)}
+		if option.Default != "" {
+			s.Default = strings.Split(option.Default, ",")
+		}
+		return s
+	default:
+		s.Type = "string"
+		if option.Default != "" {
+			s.Default = option.Default
+		}
+	}
+	s.Enum = enum(option.Enum)
+	return s
+}
+
+// configOptionSchema describes the values of a module option in the config
+// file, read as strings: numbers and booleans may be quoted, and lists are
+// comma separated strings
+func configOptionSchema(option *parser.Option) *Schema {
+	s := OptionSchema(option)
+	switch option.Type {
+	case "int":
+		s.Type = []string{"integer", "string"}
+		s.Pattern = `^-?[0-9]+$`
+	case "bool":
+		s.Type = nil
+		s.Enum = []any{true, false, "true", "false", "1", "0"}
+	case "stringSlice":
+		s = &Schema{Type: "string", Description: option.Help + ", comma separated"}
+		if option.Default != "" {
+			s.Default = option.Default
+		}
+		if len(option.Enum) > 0 {
+			values := strings.Join(option.Enum, "|")
+			s.Pattern = fmt.Sprintf("^(%s)(,(%s))*$", values, values)
+		}
+	}
+	return s
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// REQUIRED_FIELDS are the fields the config file validation requires
+var REQUIRED_FIELDS = map[reflect.Type][]string{
+	reflect.TypeFor[config.FeedConfig]():   {"name", "module"},
+	reflect.TypeFor[config.TargetConfig](): {"name", "type", "url"},
+	reflect.TypeFor[config.TokenConfig]():  {"name", "token"},
+}
+
+// typeSchema describes a config type from its yaml tags
+func typeSchema(t reflect.Type) *Schema {
+	if t == durationType {
+		return &Schema{Type: "string", Pattern: DURATION_PATTERN}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false, Required: REQUIRED_FIELDS[t]}
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			s.Properties[name] = typeSchema(t.Field(i).Type)
+		}
+		return s
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: typeSchema(t.Elem())}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: typeSchema(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	}
+	// any value
+	return &Schema{}
+}
+
+var SECTIONS_DESCRIPTIONS = map[string]string{
+	"feeds":       "Feeds served, notified and exported to OPML",
+	"notifier":    "Notifier of the feeds new items",
+	"access":      "Access control of the feeds and API",
+	"rate_limits": "Rate limits of the modules, as <requests>/<duration> or none",
+	"upstreams":   "Concurrency, delay and proxies of the upstream requests, by host or module",
+	"settings":    "Options values, overridden by their environment variables",
+	"modules":     "Modules enabled, defaults of their options and settings, by feed URL name",
+}
+
+// STATIC_BUILD_OPTIONS_SCHEMAS describes the static site builds options, when
+// they aren't options of the module
+var STATIC_BUILD_OPTIONS_SCHEMAS = map[string]*Schema{
+	"title":    {Type: "string", Description: "Title of the feed in the static site index"},
+	"filename": {Type: "string", Description: "File name of the feed in the static site builds"},
+	"private":  {Type: []string{"boolean", "string"}, Description: "Leaves the feed out of the static site index and the OPML export"},
+}
+
+// feedOptionsSchema describes the options of the feeds of a module in the config file
+func feedOptionsSchema(p parser.Parser) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	for _, option := range parser.GetFullOptions(p).OptionsList {
+		if option.IsStatic {
+			continue
+		}
+		s.Properties[option.Flag] = configOptionSchema(option)
+		// FeedPath falls back to the default of required options
+		if option.Required && option.Default == "" {
+			s.Required = append(s.Required, option.Flag)
+		}
+	}
+	for _, name := range config.STATIC_BUILD_OPTIONS {
+		if s.Properties[name] == nil {
+			s.Properties[name] = STATIC_BUILD_OPTIONS_SCHEMAS[name]
+		}
+	}
+	return s
+}
+
+// moduleConfigSchema describes the config of a module in the modules section
+func moduleConfigSchema(p parser.Parser) *Schema {
+	defaults := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	for _, option := range parser.GetFullOptions(p).OptionsList {
+		if !option.IsStatic && !option.Required {
+			defaults.Properties[option.Flag] = configOptionSchema(option)
+		}
+	}
+	settings := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	for _, setting := range parser.GetSettingsHelp(p) {
+		settings.Properties[setting.Name] = &Schema{Type: "string", Description: setting.Description}
+		if setting.Default != "" {
+			settings.Properties[setting.Name].Default = setting.Default
+		}
+		settings.Properties[setting.Name+config.FILE_SUFFIX] = &Schema{Type: "string", Description: "File containing " + setting.Name}
+	}
+	return &Schema{
+		Type:        "object",
+		Description: parser.GetModuleInfo(p.String(), p).Description,
+		Properties: map[string]*Schema{
+			"enabled":  {Type: "boolean", Default: true},
+			"defaults": defaults,
+			"settings": settings,
+		},
+		AdditionalProperties: false,
+	}
+}
+
+// settingsSchema describes the options values of the settings section
+func settingsSchema() *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	defaults := config.GetDefaultValues()
+	for _, option := range config.CONFIG_OPTIONS {
+		setting := &Schema{Type: []string{"string", "number", "boolean"}, Description: option.Description}
+		if defaults[option.Name] != "" {
+			setting.Default = defaults[option.Name]
+		}
+		s.Properties[option.Name] = setting
+	}
+	return s
+}
+
+// ConfigSchema returns the JSON Schema of the CONFIG_FILE, modules named as in the feeds config
+func ConfigSchema(modules map[string]parser.Parser) *Schema {
+	s := typeSchema(reflect.TypeFor[config.FileConfig]())
+	s.Dialect = JSON_SCHEMA_DIALECT
+	s.Title = "rss-banquet config file"
+	for name, description := range SECTIONS_DESCRIPTIONS {
+		s.Properties[name].Description = description
+	}
+
+	names := slices.Sorted(maps.Keys(modules))
+	routes := make([]string, 0, len(modules))
+	moduleConfigs := make(map[string]*Schema, len(modules))
+	feed := s.Properties["feeds"].Items
+	feed.Properties["module"].Enum = enum(names)
+	for _, name := range names {
+		p := modules[name]
+		routes = append(routes, p.String())
+		moduleConfigs[p.String()] = moduleConfigSchema(p)
+		feed.AllOf = append(feed.AllOf, &Schema{
+			If:   &Schema{Properties: map[string]*Schema{"module": {Const: name}}, Required: []string{"module"}},
+			Then: &Schema{Properties: map[string]*Schema{"options": feedOptionsSchema(p)}},
+		})
+	}
+	slices.Sort(routes)
+
+	s.Properties["settings"] = settingsSchema()
+	s.Properties["settings"].Description = SECTIONS_DESCRIPTIONS["settings"]
+	s.Properties["modules"].Properties = moduleConfigs
+	s.Properties["modules"].AdditionalProperties = false
+	s.Properties["rate_limits"].PropertyNames = &Schema{Enum: enum(names)}
+	s.Properties["access"].Properties["modules"].PropertyNames = &Schema{Enum: enum(names)}
+	s.Properties["upstreams"].Properties["modules"].PropertyNames = &Schema{Enum: enum(routes)}
+	access := &Schema{Type: "string", Enum: enum([]string{config.ACCESS_PUBLIC, config.ACCESS_TOKEN})}
+	s.Properties["access"].Properties["default"] = access
+	s.Properties["access"].Properties["api"] = access
+	s.Properties["access"].Properties["modules"].AdditionalProperties = access
+	s.Properties["notifier"].Properties["targets"].Items.Properties["type"].Enum = enum(notifier.TARGET_TYPES)
+	return s
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/opml"
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+func buildOpml(baseUrl string, feeds []config.FeedConfig, withExamples bool) *opml.OPML {
+	o := opml.New("RSS Banquet")
+	outlines := make(map[string]*opml.Outline)
+
+	for _, feed := range feeds {
+		if feed.IsPrivate() {
+			continue
+		}
+		m := getModule(feed.Module)
+		if m == nil {
+			log.Warn().Msgf("opml: feed %s uses unknown module %s", feed.Name, feed.Module)
+			continue
+		}
+		values := feed.GetOptions()
+		text := feed.Name
+		if title, ok := values["title"]; ok {
+			text = title
+		}
+		options := parser.GetFullOptions(m)
+		for _, name := range config.STATIC_BUILD_OPTIONS {
+			if !slices.ContainsFunc(options.OptionsList, func(o *parser.Option) bool { return o.Flag == name }) {
+				delete(values, name)
+			}
+		}
+		path, err := parser.FeedPath(options, values)
+		if err != nil {
+			log.Warn().Msgf("opml: skipping feed %s: %s", feed.Name, err)
+			continue
+		}
+		if outlines[feed.Module] == nil {
+			outlines[feed.Module] = &opml.Outline{Text: feed.Module}
+		}
+		outlines[feed.Module].Outlines = append(outlines[feed.Module].Outlines, opml.NewFeedOutline(text, baseUrl+path))
+	}
+
+	if withExamples {
+		for name, module := range parser.GetModules() {
+			if outlines[name] == nil {
+				outlines[name] = &opml.Outline{Text: name}
+			}
+			path := parser.ExamplePath(parser.GetFullOptions(module()))
+			outlines[name].Outlines = append(outlines[name].Outlines, opml.NewFeedOutline(fmt.Sprintf("%s (example)", name), baseUrl+path))
+		}
+	}
+
+	names := make([]string, 0, len(outlines))
+	for name := range outlines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		o.Body.Outlines = append(o.Body.Outlines, outlines[name])
+	}
+	return o
+}
+
+func getParsers() map[string]parser.Parser {
+	modules := parser.GetModules()
+	parsers := make(map[string]parser.Parser, len(modules))
+	for name, module := range modules {
+		parsers[name] = module()
+	}
+	return parsers
+}
+
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rss-banquet import <file.opml>\n")
+		fmt.Fprintf(os.Stderr, "Resolves the rss-banquet feeds of an OPML file and prints them as a config file feeds list\n")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	defer file.Close()
+	o, err := opml.Parse(file)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	feeds, problems := importFeeds(o, os.Stderr)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.FileConfig{Feeds: feeds}); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "%d feed(s) could not be resolved\n", problems)
+		os.Exit(1)
+	}
+}
+
+// importFeeds resolves the rss-banquet feeds of an OPML document through the
+// feed routes, reporting the feeds that can't be resolved to errs
+func importFeeds(o *opml.OPML, errs io.Writer) ([]config.FeedConfig, int) {
+	gin.SetMode(gin.ReleaseMode)
+	matcher := parser.NewRouteMatcher(getParsers())
+	var feeds []config.FeedConfig
+	problems := 0
+	for _, outline := range o.Feeds() {
+		match, err := matcher.Match(outline.XmlUrl)
+		if err != nil {
+			problems++
+			fmt.Fprintf(errs, "%s: %s\n", outline.Text, err)
+			continue
+		}
+		if len(match.Unknown) > 0 {
+			problems++
+			fmt.Fprintf(errs, "%s: unknown options for module %s: %s\n", outline.Text, match.Module, strings.Join(match.Unknown, ", "))
+		}
+		feed := config.FeedConfig{Name: outline.Text, Module: match.Module}
+		values := match.Values()
+		for k, v := range values {
+			if v == ":"+k {
+				problems++
+				fmt.Fprintf(errs, "%s: option %s is an example placeholder\n", outline.Text, k)
+			}
+		}
+		if len(values) > 0 {
+			feed.Options = make(map[string]any, len(values))
+			for k, v := range values {
+				feed.Options[k] = v
+			}
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, problems
+}
@@ -0,0 +1,79 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []*Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string     `xml:"text,attr"`
+	Title    string     `xml:"title,attr,omitempty"`
+	Type     string     `xml:"type,attr,omitempty"`
+	XmlUrl   string     `xml:"xmlUrl,attr,omitempty"`
+	HtmlUrl  string     `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*Outline `xml:"outline"`
+}
+
+func New(title string) *OPML {
+	return &OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+}
+
+func NewFeedOutline(text string, xmlUrl string) *Outline {
+	return &Outline{Text: text, Title: text, Type: "rss", XmlUrl: xmlUrl}
+}
+
+func (o *OPML) String() (string, error) {
+	data, err := xml.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(data), nil
+}
+
+func Parse(r io.Reader) (*OPML, error) {
+	var o OPML
+	if err := xml.NewDecoder(r).Decode(&o); err != nil {
+		return nil, fmt.Errorf("invalid opml: %w", err)
+	}
+	return &o, nil
+}
+
+// Feeds returns the outlines having a feed url, at any depth
+func (o *OPML) Feeds() []*Outline {
+	return collectFeeds(o.Body.Outlines)
+}
+
+func collectFeeds(outlines []*Outline) []*Outline {
+	var feeds []*Outline
+	for _, outline := range outlines {
+		if outline.XmlUrl != "" {
+			feeds = append(feeds, outline)
+		}
+		feeds = append(feeds, collectFeeds(outline.Outlines)...)
+	}
+	return feeds
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/opml"
+)
+
+func TestBuildOpml(t *testing.T) {
+	c, err := config.LoadConfigFile("config.sample.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := buildOpml("https://banquet.example.com", c.Feeds, false)
+
+	urls := make(map[string]string)
+	for _, outline := range o.Feeds() {
+		urls[outline.Text] = outline.XmlUrl
+	}
+	if urls["PS5 Updates"] != "https://banquet.example.com/feed/psupdates/ps5" {
+		t.Errorf("the feeds should be titled and keep their options: %v", urls)
+	}
+	if u, ok := urls["HackerOne Activity"]; !ok || strings.Contains(u, "filename") {
+		t.Errorf("the static build options should be left out of the feed URLs: %s", u)
+	}
+	if _, ok := urls["Lego New"]; ok {
+		t.Errorf("private feeds should be left out")
+	}
+	if _, ok := urls["Lego Coming Soon"]; !ok {
+		t.Errorf("missing Lego Coming Soon feed: %v", urls)
+	}
+	for _, group := range o.Body.Outlines {
+		for _, outline := range group.Outlines {
+			if strings.HasSuffix(outline.Text, "(example)") {
+				t.Errorf("unexpected example %s", outline.Text)
+			}
+		}
+	}
+
+	o = buildOpml("https://banquet.example.com", nil, true)
+	if len(o.Body.Outlines) != len(getParsers()) {
+		t.Errorf("every module should have an example, got %d groups", len(o.Body.Outlines))
+	}
+}
+
+func TestImportFeeds(t *testing.T) {
+	feeds := []config.FeedConfig{
+		{Name: "PS4", Module: "psupdates", Options: map[string]any{"hardware": "ps4"}},
+		{Name: "Lego", Module: "lego", Options: map[string]any{"category": "coming-soon"}},
+	}
+	o := buildOpml("https://banquet.example.com", feeds, false)
+	o.Body.Outlines = append(o.Body.Outlines,
+		opml.NewFeedOutline("Gone", "https://banquet.example.com/feed/gone/1"),
+		opml.NewFeedOutline("Typo", "https://banquet.example.com/feed/lego?categroy=new"),
+	)
+
+	var errs strings.Builder
+	imported, problems := importFeeds(o, &errs)
+	if problems != 2 {
+		t.Errorf("importFeeds() reported %d problems, want 2: %s", problems, errs.String())
+	}
+	if !strings.Contains(errs.String(), "Typo: unknown options for module lego: categroy") {
+		t.Errorf("unexpected errors %s", errs.String())
+	}
+	byName := make(map[string]config.FeedConfig)
+	for _, feed := range imported {
+		byName[feed.Name] = feed
+	}
+	if f := byName["PS4"]; f.Module != "psupdates" || f.Options["hardware"] != "ps4" {
+		t.Errorf("unexpected imported feed %+v", f)
+	}
+	if f := byName["Lego"]; f.Module != "lego" || f.Options["category"] != "coming-soon" {
+		t.Errorf("unexpected imported feed %+v", f)
+	}
+	if _, ok := byName["Gone"]; ok {
+		t.Errorf("the feeds of unknown modules shouldn't be imported")
+	}
+}
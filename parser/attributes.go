@@ -0,0 +1,316 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/feeds"
+)
+
+// AttributeValue is a typed value attached to a feed item, exposed in the
+// JSON feed extension and as categories in RSS/Atom, and usable in filters
+type AttributeValue interface {
+	Kind() string
+	String() string
+	// Equal tells whether the value matches the filter operand
+	Equal(operand string) bool
+	// Compare orders the value against the filter operand, for types that can be ordered
+	Compare(operand string) (int, error)
+}
+
+type Attributes map[string]AttributeValue
+
+func (a Attributes) Names() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (o *Options) SetAttribute(item *feeds.Item, name string, value AttributeValue) {
+	if value == nil {
+		return
+	}
+	ext := o.Extend(item)
+	if ext.Attributes == nil {
+		ext.Attributes = make(Attributes)
+	}
+	ext.Attributes[name] = value
+}
+
+func (o *Options) GetAttribute(item *feeds.Item, name string) AttributeValue {
+	ext := o.GetExtensions(item)
+	if ext == nil {
+		return nil
+	}
+	return ext.Attributes[name]
+}
+
+type Text string
+
+func (t Text) Kind() string   { return "text" }
+func (t Text) String() string { return string(t) }
+func (t Text) Equal(operand string) bool {
+	return strings.EqualFold(string(t), operand)
+}
+func (t Text) Compare(operand string) (int, error) {
+	return strings.Compare(strings.ToLower(string(t)), strings.ToLower(operand)), nil
+}
+
+type Number float64
+
+func (n Number) Kind() string   { return "number" }
+func (n Number) String() string { return strconv.FormatFloat(float64(n), 'f', -1, 64) }
+func (n Number) Equal(operand string) bool {
+	c, err := n.Compare(operand)
+	return err == nil && c == 0
+}
+func (n Number) Compare(operand string) (int, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(operand), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number: %s", operand)
+	}
+	return compareFloats(float64(n), f), nil
+}
+
+type Money struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+var currencySymbols = map[string]string{
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"¥": "JPY",
+}
+
+var moneyRe = regexp.MustCompile(`[0-9][0-9,]*(\.[0-9]+)?`)
+var currencyRe = regexp.MustCompile(`\b[A-Z]{3}\b`)
+
+// ParseMoney reads amounts such as "$1,299.99", "49.99 EUR" or "12"
+func ParseMoney(s string) (Money, error) {
+	amount := moneyRe.FindString(s)
+	if amount == "" {
+		return Money{}, fmt.Errorf("no amount found in %s", s)
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(amount, ",", ""), 64)
+	if err != nil {
+		return Money{}, err
+	}
+	m := Money{Amount: f}
+	if c := currencyRe.FindString(s); c != "" {
+		m.Currency = c
+	} else {
+		for symbol, currency := range currencySymbols {
+			if strings.Contains(s, symbol) {
+				m.Currency = currency
+				break
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m Money) Kind() string { return "money" }
+func (m Money) String() string {
+	s := strconv.FormatFloat(m.Amount, 'f', 2, 64)
+	if m.Currency != "" {
+		s = fmt.Sprintf("%s %s", s, m.Currency)
+	}
+	return s
+}
+func (m Money) Equal(operand string) bool {
+	c, err := m.Compare(operand)
+	return err == nil && c == 0
+}
+func (m Money) Compare(operand string) (int, error) {
+	o, err := ParseMoney(operand)
+	if err != nil {
+		return 0, err
+	}
+	if o.Currency != "" && m.Currency != "" && o.Currency != m.Currency {
+		return 0, fmt.Errorf("currency mismatch: %s != %s", m.Currency, o.Currency)
+	}
+	return compareFloats(m.Amount, o.Amount), nil
+}
+
+type Version string
+
+func (v Version) Kind() string   { return "version" }
+func (v Version) String() string { return string(v) }
+func (v Version) Equal(operand string) bool {
+	return strings.TrimPrefix(string(v), "v") == strings.TrimPrefix(operand, "v")
+}
+
+var versionPartRe = regexp.MustCompile(`[0-9]+|[A-Za-z]+`)
+
+// Compare orders versions segment by segment, numerically when possible
+func (v Version) Compare(operand string) (int, error) {
+	a := versionPartRe.FindAllString(strings.TrimPrefix(string(v), "v"), -1)
+	b := versionPartRe.FindAllString(strings.TrimPrefix(operand, "v"), -1)
+	if len(b) == 0 {
+		return 0, fmt.Errorf("invalid version: %s", operand)
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ai, aErr := strconv.Atoi(a[i])
+		bi, bErr := strconv.Atoi(b[i])
+		if aErr == nil && bErr == nil {
+			if ai != bi {
+				return compareFloats(float64(ai), float64(bi)), nil
+			}
+			continue
+		}
+		if c := strings.Compare(a[i], b[i]); c != 0 {
+			return c, nil
+		}
+	}
+	return compareFloats(float64(len(a)), float64(len(b))), nil
+}
+
+// Platform is an os/architecture/variant triplet, matching any more specific platform
+type Platform string
+
+func (p Platform) Kind() string   { return "platform" }
+func (p Platform) String() string { return string(p) }
+func (p Platform) Equal(operand string) bool {
+	ps := strings.Split(strings.ToLower(string(p)), "/")
+	os := strings.Split(strings.ToLower(operand), "/")
+	if len(os) > len(ps) {
+		return false
+	}
+	for i := range os {
+		if os[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+func (p Platform) Compare(operand string) (int, error) {
+	return 0, fmt.Errorf("platforms can't be ordered")
+}
+
+type Tags []string
+
+func (t Tags) Kind() string   { return "tags" }
+func (t Tags) String() string { return strings.Join(t, ", ") }
+func (t Tags) Equal(operand string) bool {
+	for _, tag := range t {
+		if strings.EqualFold(tag, operand) {
+			return true
+		}
+	}
+	return false
+}
+func (t Tags) Compare(operand string) (int, error) {
+	return 0, fmt.Errorf("tags can't be ordered")
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+const ATTRIBUTE_FILTER_PREFIX = "attr."
+
+type AttributeFilter struct {
+	Name     string
+	Operator string
+	Operand  string
+}
+
+var attributeFilterRe = regexp.MustCompile(`^([^<>!=]+)(<=|>=|!=|<|>|=)?(.*)$`)
+
+// ParseAttributeFilters reads the attr.<name><operator><value> query parameters.
+// As the query string is split on `=`, `attr.price<=50` is received as the
+// `attr.price<` key with a `50` value, and `attr.price<50` as a key without value.
+func ParseAttributeFilters(query url.Values) ([]AttributeFilter, error) {
+	var filters []AttributeFilter
+	for key, values := range query {
+		if !strings.HasPrefix(key, ATTRIBUTE_FILTER_PREFIX) {
+			continue
+		}
+		for _, value := range values {
+			m := attributeFilterRe.FindStringSubmatch(strings.TrimPrefix(key, ATTRIBUTE_FILTER_PREFIX))
+			if m == nil {
+				return nil, fmt.Errorf("invalid attribute filter: %s", key)
+			}
+			f := AttributeFilter{Name: m[1], Operator: m[2], Operand: m[3]}
+			switch {
+			case f.Operator == "" && f.Operand == "":
+				f.Operator = "="
+				f.Operand = value
+			case (f.Operator == "<" || f.Operator == ">") && f.Operand == "":
+				f.Operator += "="
+				f.Operand = value
+			case f.Operator == "" && f.Operand == "!":
+				f.Operator = "!="
+				f.Operand = value
+			case value != "":
+				return nil, fmt.Errorf("invalid attribute filter: %s=%s", key, value)
+			}
+			filters = append(filters, f)
+		}
+	}
+	return filters, nil
+}
+
+func (f AttributeFilter) Match(value AttributeValue) bool {
+	if value == nil {
+		return false
+	}
+	switch f.Operator {
+	case "=":
+		return value.Equal(f.Operand)
+	case "!=":
+		return !value.Equal(f.Operand)
+	}
+	c, err := value.Compare(f.Operand)
+	if err != nil {
+		return false
+	}
+	switch f.Operator {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// FilterFeedItems drops the items that don't match all the attribute filters
+func FilterFeedItems(f *feeds.Feed, o *Options, filters []AttributeFilter) {
+	if len(filters) == 0 {
+		return
+	}
+	items := []*feeds.Item{}
+	for _, item := range f.Items {
+		keep := true
+		for _, filter := range filters {
+			if !filter.Match(o.GetAttribute(item, filter.Name)) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			items = append(items, item)
+		}
+	}
+	f.Items = items
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/feeds"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+	}{
+		{"$1,299.99", Money{Amount: 1299.99, Currency: "USD"}},
+		{"49.99 EUR", Money{Amount: 49.99, Currency: "EUR"}},
+		{"12", Money{Amount: 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMoney(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMoney() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := ParseMoney("-"); err == nil {
+		t.Errorf("ParseMoney() should fail without an amount")
+	}
+}
+
+func TestParseAttributeFilters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []AttributeFilter
+	}{
+		{"attr.severity=critical", []AttributeFilter{{"severity", "=", "critical"}}},
+		{"attr.price<50", []AttributeFilter{{"price", "<", "50"}}},
+		{"attr.price%3C%3D50", []AttributeFilter{{"price", "<=", "50"}}},
+		{"attr.price<=50", []AttributeFilter{{"price", "<=", "50"}}},
+		{"attr.price>=50", []AttributeFilter{{"price", ">=", "50"}}},
+		{"attr.state!=resolved", []AttributeFilter{{"state", "!=", "resolved"}}},
+		{"feedFormat=json", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseAttributeFilters(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAttributeFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeFilterMatch(t *testing.T) {
+	tests := []struct {
+		filter AttributeFilter
+		value  AttributeValue
+		want   bool
+	}{
+		{AttributeFilter{"price", "<", "50"}, Money{Amount: 49.99, Currency: "USD"}, true},
+		{AttributeFilter{"price", "<", "50 EUR"}, Money{Amount: 49.99, Currency: "USD"}, false},
+		{AttributeFilter{"price", ">=", "$50"}, Money{Amount: 50, Currency: "USD"}, true},
+		{AttributeFilter{"severity", "=", "Critical"}, Text("critical"), true},
+		{AttributeFilter{"severity", "!=", "critical"}, Text("high"), true},
+		{AttributeFilter{"tag", ">", "1.9"}, Version("1.10.2"), true},
+		{AttributeFilter{"tag", "<", "v2.0.0"}, Version("v2.0.0-rc1"), false},
+		{AttributeFilter{"platform", "=", "linux/arm64"}, Platform("linux/arm64/v8"), true},
+		{AttributeFilter{"platform", "=", "linux/amd64"}, Platform("linux/arm64"), false},
+		{AttributeFilter{"bugs", "=", "xss"}, Tags{"SSRF", "XSS"}, true},
+		{AttributeFilter{"points", ">", "10"}, Number(5), false},
+		{AttributeFilter{"missing", "=", "x"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter.Name+tt.filter.Operator+tt.filter.Operand, func(t *testing.T) {
+			if got := tt.filter.Match(tt.value); got != tt.want {
+				t.Errorf("Match(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterFeedItems(t *testing.T) {
+	o := &Options{}
+	cheap := &feeds.Item{Title: "cheap", Link: &feeds.Link{}}
+	expensive := &feeds.Item{Title: "expensive", Link: &feeds.Link{}}
+	unpriced := &feeds.Item{Title: "unpriced", Link: &feeds.Link{}}
+	o.SetAttribute(cheap, "price", Money{Amount: 10, Currency: "USD"})
+	o.SetAttribute(expensive, "price", Money{Amount: 100, Currency: "USD"})
+	f := &feeds.Feed{Title: "feed", Link: &feeds.Link{}, Items: []*feeds.Item{cheap, expensive, unpriced}}
+
+	FilterFeedItems(f, o, []AttributeFilter{{"price", "<", "50"}})
+	if len(f.Items) != 1 || f.Items[0] != cheap {
+		t.Errorf("unexpected filtered items %v", f.Items)
+	}
+
+	rss, err := ToRss(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rss, `<category domain="price">10.00 USD</category>`) {
+		t.Errorf("rss output missing the price category:\n%s", rss)
+	}
+	json, err := ToJSON(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(json, `"_banquet"`) || !strings.Contains(json, `"type": "money"`) {
+		t.Errorf("json output missing the attributes extension:\n%s", json)
+	}
+}
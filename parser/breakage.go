@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/rs/zerolog/log"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/metrics"
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+// BASELINE_MIN_SAMPLES is the number of parses needed before detecting anomalies
+const BASELINE_MIN_SAMPLES = 3
+
+// BASELINE_WEIGHT is the weight of the last parse in the moving averages
+const BASELINE_WEIGHT = 0.2
+
+// BASELINE_COMPLETENESS is the ratio of items having a field above which all of them are expected to have it
+const BASELINE_COMPLETENESS = 0.9
+
+// BASELINES_SAVE_INTERVAL is the minimum delay between two saves of the baselines, also saved on shutdown
+const BASELINES_SAVE_INTERVAL = time.Minute
+
+// MAX_BASELINES bounds the number of feeds tracked, the least recently served ones being dropped
+const MAX_BASELINES = 1000
+
+const BREAKAGE_HEADER = "X-Banquet-Warning"
+
+var ANOMALY_KINDS = []string{"no_items", "missing_dates", "missing_links"}
+
+// FeedBaseline holds the moving averages of a feed item count and field completeness
+type FeedBaseline struct {
+	Samples   int       `json:"samples"`
+	Items     float64   `json:"items"`
+	DateRatio float64   `json:"dateRatio"`
+	LinkRatio float64   `json:"linkRatio"`
+	Seen      time.Time `json:"seen"`
+}
+
+type BreakageError struct {
+	Anomalies []string
+}
+
+func (e *BreakageError) Error() string {
+	return fmt.Sprintf("BreakageError: possible scraper breakage: %s", strings.Join(e.Anomalies, ", "))
+}
+
+var (
+	baselines       map[string]*FeedBaseline
+	baselinesMu     sync.Mutex
+	baselinesLoaded bool
+	baselinesDirty  bool
+	baselinesSaved  time.Time
+)
+
+func loadBaselines() {
+	if baselinesLoaded {
+		return
+	}
+	baselinesLoaded = true
+	baselines = make(map[string]*FeedBaseline)
+	if path := config.GetConfigOption("BASELINES_FILE"); path != "" {
+		if err := utils.ReadJSONFile(path, &baselines); err != nil {
+			log.Error().Msgf("unable to read feed baselines %s: %s", path, err)
+		}
+	}
+}
+
+// saveBaselines writes the baselines file if they changed, baselinesMu being held
+func saveBaselines() {
+	path := config.GetConfigOption("BASELINES_FILE")
+	if path == "" || !baselinesDirty {
+		return
+	}
+	if err := utils.WriteJSONFile(path, baselines); err != nil {
+		log.Error().Msgf("unable to save feed baselines %s: %s", path, err)
+		return
+	}
+	baselinesDirty = false
+	baselinesSaved = time.Now()
+}
+
+// SaveBaselines persists the feed baselines, on shutdown
+func SaveBaselines() {
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+	saveBaselines()
+}
+
+// evictBaseline drops the baseline of the least recently served feed, baselinesMu being held
+func evictBaseline() {
+	oldest := ""
+	for key, b := range baselines {
+		if oldest == "" || b.Seen.Before(baselines[oldest].Seen) {
+			oldest = key
+		}
+	}
+	delete(baselines, oldest)
+	metrics.ForgetFeed(oldest)
+}
+
+// BaselineKey identifies the unfiltered feed served at u by its path and
+// options, leaving out the filters and unknown query parameters
+func BaselineKey(u *url.URL, o *Options) string {
+	query := url.Values{}
+	for key, values := range u.Query() {
+		isOption := slices.ContainsFunc(o.OptionsList, func(option *Option) bool { return option.Flag == key })
+		if isOption && key != "feedFormat" {
+			query[key] = values
+		}
+	}
+	if len(query) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+func getFieldRatios(f *feeds.Feed) (float64, float64) {
+	if len(f.Items) == 0 {
+		return 0, 0
+	}
+	dates, links := 0, 0
+	for _, item := range f.Items {
+		if !item.Created.IsZero() || !item.Updated.IsZero() {
+			dates++
+		}
+		if item.Link != nil && item.Link.Href != "" {
+			links++
+		}
+	}
+	return float64(dates) / float64(len(f.Items)), float64(links) / float64(len(f.Items))
+}
+
+func (b *FeedBaseline) anomalies(f *feeds.Feed, dateRatio float64, linkRatio float64) []string {
+	var anomalies []string
+	if b.Samples < BASELINE_MIN_SAMPLES {
+		return anomalies
+	}
+	if len(f.Items) == 0 {
+		if b.Items >= 1 {
+			anomalies = append(anomalies, "no_items")
+		}
+		return anomalies
+	}
+	if dateRatio == 0 && b.DateRatio >= BASELINE_COMPLETENESS {
+		anomalies = append(anomalies, "missing_dates")
+	}
+	if linkRatio == 0 && b.LinkRatio >= BASELINE_COMPLETENESS {
+		anomalies = append(anomalies, "missing_links")
+	}
+	return anomalies
+}
+
+func (b *FeedBaseline) update(items int, dateRatio float64, linkRatio float64) {
+	if b.Samples == 0 {
+		b.Items, b.DateRatio, b.LinkRatio = float64(items), dateRatio, linkRatio
+	} else {
+		b.Items += BASELINE_WEIGHT * (float64(items) - b.Items)
+		b.DateRatio += BASELINE_WEIGHT * (dateRatio - b.DateRatio)
+		b.LinkRatio += BASELINE_WEIGHT * (linkRatio - b.LinkRatio)
+	}
+	b.Samples++
+}
+
+// CheckFeedBreakage compares the unfiltered feed with the baseline of its
+// previous parses, returning the anomalies found. Anomalous parses are kept
+// out of the baseline so that a layout change keeps being reported.
+func CheckFeedBreakage(module string, key string, f *feeds.Feed) []string {
+	dateRatio, linkRatio := getFieldRatios(f)
+
+	baselinesMu.Lock()
+	loadBaselines()
+	b, ok := baselines[key]
+	if !ok {
+		if len(baselines) >= MAX_BASELINES {
+			evictBaseline()
+		}
+		b = &FeedBaseline{}
+		baselines[key] = b
+	}
+	b.Seen = time.Now()
+	anomalies := b.anomalies(f, dateRatio, linkRatio)
+	if len(anomalies) == 0 {
+		b.update(len(f.Items), dateRatio, linkRatio)
+		baselinesDirty = true
+		if time.Since(baselinesSaved) >= BASELINES_SAVE_INTERVAL {
+			saveBaselines()
+		}
+	}
+	// recorded with the lock held, so that an evicted feed keeps no series
+	metrics.ObserveFeedAnomalies(module, key, ANOMALY_KINDS, anomalies)
+	baselinesMu.Unlock()
+	return anomalies
+}
+
+// PeekFeedBreakage compares the unfiltered feed with its baseline like
+// CheckFeedBreakage, leaving the baseline untouched
+func PeekFeedBreakage(key string, f *feeds.Feed) []string {
+	dateRatio, linkRatio := getFieldRatios(f)
+
+	baselinesMu.Lock()
+	defer baselinesMu.Unlock()
+	loadBaselines()
+	b, ok := baselines[key]
+	if !ok {
+		return nil
+	}
+	return b.anomalies(f, dateRatio, linkRatio)
+}
+
+// AddBreakageItem adds an item reporting the anomalies, identified by the day so that readers show it once a day
+func AddBreakageItem(f *feeds.Feed, key string, anomalies []string) {
+	now := time.Now()
+	item := &feeds.Item{
+		Id:          GetGuid([]string{key, strings.Join(anomalies, ","), now.Format(time.DateOnly)}),
+		Title:       fmt.Sprintf("rss-banquet: %s may be broken", key),
+		Description: fmt.Sprintf("The last parse of %s looks anomalous compared to the previous ones (%s), the upstream page layout may have changed.", key, strings.Join(anomalies, ", ")),
+		Created:     now,
+	}
+	if f.Link != nil {
+		item.Link = &feeds.Link{Href: f.Link.Href}
+	}
+	f.Items = append([]*feeds.Item{item}, f.Items...)
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+func newBreakageTestFeed(items int, dated bool, linked bool) *feeds.Feed {
+	f := &feeds.Feed{}
+	for range items {
+		item := &feeds.Item{Title: "item"}
+		if dated {
+			item.Created = time.Now()
+		}
+		if linked {
+			item.Link = &feeds.Link{Href: "https://example.com"}
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f
+}
+
+func TestFeedBaselineAnomalies(t *testing.T) {
+	b := &FeedBaseline{}
+	healthy := newBreakageTestFeed(10, true, true)
+	for range BASELINE_MIN_SAMPLES {
+		dateRatio, linkRatio := getFieldRatios(healthy)
+		if anomalies := b.anomalies(healthy, dateRatio, linkRatio); len(anomalies) > 0 {
+			t.Fatalf("unexpected anomalies while learning the baseline: %v", anomalies)
+		}
+		b.update(len(healthy.Items), dateRatio, linkRatio)
+	}
+
+	tests := []struct {
+		name string
+		feed *feeds.Feed
+		want []string
+	}{
+		{"healthy", newBreakageTestFeed(8, true, true), nil},
+		{"empty", newBreakageTestFeed(0, true, true), []string{"no_items"}},
+		{"undated", newBreakageTestFeed(10, false, true), []string{"missing_dates"}},
+		{"unlinked", newBreakageTestFeed(10, false, false), []string{"missing_dates", "missing_links"}},
+	}
+	for _, tt := range tests {
+		dateRatio, linkRatio := getFieldRatios(tt.feed)
+		if got := b.anomalies(tt.feed, dateRatio, linkRatio); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: anomalies = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// feeds without dates aren't expected to get some
+	b = &FeedBaseline{}
+	undated := newBreakageTestFeed(10, false, true)
+	for range BASELINE_MIN_SAMPLES {
+		b.update(10, 0, 1)
+	}
+	if got := b.anomalies(undated, 0, 1); len(got) > 0 {
+		t.Errorf("unexpected anomalies for an undated feed: %v", got)
+	}
+}
+
+func TestBreakageHealth(t *testing.T) {
+	RecordParse("breakagetest", "/feed/breakagetest", time.Second, 0, &BreakageError{Anomalies: []string{"no_items"}})
+	o := GetLastParse("breakagetest")
+	if o.Status != "broken" || o.ErrorClass != "breakage" {
+		t.Errorf("unexpected outcome %+v", o)
+	}
+	if got := GetReadiness(map[string]*ModuleHealth{"a": {Status: "broken"}, "b": {Status: "ok"}}); got != "degraded" {
+		t.Errorf("GetReadiness() = %s, want degraded", got)
+	}
+}
+
+func TestBaselineKey(t *testing.T) {
+	o := &Options{OptionsList: OptionsList{{Flag: "count", Type: "int"}, {Flag: "feedFormat", Type: "string"}}}
+	u, _ := url.Parse("/feed/breakagetest/1?count=5&attr.price<10&categories=a&feedFormat=atom&gone=1")
+	if got := BaselineKey(u, o); got != "/feed/breakagetest/1?count=5" {
+		t.Errorf("BaselineKey() = %s", got)
+	}
+}
+
+func TestBaselinesBoundsAndSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baselines.json")
+	setConfigOption(t, "BASELINES_FILE", path)
+	baselinesMu.Lock()
+	baselines, baselinesLoaded, baselinesSaved = make(map[string]*FeedBaseline), true, time.Time{}
+	baselinesMu.Unlock()
+
+	feed := newBreakageTestFeed(3, true, true)
+	for i := range MAX_BASELINES + 1 {
+		CheckFeedBreakage("breakagetest", fmt.Sprintf("/feed/breakagetest/%d", i), feed)
+	}
+	baselinesMu.Lock()
+	_, first := baselines["/feed/breakagetest/0"]
+	count := len(baselines)
+	baselinesMu.Unlock()
+	if count != MAX_BASELINES || first {
+		t.Errorf("the least recently served baselines should be dropped: %d baselines", count)
+	}
+
+	saved := make(map[string]*FeedBaseline)
+	if err := utils.ReadJSONFile(path, &saved); err != nil || len(saved) != 1 {
+		t.Errorf("the baselines should be saved once per interval, got %d: %v", len(saved), err)
+	}
+	SaveBaselines()
+	saved = make(map[string]*FeedBaseline)
+	if err := utils.ReadJSONFile(path, &saved); err != nil || len(saved) != MAX_BASELINES {
+		t.Errorf("the baselines should be saved on shutdown, got %d: %v", len(saved), err)
+	}
+}
+
+func TestFilteredFeedBreakage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	parses := 0
+	p := cacheTestParser{parses: &parses}
+	Route(r, p, GetFullOptions(p))
+
+	for _, path := range []string{"/feed/cachetest/breakage", "/feed/cachetest/breakage", "/feed/cachetest/breakage", "/feed/cachetest/breakage?attr.version=9.9.9"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := w.Header().Get(BREAKAGE_HEADER); got != "" {
+			t.Errorf("%s: a filter leaving no items isn't a breakage: %s", path, got)
+		}
+	}
+}
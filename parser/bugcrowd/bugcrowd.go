@@ -1,7 +1,6 @@
 package bugcrowd
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -15,6 +14,30 @@ func (Bugcrowd) String() string {
 	return "bugcrowd"
 }
 
+func init() {
+	parser.Register("bugcrowd", BugcrowdParser)
+}
+
+func (Bugcrowd) Description() string {
+	return "Bugcrowd Crowdstream disclosed and accepted reports"
+}
+
+func (Bugcrowd) Homepage() string {
+	return "https://bugcrowd.com"
+}
+
+func (Bugcrowd) Category() string {
+	return "security"
+}
+
+func (Bugcrowd) Icon() string {
+	return "https://bugcrowd.com/favicon.ico"
+}
+
+func (Bugcrowd) RefreshInterval() time.Duration {
+	return time.Hour
+}
+
 func (Bugcrowd) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -209,6 +232,25 @@ func buildItemContent(item *bugcrowdItem) string {
 	return description
 }
 
+func setItemAttributes(newItem *feeds.Item, item *bugcrowdItem, options *parser.Options) {
+	options.SetAttribute(newItem, "program", parser.Text(item.ProgramName))
+	if item.Amount != "" {
+		if reward, err := parser.ParseMoney(item.Amount); err == nil {
+			options.SetAttribute(newItem, "reward", reward)
+		}
+	}
+	if item.Points > 0 {
+		options.SetAttribute(newItem, "points", parser.Number(item.Points))
+	}
+	if item.Priority > 0 {
+		options.SetAttribute(newItem, "priority", parser.Number(item.Priority))
+	}
+	if item.Substate != "" {
+		options.SetAttribute(newItem, "state", parser.Text(item.Substate))
+	}
+	options.SetAttribute(newItem, "disclosed", parser.Text(fmt.Sprint(item.Disclosed)))
+}
+
 func feedAdapter(b *bugcrowdFeed, options *parser.Options) (*feeds.Feed, error) {
 	feed := feeds.Feed{
 		Title:       options.Get("title").(string),
@@ -230,6 +272,7 @@ func feedAdapter(b *bugcrowdFeed, options *parser.Options) (*feeds.Feed, error)
 			Id:          fmt.Sprint(updatedAt.Format(time.RFC3339), item.Id),
 			Updated:     updatedAt,
 		}
+		setItemAttributes(&newItem, &item, options)
 		feed.Items = append(feed.Items, &newItem)
 	}
 
@@ -261,7 +304,7 @@ func getCrowdStreamUrl(options *parser.Options) string {
 func (Bugcrowd) Parse(options *parser.Options) (*feeds.Feed, error) {
 	url := getCrowdStreamUrl(options)
 
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), url, nil)
 
 	if err != nil {
 		return nil, err
@@ -277,7 +320,7 @@ func (Bugcrowd) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	var feed bugcrowdFeed
 
-	if err := json.Unmarshal(data, &feed); err != nil {
+	if err := parser.UnmarshalJSON(options.Context(), data, &feed); err != nil {
 		return nil, err
 	}
 
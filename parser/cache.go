@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/metrics"
+)
+
+const FEED_CACHE_MAX_SIZE = 1000
+
+const CACHE_HEADER = "X-Banquet-Cache"
+
+type cachedFeed struct {
+	feed       *feeds.Feed
+	extensions map[*feeds.Item]*ItemExtensions
+	expires    time.Time
+}
+
+var (
+	feedCache   = make(map[string]cachedFeed)
+	feedCacheMu sync.RWMutex
+)
+
+// GetFeedCacheTtl returns how long the served feeds are cached, 0 if they aren't
+func GetFeedCacheTtl() (time.Duration, error) {
+	return time.ParseDuration(config.GetConfigOption("FEED_CACHE_TTL"))
+}
+
+func getCachedFeed(key string) (cachedFeed, bool) {
+	feedCacheMu.RLock()
+	defer feedCacheMu.RUnlock()
+	cached, ok := feedCache[key]
+	if !ok || time.Now().After(cached.expires) {
+		return cachedFeed{}, false
+	}
+	return cached, true
+}
+
+// IsFeedCached returns whether the feed would be served from the cache, without parsing
+func IsFeedCached(key string) bool {
+	_, ok := getCachedFeed(key)
+	return ok
+}
+
+// cacheFeed stores the served feed and its items extensions for the ttl,
+// they must not be modified afterwards
+func cacheFeed(key string, feed *feeds.Feed, o *Options, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	feedCacheMu.Lock()
+	defer feedCacheMu.Unlock()
+	if len(feedCache) >= FEED_CACHE_MAX_SIZE {
+		now := time.Now()
+		for k, cached := range feedCache {
+			if now.After(cached.expires) {
+				delete(feedCache, k)
+			}
+		}
+		if len(feedCache) >= FEED_CACHE_MAX_SIZE {
+			feedCache = make(map[string]cachedFeed)
+		}
+	}
+	feedCache[key] = cachedFeed{feed: feed, extensions: o.feedExtensions().items, expires: time.Now().Add(ttl)}
+}
+
+// lookupFeedCache returns the cached feed, attaching its items extensions to o
+func lookupFeedCache(key string, o *Options) (*feeds.Feed, bool) {
+	cached, ok := getCachedFeed(key)
+	if !ok {
+		metrics.CacheMiss("feed")
+		return nil, false
+	}
+	metrics.CacheHit("feed")
+	o.extensions = &feedExtensions{items: cached.extensions}
+	return cached.feed, true
+}
+
+// ClearFeedCache drops the cached feeds, parsed with outdated settings
+func ClearFeedCache() {
+	feedCacheMu.Lock()
+	defer feedCacheMu.Unlock()
+	feedCache = make(map[string]cachedFeed)
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+)
+
+type cacheTestParser struct {
+	parses *int
+}
+
+func (p cacheTestParser) Parse(o *Options) (*feeds.Feed, error) {
+	*p.parses++
+	item := &feeds.Item{Title: "item", Link: &feeds.Link{Href: "https://example.com"}}
+	o.SetAttribute(item, "version", Version("1.2.3"))
+	return &feeds.Feed{Title: "cache", Items: []*feeds.Item{item}}, nil
+}
+func (cacheTestParser) String() string { return "cachetest" }
+func (p cacheTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestFeedCache(t *testing.T) {
+	setConfigOption(t, "FEED_CACHE_TTL", "1m")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	parses := 0
+	p := cacheTestParser{parses: &parses}
+	Route(r, p, GetFullOptions(p))
+
+	for i, want := range []string{"miss", "hit"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed/cachetest/1?feedFormat=json&token=secret", nil))
+		if got := w.Header().Get(CACHE_HEADER); got != want {
+			t.Errorf("request %d: cache %s, want %s", i, got, want)
+		}
+		if !strings.Contains(w.Body.String(), "1.2.3") {
+			t.Errorf("request %d: the item attributes should be served: %s", i, w.Body.String())
+		}
+	}
+	if parses != 1 {
+		t.Errorf("expected 1 parse, got %d", parses)
+	}
+	if !IsFeedCached("/feed/cachetest/1") {
+		t.Errorf("the feed key should leave out the format and token")
+	}
+}
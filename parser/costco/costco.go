@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
@@ -15,6 +16,30 @@ func (Costco) String() string {
 	return "costco"
 }
 
+func init() {
+	parser.Register("costco", CostcoParser)
+}
+
+func (Costco) Description() string {
+	return "Products of a Costco page"
+}
+
+func (Costco) Homepage() string {
+	return "https://www.costco.com"
+}
+
+func (Costco) Category() string {
+	return "shopping"
+}
+
+func (Costco) Icon() string {
+	return "https://www.costco.com/favicon.ico"
+}
+
+func (Costco) RefreshInterval() time.Duration {
+	return 6 * time.Hour
+}
+
 func (Costco) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -58,7 +83,7 @@ func (Costco) Parse(options *parser.Options) (*feeds.Feed, error) {
 		"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
 	}
 
-	resp, err := parser.HttpGet(url, map[string]any{
+	resp, err := parser.HttpGetWithContext(options.Context(), url, map[string]any{
 		"headers": headers,
 	})
 	if err != nil {
@@ -75,7 +100,7 @@ func (Costco) Parse(options *parser.Options) (*feeds.Feed, error) {
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("failed to fetch page: %s", resp.Status)
 	}
-	doc, err := goquery.NewDocumentFromReader(zipReader)
+	doc, err := parser.DecodeHTML(options.Context(), zipReader)
 	if err != nil {
 		return nil, err
 	}
@@ -130,12 +155,11 @@ func (Costco) Parse(options *parser.Options) (*feeds.Feed, error) {
 			Link:        &feeds.Link{Href: fmt.Sprintf("https://www.costco.com/s?dept=All&keyword=%s", SKU)},
 			Id:          SKU,
 		}
-		if imageUrl != "" {
-			newItem.Enclosure = &feeds.Enclosure{
-				Url:    strings.ReplaceAll(imageUrl, "\\/", "/"),
-				Type:   "image/jpeg",
-				Length: "0",
-			}
+		options.AddImage(&newItem, strings.ReplaceAll(imageUrl, "\\/", "/"))
+		options.SetAttribute(&newItem, "sku", parser.Text(SKU))
+		if p, err := parser.ParseMoney(price); err == nil {
+			p.Currency = "USD"
+			options.SetAttribute(&newItem, "price", p)
 		}
 		feed.Items = append(feed.Items, &newItem)
 	})
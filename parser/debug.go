@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+	"github.com/rs/zerolog"
+)
+
+// DEBUG_BODY_MAX_SIZE is the number of bytes of the upstream bodies kept in debug captures
+const DEBUG_BODY_MAX_SIZE = 8192
+
+const DEBUG_REDACTED = "[redacted]"
+
+var DEBUG_REDACTED_HEADERS = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "Nyt-Token"}
+
+type UpstreamRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	// Proxy is the proxy the request was sent through, and where it is configured
+	Proxy           string      `json:"proxy,omitempty"`
+	Status          int         `json:"status,omitempty"`
+	QueuedMs        int64       `json:"queuedMs"`
+	DurationMs      int64       `json:"durationMs"`
+	RequestHeaders  http.Header `json:"requestHeaders"`
+	ResponseHeaders http.Header `json:"responseHeaders,omitempty"`
+	Body            string      `json:"body,omitempty"`
+	BodyTruncated   bool        `json:"bodyTruncated,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// DebugCapture records the upstream requests and warnings of a parse
+type DebugCapture struct {
+	mu       sync.Mutex
+	Requests []*UpstreamRequest `json:"requests"`
+	Warnings []string           `json:"warnings"`
+}
+
+type debugCaptureKey struct{}
+
+// warningsHook records the warnings logged during the parse
+type warningsHook func(msg string)
+
+func (h warningsHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if level < zerolog.WarnLevel {
+		return
+	}
+	h(msg)
+}
+
+// WarningsCollector records the warnings logged by the parses, without the
+// upstream requests of the debug captures
+type WarningsCollector struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (w *WarningsCollector) add(msg string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, msg)
+}
+
+// Warnings returns the warnings logged so far
+func (w *WarningsCollector) Warnings() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.warnings)
+}
+
+// WithWarnings returns a context collecting the warnings logged by the parses using it
+func WithWarnings(ctx context.Context) (context.Context, *WarningsCollector) {
+	collector := &WarningsCollector{}
+	logger := GetLogger(ctx).Hook(warningsHook(collector.add))
+	return logger.WithContext(ctx), collector
+}
+
+// WithDebugCapture returns a context capturing the upstream requests and the
+// warnings logged by the parses using it
+func WithDebugCapture(ctx context.Context) (context.Context, *DebugCapture) {
+	capture := &DebugCapture{Requests: []*UpstreamRequest{}, Warnings: []string{}}
+	ctx = context.WithValue(ctx, debugCaptureKey{}, capture)
+	logger := GetLogger(ctx).Hook(warningsHook(func(msg string) {
+		capture.mu.Lock()
+		defer capture.mu.Unlock()
+		capture.Warnings = append(capture.Warnings, msg)
+	}))
+	return logger.WithContext(ctx), capture
+}
+
+func GetDebugCapture(ctx context.Context) *DebugCapture {
+	capture, _ := ctx.Value(debugCaptureKey{}).(*DebugCapture)
+	return capture
+}
+
+type sensitiveResponseKey struct{}
+
+// WithSensitiveResponse marks the requests made with the context as returning
+// credentials, such as login tokens, their bodies being left out of the captures
+func WithSensitiveResponse(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sensitiveResponseKey{}, true)
+}
+
+func isSensitiveResponse(ctx context.Context) bool {
+	sensitive, _ := ctx.Value(sensitiveResponseKey{}).(bool)
+	return sensitive
+}
+
+func redactHeaders(h http.Header) http.Header {
+	redacted := h.Clone()
+	for _, name := range DEBUG_REDACTED_HEADERS {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, DEBUG_REDACTED)
+		}
+	}
+	return redacted
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureBody keeps the beginning of the response body while leaving it whole for the module
+func captureBody(r *UpstreamRequest, resp *http.Response) {
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, DEBUG_BODY_MAX_SIZE+1))
+	if len(body) > DEBUG_BODY_MAX_SIZE {
+		r.Body = string(body[:DEBUG_BODY_MAX_SIZE])
+		r.BodyTruncated = true
+	} else {
+		r.Body = string(body)
+	}
+	if readErr != nil {
+		r.Error = readErr.Error()
+	}
+	resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+}
+
+// record adds the request to the capture, along with the beginning of its
+// response body unless it is sensitive
+func (c *DebugCapture) record(req *http.Request, resp *http.Response, proxy string, queued time.Duration, duration time.Duration, err error) {
+	r := &UpstreamRequest{
+		Method:         req.Method,
+		Url:            req.URL.String(),
+		Proxy:          proxy,
+		QueuedMs:       queued.Milliseconds(),
+		DurationMs:     duration.Milliseconds(),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+	switch {
+	case err != nil:
+		r.Error = err.Error()
+	case isSensitiveResponse(req.Context()):
+		r.Status = resp.StatusCode
+		r.ResponseHeaders = redactHeaders(resp.Header)
+		r.Body = DEBUG_REDACTED
+	default:
+		r.Status = resp.StatusCode
+		r.ResponseHeaders = redactHeaders(resp.Header)
+		captureBody(r, resp)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Requests = append(c.Requests, r)
+}
+
+type DebugReport struct {
+	Module     string             `json:"module"`
+	Options    map[string]string  `json:"options"`
+	DurationMs int64              `json:"durationMs"`
+	Error      string             `json:"error,omitempty"`
+	Feed       json.RawMessage    `json:"feed,omitempty"`
+	Requests   []*UpstreamRequest `json:"requests"`
+	Warnings   []string           `json:"warnings"`
+}
+
+// NewDebugReport builds the report of a parse made with a debug capture
+func NewDebugReport(module string, values map[string]string, duration time.Duration, feed *feeds.Feed, o *Options, err error, capture *DebugCapture) *DebugReport {
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	report := &DebugReport{
+		Module:     module,
+		Options:    values,
+		DurationMs: duration.Milliseconds(),
+		Requests:   slices.Clone(capture.Requests),
+		Warnings:   slices.Clone(capture.Warnings),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if feed != nil {
+		if s, err := ToJSON(feed, o); err == nil {
+			report.Feed = json.RawMessage(s)
+		} else {
+			report.Error = err.Error()
+		}
+	}
+	return report
+}
+
+// DebugHandler serves the feeds as /feed/... would, along with the upstream
+// requests made and the warnings logged while parsing them
+func DebugHandler(matcher *RouteMatcher, token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(GetRequestToken(c)), []byte(token)) != 1 {
+			c.String(http.StatusUnauthorized, "invalid debug token")
+			return
+		}
+		u := *c.Request.URL
+		query := u.Query()
+		query.Del(TOKEN_PARAMETER)
+		u.RawQuery = query.Encode()
+		u.Path = "/feed" + c.Param("path")
+		u.RawPath = ""
+
+		match, err := matcher.Match(u.RequestURI())
+		if err != nil {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx, capture := WithDebugCapture(c.Request.Context())
+		start := time.Now()
+		feed, o, err := match.ParseWithContext(ctx)
+		c.JSON(http.StatusOK, NewDebugReport(match.Module, match.Values(), time.Since(start), feed, o, err, capture))
+	}
+}
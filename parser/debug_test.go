@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+)
+
+type debugTestParser struct {
+	upstream string
+}
+
+func (p debugTestParser) Parse(o *Options) (*feeds.Feed, error) {
+	resp, err := HttpGetWithContext(o.Context(), p.upstream, map[string]any{"headers": map[string]string{"Authorization": "secret"}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.Logger().Warn().Msg("unable to parse item date")
+	return &feeds.Feed{
+		Title: "debug",
+		Items: []*feeds.Item{{Title: "item", Content: string(body[:4]), Link: &feeds.Link{Href: "https://example.com"}}},
+	}, nil
+}
+func (debugTestParser) String() string { return "debugtest" }
+func (p debugTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestDebugHandler(t *testing.T) {
+	body := strings.Repeat("a", DEBUG_BODY_MAX_SIZE+10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	p := debugTestParser{upstream: upstream.URL}
+	r.GET("/api/debug/feed/*path", DebugHandler(NewRouteMatcher(map[string]Parser{"debugtest": p}), "t0k3n"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/debug/feed/debugtest/42?token=wrong", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status %d with an invalid token", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/debug/feed/debugtest/42?token=t0k3n", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	var report DebugReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Module != "debugtest" || report.Options["id"] != "42" || report.Error != "" {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if !strings.Contains(string(report.Feed), `"content_html":"aaaa"`) {
+		t.Errorf("unexpected feed %s", report.Feed)
+	}
+	if len(report.Requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(report.Requests))
+	}
+	req := report.Requests[0]
+	if req.Status != 200 || req.Url != upstream.URL || !req.BodyTruncated || len(req.Body) != DEBUG_BODY_MAX_SIZE {
+		t.Errorf("unexpected request %+v", req)
+	}
+	if req.RequestHeaders.Get("Authorization") != DEBUG_REDACTED || req.ResponseHeaders.Get("Set-Cookie") != DEBUG_REDACTED {
+		t.Errorf("sensitive headers should be redacted: %v %v", req.RequestHeaders, req.ResponseHeaders)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0] != "unable to parse item date" {
+		t.Errorf("unexpected warnings %v", report.Warnings)
+	}
+}
+
+func TestDebugSensitiveResponse(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"token":"secret"}`))
+	}))
+	defer upstream.Close()
+
+	ctx, capture := WithDebugCapture(context.Background())
+	resp, err := HttpGetWithContext(WithSensitiveResponse(ctx), upstream.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || string(body) != `{"token":"secret"}` {
+		t.Errorf("the module should read the whole body: %s %v", body, err)
+	}
+	if len(capture.Requests) != 1 || capture.Requests[0].Body != DEBUG_REDACTED || capture.Requests[0].Status != 200 {
+		t.Errorf("the sensitive bodies should be redacted: %+v", capture.Requests)
+	}
+}
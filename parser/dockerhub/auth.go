@@ -0,0 +1,80 @@
+package dockerhub
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const DOCKERHUB_LOGIN_URL = "https://hub.docker.com/v2/users/login"
+
+// DOCKERHUB_TOKEN_TTL is how long a login token is reused for
+const DOCKERHUB_TOKEN_TTL = 10 * time.Minute
+
+func (DockerHub) Settings() []parser.ModuleSetting {
+	return []parser.ModuleSetting{
+		{
+			Name:        "USERNAME",
+			Description: "Docker Hub username, authenticated requests getting higher rate limits",
+		},
+		{
+			Name:        "TOKEN",
+			Description: "Docker Hub personal access token of USERNAME",
+			Secret:      true,
+		},
+	}
+}
+
+var login struct {
+	mu       sync.Mutex
+	key      string
+	token    string
+	expireAt time.Time
+}
+
+// getAuthHeaders logs in with the configured credentials, returning the
+// headers of the authenticated requests, or nil without credentials
+func getAuthHeaders(options *parser.Options) (map[string]string, error) {
+	username, password := options.Setting("USERNAME"), options.Setting("TOKEN")
+	if username == "" || password == "" {
+		return nil, nil
+	}
+	login.mu.Lock()
+	defer login.mu.Unlock()
+	key := username + ":" + password
+	if login.key == key && time.Now().Before(login.expireAt) {
+		return map[string]string{"Authorization": "Bearer " + login.token}, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return nil, err
+	}
+	// the login token isn't to be shown by the debug captures
+	req, err := http.NewRequestWithContext(parser.WithSensitiveResponse(options.Context()), http.MethodPost, DOCKERHUB_LOGIN_URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := parser.HttpDo(&http.Client{}, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker hub login failed: %s", res.Status)
+	}
+	var r struct {
+		Token string `json:"token"`
+	}
+	if err := parser.DecodeJSON(options.Context(), res.Body, &r); err != nil {
+		return nil, err
+	}
+	login.key, login.token, login.expireAt = key, r.Token, time.Now().Add(DOCKERHUB_TOKEN_TTL)
+	return map[string]string{"Authorization": "Bearer " + r.Token}, nil
+}
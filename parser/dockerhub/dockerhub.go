@@ -1,14 +1,13 @@
 package dockerhub
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"time"
 
 	"strings"
 
-	"encoding/json"
-
 	"github.com/gorilla/feeds"
 	"github.com/nbr23/rss-banquet/parser"
 )
@@ -17,6 +16,34 @@ func (DockerHub) String() string {
 	return "dockerhub"
 }
 
+func init() {
+	parser.Register("dockerhub", DockerHubParser)
+}
+
+func (DockerHub) Description() string {
+	return "Docker Hub image tags updates"
+}
+
+func (DockerHub) Homepage() string {
+	return "https://hub.docker.com"
+}
+
+func (DockerHub) Category() string {
+	return "software"
+}
+
+func (DockerHub) Icon() string {
+	return "https://hub.docker.com/favicon.ico"
+}
+
+func (DockerHub) RefreshInterval() time.Duration {
+	return time.Hour
+}
+
+func (DockerHub) SampleOptions() map[string]string {
+	return map[string]string{"image": "nbr23/rss-banquet:latest"}
+}
+
 func (DockerHub) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -178,16 +205,16 @@ func parseDockerImage(imageName string) dockerImageName {
 	}
 }
 
-func getDockerTagImagesDetails(image dockerImageName) ([]dockerhubImage, error) {
+func getDockerTagImagesDetails(ctx context.Context, image dockerImageName, headers map[string]string) ([]dockerhubImage, error) {
 	var images []dockerhubImage
-	res, err := parser.HttpGet(fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags/%s", image, image.Tag), nil)
+	res, err := parser.HttpGetWithContext(ctx, fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags/%s", image, image.Tag), map[string]any{"headers": headers})
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	var dResponse dockerhubTag
-	err = json.NewDecoder(res.Body).Decode(&dResponse)
+	err = parser.DecodeJSON(ctx, res.Body, &dResponse)
 	if err != nil {
 		return nil, err
 	}
@@ -202,16 +229,16 @@ func getDockerTagImagesDetails(image dockerImageName) ([]dockerhubImage, error)
 	return images, nil
 }
 
-func getDockerTagsImages(image dockerImageName) ([]dockerhubImage, error) {
+func getDockerTagsImages(ctx context.Context, image dockerImageName, headers map[string]string) ([]dockerhubImage, error) {
 	var images []dockerhubImage
-	res, err := parser.HttpGet(fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags/?page_size=25&page=1&ordering=last_updated", image), nil)
+	res, err := parser.HttpGetWithContext(ctx, fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags/?page_size=25&page=1&ordering=last_updated", image), map[string]any{"headers": headers})
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	var dResponse dockerhubResponse
-	err = json.NewDecoder(res.Body).Decode(&dResponse)
+	err = parser.DecodeJSON(ctx, res.Body, &dResponse)
 	if err != nil {
 		return nil, err
 	}
@@ -248,16 +275,20 @@ func (DockerHub) Parse(options *parser.Options) (*feeds.Feed, error) {
 		platform = parsePlatform(options.Get("platform").(string))
 	}
 
+	headers, err := getAuthHeaders(options)
+	if err != nil {
+		options.Logger().Warn().Err(err).Msg("falling back to anonymous requests")
+	}
+
 	var images []dockerhubImage
-	var err error
 
 	if imageName.Tag != "" {
-		images, err = getDockerTagImagesDetails(imageName)
+		images, err = getDockerTagImagesDetails(options.Context(), imageName, headers)
 		if err != nil {
 			return nil, parser.NewNotFoundError("image not found")
 		}
 	} else {
-		images, err = getDockerTagsImages(imageName)
+		images, err = getDockerTagsImages(options.Context(), imageName, headers)
 		if err != nil {
 			return nil, parser.NewNotFoundError("tag not found")
 		}
@@ -286,7 +317,7 @@ func (DockerHub) Parse(options *parser.Options) (*feeds.Feed, error) {
 		}
 		imagePushed, err := time.Parse("2006-01-02T15:04:05.999999Z", i.LastPushed)
 		if err != nil {
-			fmt.Println("Error while parsing date :", err)
+			options.Logger().Warn().Err(err).Msgf("unable to parse the push date of %s", i.FullName.Pretty())
 			continue
 		}
 		item.Title = fmt.Sprintf("%s %s", i.FullName.Pretty(), i.Platform())
@@ -296,6 +327,11 @@ func (DockerHub) Parse(options *parser.Options) (*feeds.Feed, error) {
 		item.Id = fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(i.FullName.Pretty(), i.FullName, i.Os, i.Architecture, i.LastPushed))))
 		item.Updated = imagePushed
 		item.Created = imagePushed
+		options.SetAttribute(&item, "platform", parser.Platform(i.Platform()))
+		options.SetAttribute(&item, "digest", parser.Text(i.Digest))
+		if i.FullName.Tag != "" {
+			options.SetAttribute(&item, "tag", parser.Version(i.FullName.Tag))
+		}
 
 		if lastPushed.Before(imagePushed) {
 			lastPushed = imagePushed
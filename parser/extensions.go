@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/feeds"
+)
+
+// ItemExtensions holds the item metadata that gorilla/feeds can't carry
+type ItemExtensions struct {
+	Media      []*Media
+	Attributes Attributes
+	Categories []string
+}
+
+// FeedLink is a feed level link, such as the WebSub hub and self links
+type FeedLink struct {
+	Rel  string
+	Href string
+}
+
+type feedExtensions struct {
+	mu    sync.Mutex
+	items map[*feeds.Item]*ItemExtensions
+	links []FeedLink
+}
+
+var feedExtensionsInitMu sync.Mutex
+
+func (o *Options) feedExtensions() *feedExtensions {
+	feedExtensionsInitMu.Lock()
+	defer feedExtensionsInitMu.Unlock()
+	if o.extensions == nil {
+		o.extensions = &feedExtensions{items: make(map[*feeds.Item]*ItemExtensions)}
+	}
+	return o.extensions
+}
+
+// Extend returns the extensions attached to item, creating them if needed
+func (o *Options) Extend(item *feeds.Item) *ItemExtensions {
+	e := o.feedExtensions()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ext, ok := e.items[item]
+	if !ok {
+		ext = &ItemExtensions{}
+		e.items[item] = ext
+	}
+	return ext
+}
+
+// GetExtensions returns the extensions attached to item, or nil
+func (o *Options) GetExtensions(item *feeds.Item) *ItemExtensions {
+	if o == nil {
+		return nil
+	}
+	e := o.feedExtensions()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.items[item]
+}
+
+func (o *Options) AddMedia(item *feeds.Item, media ...*Media) {
+	ext := o.Extend(item)
+	ext.Media = append(ext.Media, media...)
+}
+
+func (o *Options) AddCategories(item *feeds.Item, categories ...string) {
+	ext := o.Extend(item)
+	for _, c := range categories {
+		c = strings.TrimSpace(c)
+		if c != "" && !slices.Contains(ext.Categories, c) {
+			ext.Categories = append(ext.Categories, c)
+		}
+	}
+}
+
+// AddImage attaches an image to the item, its type and size are resolved when serving the feed
+func (o *Options) AddImage(item *feeds.Item, url string) {
+	if url == "" {
+		return
+	}
+	o.AddMedia(item, &Media{Url: url, Medium: "image"})
+}
+
+func (o *Options) AddFeedLink(rel string, href string) {
+	e := o.feedExtensions()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.links = append(e.links, FeedLink{Rel: rel, Href: href})
+}
+
+func (o *Options) GetFeedLinks() []FeedLink {
+	if o == nil {
+		return nil
+	}
+	e := o.feedExtensions()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.links
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/feeds"
+)
+
+// FeedFilter holds the item filters that can be applied to any feed route
+type FeedFilter struct {
+	Attributes []AttributeFilter
+	Categories []string
+}
+
+// ParseFeedFilter reads the filters from the query parameters. The `category`
+// parameter sets the option of the same name of the modules having one, such
+// as lego, whose items are filtered with `categories`: the values such an
+// option doesn't accept are rejected rather than ignored.
+func ParseFeedFilter(query url.Values, o *Options) (*FeedFilter, error) {
+	var err error
+	f := &FeedFilter{}
+	f.Attributes, err = ParseAttributeFilters(query)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{"categories"}
+	i := slices.IndexFunc(o.OptionsList, func(option *Option) bool { return option.Flag == "category" })
+	if i < 0 {
+		keys = append(keys, "category")
+	} else if enum := o.OptionsList[i].Enum; len(enum) > 0 {
+		for _, value := range query["category"] {
+			if !slices.Contains(enum, value) {
+				return nil, fmt.Errorf("invalid category %s: the module category option expects one of %s, items are filtered with categories", value, strings.Join(enum, ", "))
+			}
+		}
+	}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			for _, category := range strings.Split(value, ",") {
+				if category = strings.TrimSpace(category); category != "" {
+					f.Categories = append(f.Categories, category)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// IsFilterParameter tells whether a query parameter is handled by the feed filters
+func IsFilterParameter(key string, o *Options) bool {
+	if strings.HasPrefix(key, ATTRIBUTE_FILTER_PREFIX) || key == "categories" {
+		return true
+	}
+	if key == "category" {
+		_, _, err := o.OptionsList.Get("category")
+		return err != nil
+	}
+	return false
+}
+
+func (f *FeedFilter) matchCategories(ext *ItemExtensions) bool {
+	if len(f.Categories) == 0 {
+		return true
+	}
+	if ext == nil {
+		return false
+	}
+	for _, wanted := range f.Categories {
+		for _, category := range ext.Categories {
+			if strings.EqualFold(wanted, category) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply drops the feed items that don't match the filter
+func (f *FeedFilter) Apply(feed *feeds.Feed, o *Options) {
+	if f == nil {
+		return
+	}
+	if len(f.Categories) > 0 {
+		items := []*feeds.Item{}
+		for _, item := range feed.Items {
+			if f.matchCategories(o.GetExtensions(item)) {
+				items = append(items, item)
+			}
+		}
+		feed.Items = items
+	}
+	FilterFeedItems(feed, o, f.Attributes)
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/feeds"
+)
+
+func TestFeedFilterAttributes(t *testing.T) {
+	o := &Options{}
+	cheap := &feeds.Item{Title: "cheap", Link: &feeds.Link{}}
+	expensive := &feeds.Item{Title: "expensive", Link: &feeds.Link{}}
+	unpriced := &feeds.Item{Title: "unpriced", Link: &feeds.Link{}}
+	o.SetAttribute(cheap, "price", Money{Amount: 10, Currency: "USD"})
+	o.SetAttribute(expensive, "price", Money{Amount: 100, Currency: "USD"})
+	f := &feeds.Feed{Title: "feed", Link: &feeds.Link{}, Items: []*feeds.Item{cheap, expensive, unpriced}}
+
+	o.AddCategories(expensive, "lego")
+	query, _ := url.ParseQuery("attr.price>5&categories=lego")
+	filter, err := ParseFeedFilter(query, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.Apply(f, o)
+	if len(f.Items) != 1 || f.Items[0] != expensive {
+		t.Errorf("the items should match both the categories and attribute filters: %v", f.Items)
+	}
+}
+
+func TestFeedFilterCategories(t *testing.T) {
+	o := &Options{}
+	xss := &feeds.Item{Title: "xss", Link: &feeds.Link{}}
+	ssrf := &feeds.Item{Title: "ssrf", Link: &feeds.Link{}}
+	o.AddCategories(xss, "XSS", "CSRF")
+	o.AddCategories(ssrf, "SSRF")
+	f := &feeds.Feed{Title: "feed", Link: &feeds.Link{}, Items: []*feeds.Item{xss, ssrf}}
+
+	query, _ := url.ParseQuery("category=xss,idor")
+	filter, err := ParseFeedFilter(query, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.Apply(f, o)
+	if len(f.Items) != 1 || f.Items[0] != xss {
+		t.Errorf("unexpected filtered items %v", f.Items)
+	}
+
+	rss, err := ToRss(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rss, "<category>XSS</category>") || !strings.Contains(rss, "<category>CSRF</category>") {
+		t.Errorf("rss output missing the categories:\n%s", rss)
+	}
+	atom, err := ToAtom(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(atom, `<category term="XSS"></category>`) {
+		t.Errorf("atom output missing the categories:\n%s", atom)
+	}
+	json, err := ToJSON(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(json, `"tags": [`) {
+		t.Errorf("json output missing the tags:\n%s", json)
+	}
+}
+
+func TestFeedFilterCategoryOption(t *testing.T) {
+	o := &Options{OptionsList: OptionsList{{Flag: "category", Type: "string", Default: "new"}}}
+	query, _ := url.ParseQuery("category=new&categories=xss")
+	filter, err := ParseFeedFilter(query, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(filter.Categories, []string{"xss"}) {
+		t.Errorf("the category module option should not be used as a filter, got %v", filter.Categories)
+	}
+
+	o.OptionsList[0].Enum = []string{"new", "coming-soon"}
+	query, _ = url.ParseQuery("category=xss")
+	if _, err := ParseFeedFilter(query, o); err == nil || !strings.Contains(err.Error(), "categories") {
+		t.Errorf("category values the option doesn't accept should point to categories: %v", err)
+	}
+}
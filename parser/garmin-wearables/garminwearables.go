@@ -1,6 +1,7 @@
 package garminwearables
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -15,6 +16,39 @@ func (GarminWearables) String() string {
 	return "garminwearables"
 }
 
+func init() {
+	parser.Register("garmin-wearables", GarminWearablesParser)
+}
+
+func (GarminWearables) Description() string {
+	return "Garmin wearables software updates"
+}
+
+func (GarminWearables) Homepage() string {
+	return "https://www.garmin.com"
+}
+
+func (GarminWearables) Category() string {
+	return "software"
+}
+
+func (GarminWearables) Icon() string {
+	return "https://www.garmin.com/favicon.ico"
+}
+
+func (GarminWearables) RefreshInterval() time.Duration {
+	return 12 * time.Hour
+}
+
+// each parse probes the release notes of the last two years
+func (GarminWearables) RateLimit() string {
+	return "30/1h"
+}
+
+func (GarminWearables) Politeness() parser.Politeness {
+	return parser.Politeness{Concurrency: 1, Delay: 250 * time.Millisecond}
+}
+
 func (GarminWearables) GetOptions() parser.Options {
 	return parser.Options{}
 }
@@ -30,7 +64,7 @@ func getReleaseNotes(s *goquery.Document) [][]string {
 	return nil
 }
 
-func BruteForcePossibleVersions() []*feeds.Item {
+func BruteForcePossibleVersions(ctx context.Context) []*feeds.Item {
 	now := time.Now()
 	year := now.Year()
 
@@ -43,7 +77,7 @@ func BruteForcePossibleVersions() []*feeds.Item {
 			var update feeds.Item
 			var err error
 			update.Link = &feeds.Link{Href: fmt.Sprintf(urlFormat, y, time.Month(m).String(), y)}
-			update.Created, err = parser.GetRemoteFileLastModified(update.Link.Href)
+			update.Created, err = parser.GetRemoteFileLastModified(ctx, update.Link.Href)
 			if err != nil {
 				continue
 			}
@@ -57,20 +91,20 @@ func BruteForcePossibleVersions() []*feeds.Item {
 	return items
 }
 
-func GetLatestVersions() ([]*feeds.Item, error) {
-	resp, err := parser.HttpGet("https://www.garmin.com/en-US/support/software/wearables/", nil)
+func GetLatestVersions(ctx context.Context) ([]*feeds.Item, error) {
+	resp, err := parser.HttpGetWithContext(ctx, "https://www.garmin.com/en-US/support/software/wearables/", nil)
 	items := []*feeds.Item{}
 
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("unable to fetch the Garmin Wearable updates page, status code: %d", resp.StatusCode)
 	}
 
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(ctx, resp.Body)
 
 	if err != nil {
 		return nil, err
@@ -86,7 +120,7 @@ func GetLatestVersions() ([]*feeds.Item, error) {
 			return nil, err
 		}
 
-		update.Created, err = parser.GetRemoteFileLastModified(releaseNote[0])
+		update.Created, err = parser.GetRemoteFileLastModified(ctx, releaseNote[0])
 		if err != nil {
 			return nil, err
 		}
@@ -110,11 +144,11 @@ func (GarminWearables) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	var items []*feeds.Item
 
-	items, err := GetLatestVersions()
+	items, err := GetLatestVersions(options.Context())
 
 	if err != nil {
-		fmt.Println("Falling back to brute force")
-		items = BruteForcePossibleVersions()
+		options.Logger().Warn().Err(err).Msg("unable to fetch the latest versions, falling back to brute force")
+		items = BruteForcePossibleVersions(options.Context())
 	}
 
 	if len(items) == 0 {
@@ -1,6 +1,7 @@
 package garminsdk
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -9,13 +10,36 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
 	"github.com/nbr23/rss-banquet/parser"
-	"github.com/rs/zerolog/log"
 )
 
 func (GarminSDK) String() string {
 	return "garminsdk"
 }
 
+func init() {
+	parser.Register("garmin-sdk", GarminSDKParser)
+}
+
+func (GarminSDK) Description() string {
+	return "Garmin FIT and Connect IQ SDKs releases"
+}
+
+func (GarminSDK) Homepage() string {
+	return "https://developer.garmin.com"
+}
+
+func (GarminSDK) Category() string {
+	return "software"
+}
+
+func (GarminSDK) Icon() string {
+	return "https://developer.garmin.com/favicon.ico"
+}
+
+func (GarminSDK) RefreshInterval() time.Duration {
+	return 12 * time.Hour
+}
+
 func (GarminSDK) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -25,6 +49,7 @@ func (GarminSDK) GetOptions() parser.Options {
 				Type:     "stringSlice",
 				Help:     "list of names of the sdks to watch: fit, connect-iq",
 				Default:  "fit",
+				Enum:     []string{"fit", "connect-iq"},
 			},
 		},
 		Parser: GarminSDK{},
@@ -53,24 +78,26 @@ func getDownloadButtons(s *goquery.Document) []*goquery.Selection {
 	return res
 }
 
-func getValidUrl(sdkName string) (string, *http.Response, error) {
+func getValidUrl(ctx context.Context, sdkName string) (string, *http.Response, error) {
 	url := fmt.Sprintf("https://developer.garmin.com/%s/download/", strings.ToLower(sdkName))
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(ctx, url, nil)
 	if err != nil {
-		log.Error().Msgf("unable to fetch the update page: %v", err)
+		parser.GetLogger(ctx).Error().Msgf("unable to fetch the update page: %v", err)
 		return "", nil, err
 	}
 	if resp.StatusCode == 404 {
+		resp.Body.Close()
 		url = fmt.Sprintf("https://developer.garmin.com/%s/sdk/", strings.ToLower(sdkName))
-		resp, err = parser.HttpGet(url, nil)
+		resp, err = parser.HttpGetWithContext(ctx, url, nil)
 	}
 	if err != nil {
 		return "", nil, err
 	}
 	if resp.StatusCode != 200 {
+		resp.Body.Close()
 		return "", nil, fmt.Errorf("unable to fetch the update page, status code: %d", resp.StatusCode)
 	}
-	log.Debug().Msg(fmt.Sprintf("fetched the update page %s", url))
+	parser.GetLogger(ctx).Debug().Msg(fmt.Sprintf("fetched the update page %s", url))
 	return url, resp, nil
 }
 
@@ -79,13 +106,13 @@ func (GarminSDK) Parse(options *parser.Options) (*feeds.Feed, error) {
 	var feed feeds.Feed
 
 	for _, sdkName := range sdkNames {
-		_, resp, err := getValidUrl(sdkName)
+		_, resp, err := getValidUrl(options.Context(), sdkName)
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
+		// closed before the next sdk, releasing its upstream request slot
+		doc, err := parser.DecodeHTML(options.Context(), resp.Body)
+		resp.Body.Close()
 		if err != nil {
 			return nil, err
 		}
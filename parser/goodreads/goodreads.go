@@ -1,6 +1,7 @@
 package goodreads
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
+	"github.com/nbr23/rss-banquet/metrics"
 	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/utils"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
 	"golang.org/x/text/language/display"
@@ -26,6 +29,39 @@ func (GoodReads) String() string {
 	return "goodreads"
 }
 
+func init() {
+	parser.Register("goodreads", GoodReadsParser)
+}
+
+func (GoodReads) Description() string {
+	return "Books of an author or series, from Goodreads"
+}
+
+func (GoodReads) Homepage() string {
+	return "https://www.goodreads.com"
+}
+
+func (GoodReads) Category() string {
+	return "books"
+}
+
+func (GoodReads) Icon() string {
+	return "https://www.goodreads.com/favicon.ico"
+}
+
+func (GoodReads) RefreshInterval() time.Duration {
+	return 24 * time.Hour
+}
+
+// each parse fetches the details of every book
+func (GoodReads) RateLimit() string {
+	return "30/1h"
+}
+
+func (GoodReads) Politeness() parser.Politeness {
+	return parser.Politeness{Concurrency: 2, Delay: 500 * time.Millisecond}
+}
+
 func GoodReadsParser() parser.Parser {
 	return GoodReads{}
 }
@@ -51,16 +87,52 @@ var EditionTypes = []string{
 	"Unknown Binding",
 }
 
+const BOOK_DETAILS_CACHE_FILE = "goodreads-books.json"
+
 type cachedBook struct {
-	book     *GRBook
-	expireAt time.Time
+	Book     *GRBook   `json:"book"`
+	ExpireAt time.Time `json:"expireAt"`
 }
 
 var (
-	bookDetailsCache   = make(map[string]cachedBook)
-	bookDetailsCacheMu sync.RWMutex
+	bookDetailsCache     = make(map[string]cachedBook)
+	bookDetailsCacheMu   sync.RWMutex
+	bookDetailsCacheLoad sync.Once
 )
 
+// loadBookDetailsCache restores the cache persisted by the last shutdown
+func loadBookDetailsCache(ctx context.Context) {
+	path := parser.GetCacheFile(BOOK_DETAILS_CACHE_FILE)
+	if path == "" {
+		return
+	}
+	cache := make(map[string]cachedBook)
+	if err := utils.ReadJSONFile(path, &cache); err != nil {
+		parser.GetLogger(ctx).Warn().Err(err).Msgf("unable to load the book details cache %s", path)
+		return
+	}
+	bookDetailsCacheMu.Lock()
+	defer bookDetailsCacheMu.Unlock()
+	for link, cached := range cache {
+		if _, ok := bookDetailsCache[link]; !ok {
+			bookDetailsCache[link] = cached
+		}
+	}
+}
+
+// Shutdown persists the book details cache
+func (GoodReads) Shutdown(ctx context.Context) error {
+	path := parser.GetCacheFile(BOOK_DETAILS_CACHE_FILE)
+	if path == "" {
+		return nil
+	}
+	// keep the persisted books when no feed was served
+	bookDetailsCacheLoad.Do(func() { loadBookDetailsCache(ctx) })
+	bookDetailsCacheMu.RLock()
+	defer bookDetailsCacheMu.RUnlock()
+	return utils.WriteJSONFile(path, bookDetailsCache)
+}
+
 func isReleased(publicationDate string) bool {
 	d, err := getDateFromPubDateErr(publicationDate)
 	if err != nil {
@@ -77,15 +149,15 @@ func isReleased(publicationDate string) bool {
 }
 
 // Grabs rudimentary book details from the editions page
-func getBookEditions(editionsUrl string) ([]*GRBook, error) {
-	resp, err := parser.HttpGet(editionsUrl, nil)
+func getBookEditions(ctx context.Context, editionsUrl string) ([]*GRBook, error) {
+	resp, err := parser.HttpGetWithContext(ctx, editionsUrl, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(ctx, resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -139,15 +211,15 @@ func getBookFormatFromPageFormat(pageformat string) string {
 	return ""
 }
 
-func getBookDetails(book *GRBook) (*GRBook, error) {
-	resp, err := parser.HttpGet(book.Link, nil)
+func getBookDetails(ctx context.Context, book *GRBook) (*GRBook, error) {
+	resp, err := parser.HttpGetWithContext(ctx, book.Link, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(ctx, resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +241,7 @@ func getBookDetails(book *GRBook) (*GRBook, error) {
 			var bookJson GRBookJson
 			err := json.Unmarshal([]byte(s.Text()), &bookJson)
 			if err != nil {
-				log.Error().Msg(fmt.Sprintf("unable to parse book json: %s", err.Error()))
+				parser.GetLogger(ctx).Error().Msg(fmt.Sprintf("unable to parse book json: %s", err.Error()))
 				return
 			}
 			book.Language = bookJson.InLanguage
@@ -179,23 +251,27 @@ func getBookDetails(book *GRBook) (*GRBook, error) {
 	return book, nil
 }
 
-func getBookDetailsCached(book *GRBook) (*GRBook, error) {
+func getBookDetailsCached(ctx context.Context, book *GRBook) (*GRBook, error) {
+	logger := parser.GetLogger(ctx)
+	bookDetailsCacheLoad.Do(func() { loadBookDetailsCache(ctx) })
 	bookDetailsCacheMu.RLock()
 	cached, ok := bookDetailsCache[book.Link]
 	bookDetailsCacheMu.RUnlock()
 	if ok {
-		if time.Now().After(cached.expireAt) {
+		if time.Now().After(cached.ExpireAt) {
 			bookDetailsCacheMu.Lock()
 			delete(bookDetailsCache, book.Link)
 			bookDetailsCacheMu.Unlock()
-			log.Info().Msg(fmt.Sprintf("Cache expired for %s", book.Link))
-		} else if isReleased(cached.book.PublicationDate) {
-			log.Info().Msg(fmt.Sprintf("Using cached book details for %s %s", book.Link, cached.book.PublicationDate))
-			return cached.book, nil
+			logger.Info().Msg(fmt.Sprintf("Cache expired for %s", book.Link))
+		} else if isReleased(cached.Book.PublicationDate) {
+			logger.Info().Msg(fmt.Sprintf("Using cached book details for %s %s", book.Link, cached.Book.PublicationDate))
+			metrics.CacheHit("goodreads_books")
+			return cached.Book, nil
 		}
 	}
-	log.Info().Msg(fmt.Sprintf("Fetching book details for %s %s", book.Link, book.PublicationDate))
-	b, err := getBookDetails(book)
+	metrics.CacheMiss("goodreads_books")
+	logger.Info().Msg(fmt.Sprintf("Fetching book details for %s %s", book.Link, book.PublicationDate))
+	b, err := getBookDetails(ctx, book)
 	if err != nil {
 		return nil, err
 	}
@@ -204,37 +280,38 @@ func getBookDetailsCached(book *GRBook) (*GRBook, error) {
 		maxDays := CACHE_EXPIRY_RANGE[1]
 		days := rand.Intn(maxDays-minDays+1) + minDays
 		expireAt := time.Now().Add(time.Duration(days) * 24 * time.Hour)
-		log.Info().Msg(fmt.Sprintf("Caching book details for %s %s, expires in %d days", b.Link, b.PublicationDate, days))
+		logger.Info().Msg(fmt.Sprintf("Caching book details for %s %s, expires in %d days", b.Link, b.PublicationDate, days))
 		bookDetailsCacheMu.Lock()
-		bookDetailsCache[book.Link] = cachedBook{book: b, expireAt: expireAt}
+		bookDetailsCache[book.Link] = cachedBook{Book: b, ExpireAt: expireAt}
 		bookDetailsCacheMu.Unlock()
 	} else {
-		log.Info().Msg(fmt.Sprintf("Not caching book details for %s %s", b.Link, b.PublicationDate))
+		logger.Info().Msg(fmt.Sprintf("Not caching book details for %s %s", b.Link, b.PublicationDate))
 	}
 	return b, nil
 }
 
-func getAuthorBooksList(authorId string, bookLanguage string, yearMin int, bookFormats []string) (string, string, []GRBook, error) {
+func getAuthorBooksList(ctx context.Context, authorId string, bookLanguage string, yearMin int, bookFormats []string) (string, string, []GRBook, error) {
 	url := fmt.Sprintf("https://www.goodreads.com/author/list/%s?utf8=%%E2%%9C%%93&sort=original_publication_year", authorId)
-	books, title, err := getBooksList(url, bookLanguage, yearMin, bookFormats)
+	books, title, err := getBooksList(ctx, url, bookLanguage, yearMin, bookFormats)
 	return url, title, books, err
 }
 
-func getSeriesBooksList(seriesId string, bookLanguage string, yearMin int, bookFormats []string) (string, string, []GRBook, error) {
+func getSeriesBooksList(ctx context.Context, seriesId string, bookLanguage string, yearMin int, bookFormats []string) (string, string, []GRBook, error) {
 	url := fmt.Sprintf("https://www.goodreads.com/series/%s", seriesId)
-	books, title, err := getBooksList(url, bookLanguage, yearMin, bookFormats)
+	books, title, err := getBooksList(ctx, url, bookLanguage, yearMin, bookFormats)
 	return url, title, books, err
 }
 
-func getBooksList(url string, bookLanguage string, yearMin int, bookFormats []string) ([]GRBook, string, error) {
-	resp, err := parser.HttpGet(url, nil)
+func getBooksList(ctx context.Context, url string, bookLanguage string, yearMin int, bookFormats []string) ([]GRBook, string, error) {
+	logger := parser.GetLogger(ctx)
+	resp, err := parser.HttpGetWithContext(ctx, url, nil)
 	if err != nil {
 		return nil, "", parser.NewInternalError("unable to fetch the page")
 	}
 
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(ctx, resp.Body)
 	if err != nil {
 		return nil, "", parser.NewInternalError("unable to parse the page")
 	}
@@ -269,16 +346,16 @@ func getBooksList(url string, bookLanguage string, yearMin int, bookFormats []st
 				pubYear = expectedRe.FindStringSubmatch(s.Text())[1]
 			}
 			if pubYear == "" {
-				log.Warn().Msg(fmt.Sprintf("No publication year found for %s", title))
+				logger.Warn().Msg(fmt.Sprintf("No publication year found for %s", title))
 				return
 			}
 			year, err := time.Parse("2006", pubYear)
 			if err != nil || year.Year() < yearMin {
-				log.Debug().Msg(fmt.Sprintf("Skipping book with year %d %s", year.Year(), book.Link))
+				logger.Debug().Msg(fmt.Sprintf("Skipping book with year %d %s", year.Year(), book.Link))
 				return
 			}
 		} else {
-			log.Debug().Msg(fmt.Sprintf("No publication year found for %s, grabbing more detail", title))
+			logger.Debug().Msg(fmt.Sprintf("No publication year found for %s, grabbing more detail", title))
 		}
 
 		var editionsUrl string
@@ -288,9 +365,9 @@ func getBooksList(url string, bookLanguage string, yearMin int, bookFormats []st
 		if editionsUrl != "" {
 			editionsUrl = fmt.Sprintf("https://www.goodreads.com%s", editionsUrl)
 
-			editions, err := getBookEditions(editionsUrl)
+			editions, err := getBookEditions(ctx, editionsUrl)
 			if err != nil {
-				log.Warn().Msg(fmt.Sprintf("unable to fetch book editions %s: %s", editionsUrl, err.Error()))
+				logger.Warn().Msg(fmt.Sprintf("unable to fetch book editions %s: %s", editionsUrl, err.Error()))
 				return
 			}
 			var earliestEdition *GRBook
@@ -298,22 +375,22 @@ func getBooksList(url string, bookLanguage string, yearMin int, bookFormats []st
 
 			for _, e := range editions {
 				if e.Language != bookLanguage {
-					log.Debug().Msg(fmt.Sprintf("Skipping edition with language %s %s", e.Language, e.Link))
+					logger.Debug().Msg(fmt.Sprintf("Skipping edition with language %s %s", e.Language, e.Link))
 					continue
 				}
 				if e.BookFormat == "" {
-					log.Debug().Msg(fmt.Sprintf("Skipping edition with missing format %s", e.Link))
+					logger.Debug().Msg(fmt.Sprintf("Skipping edition with missing format %s", e.Link))
 					continue
 				}
 				if !isAcceptedBookFormat(bookFormats, e.BookFormat) {
-					log.Debug().Msg(fmt.Sprintf("Skipping edition with format %s %s", e.BookFormat, e.Link))
+					logger.Debug().Msg(fmt.Sprintf("Skipping edition with format %s %s", e.BookFormat, e.Link))
 					continue
 				}
 
 				if e.PublicationDate != "" {
 					publicationDate, err := getDateFromPubDateErr(e.PublicationDate)
 					if err != nil {
-						log.Info().Msg(fmt.Sprintf("Skipping edition with invalid date %v %s", e.PublicationDate, e.Link))
+						logger.Info().Msg(fmt.Sprintf("Skipping edition with invalid date %v %s", e.PublicationDate, e.Link))
 						continue
 					}
 					if earliestEdition == nil || publicationDate.Before(earliestEditionDate) || publicationDate.Equal(earliestEditionDate) {
@@ -321,32 +398,32 @@ func getBooksList(url string, bookLanguage string, yearMin int, bookFormats []st
 						earliestEditionDate = publicationDate
 					}
 				} else {
-					log.Debug().Msg(fmt.Sprintf("Skipping edition with missing year %s", e.Link))
+					logger.Debug().Msg(fmt.Sprintf("Skipping edition with missing year %s", e.Link))
 				}
 			}
 
 			if earliestEdition != nil {
-				log.Debug().Msg(fmt.Sprintf("Substituting with earliest edition of book: %s", earliestEdition.Link))
+				logger.Debug().Msg(fmt.Sprintf("Substituting with earliest edition of book: %s", earliestEdition.Link))
 				book.Link = earliestEdition.Link
 				book.PublicationDate = earliestEdition.PublicationDate
 			}
 		}
 
-		book, err = getBookDetailsCached(book)
+		book, err = getBookDetailsCached(ctx, book)
 		if err != nil {
-			log.Error().Msg(fmt.Sprintf("unable to fetch book details: %s", err.Error()))
+			logger.Error().Msg(fmt.Sprintf("unable to fetch book details: %s", err.Error()))
 			return
 		}
 		if book.Language != bookLanguage {
-			log.Debug().Msg(fmt.Sprintf("Skipping book with language %s", book.Language))
+			logger.Debug().Msg(fmt.Sprintf("Skipping book with language %s", book.Language))
 			return
 		}
 		if !isAcceptedBookFormat(bookFormats, book.BookFormat) {
-			log.Debug().Msg(fmt.Sprintf("Skipping book with format %s", book.BookFormat))
+			logger.Debug().Msg(fmt.Sprintf("Skipping book with format %s", book.BookFormat))
 			return
 		}
 		if book.PublicationDate == "" || getDateFromPubDate(book.PublicationDate).Year() < yearMin {
-			log.Debug().Msg(fmt.Sprintf("Skipping book with year %s", book.PublicationDate))
+			logger.Debug().Msg(fmt.Sprintf("Skipping book with year %s", book.PublicationDate))
 			return
 		}
 		books = append(books, *book)
@@ -434,6 +511,18 @@ func getDateFromPubDateErr(publicationDate string) (time.Time, error) {
 	return time.Now(), fmt.Errorf("invalid publication date")
 }
 
+func (GoodReads) Validate(options *parser.Options) error {
+	if options.Get("authorId").(string) == "" && options.Get("seriesId").(string) == "" {
+		return fmt.Errorf("authorId or seriesId required")
+	}
+	if bookLanguage := options.Get("language").(string); bookLanguage != "" {
+		if _, err := getBookLanguage(bookLanguage); err != nil {
+			return fmt.Errorf("unknown language %s", bookLanguage)
+		}
+	}
+	return nil
+}
+
 func (GoodReads) Parse(options *parser.Options) (*feeds.Feed, error) {
 	authorId := options.Get("authorId").(string)
 	seriesId := options.Get("seriesId").(string)
@@ -455,9 +544,9 @@ func (GoodReads) Parse(options *parser.Options) (*feeds.Feed, error) {
 	var title string
 
 	if authorId != "" {
-		url, title, books, err = getAuthorBooksList(authorId, bookLanguage, yearMin, bookFormats)
+		url, title, books, err = getAuthorBooksList(options.Context(), authorId, bookLanguage, yearMin, bookFormats)
 	} else if seriesId != "" {
-		url, title, books, err = getSeriesBooksList(seriesId, bookLanguage, yearMin, bookFormats)
+		url, title, books, err = getSeriesBooksList(options.Context(), seriesId, bookLanguage, yearMin, bookFormats)
 	} else {
 		return nil, parser.NewNotFoundError("authorId or seriesId required")
 	}
@@ -478,17 +567,8 @@ func (GoodReads) Parse(options *parser.Options) (*feeds.Feed, error) {
 		item.Updated = item.Created
 		feed.Items = append(feed.Items, &item)
 
-		if book.CoverUrl != "" {
-			imgExt := parser.GetFileTypeFromUrl(book.CoverUrl)
-			if !parser.IsImageType(imgExt) {
-				imgExt = "png"
-			}
-			item.Enclosure = &feeds.Enclosure{
-				Url:    book.CoverUrl,
-				Type:   "image/" + imgExt,
-				Length: "0",
-			}
-		}
+		options.AddImage(&item, book.CoverUrl)
+		options.AddCategories(&item, book.BookFormat)
 	}
 
 	feed.Title = fmt.Sprintf("%s - %s", title, bookLanguage)
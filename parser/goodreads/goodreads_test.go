@@ -1,11 +1,15 @@
 package goodreads
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/nbr23/rss-banquet/config"
 	"github.com/nbr23/rss-banquet/testsuite"
+	"github.com/nbr23/rss-banquet/utils"
 )
 
 // Amélie Nothomb has been publishing yearly for 30 years. Don't break my tests!
@@ -24,3 +28,25 @@ func TestAmelieNothomb(t *testing.T) {
 		`^Books by Amélie Nothomb - French$`,
 	)
 }
+
+func TestShutdownKeepsPersistedCache(t *testing.T) {
+	dir := t.TempDir()
+	defer config.SetValues(map[string]string{"CACHE_DIR": config.GetConfigOption("CACHE_DIR")})
+	config.SetValues(map[string]string{"CACHE_DIR": dir})
+	path := filepath.Join(dir, BOOK_DETAILS_CACHE_FILE)
+	persisted := map[string]cachedBook{"https://www.goodreads.com/book/show/1": {Book: &GRBook{Title: "a"}, ExpireAt: time.Now().Add(time.Hour)}}
+	if err := utils.WriteJSONFile(path, persisted); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (GoodReads{}).Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	saved := make(map[string]cachedBook)
+	if err := utils.ReadJSONFile(path, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 {
+		t.Errorf("the persisted books should be kept, got %v", saved)
+	}
+}
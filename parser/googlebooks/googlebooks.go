@@ -2,11 +2,11 @@ package googlebooks
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -26,6 +26,35 @@ func (Googlebooks) String() string {
 	return "books"
 }
 
+func init() {
+	parser.Register("books", GooglebooksParser)
+}
+
+func (Googlebooks) Description() string {
+	return "Books of an author, from Google Books"
+}
+
+func (Googlebooks) Homepage() string {
+	return "https://books.google.com"
+}
+
+func (Googlebooks) Category() string {
+	return "books"
+}
+
+func (Googlebooks) Icon() string {
+	return "https://books.google.com/favicon.ico"
+}
+
+func (Googlebooks) RefreshInterval() time.Duration {
+	return 24 * time.Hour
+}
+
+// each parse fetches the pages of every book
+func (Googlebooks) RateLimit() string {
+	return "30/1h"
+}
+
 func GooglebooksParser() parser.Parser {
 	return Googlebooks{}
 }
@@ -68,8 +97,26 @@ func generateSOCSCookie() string {
 	return socsCookie
 }
 
-func fetchGoogleBooksPage(u string) (*http.Response, error) {
-	req, err := http.NewRequest(
+// SOCS_COOKIE_TTL is the lifetime of the consent cookie, as set by the consent page
+const SOCS_COOKIE_TTL = 390 * 24 * time.Hour
+
+// setConsentCookie sets the SOCS consent cookie in the session, once
+func setConsentCookie(session *parser.Session, u *url.URL) {
+	if session.Cookie(u, "SOCS") != "" {
+		return
+	}
+	session.SetCookies(u, []*http.Cookie{{
+		Name:    "SOCS",
+		Value:   generateSOCSCookie(),
+		Path:    "/",
+		Expires: time.Now().Add(SOCS_COOKIE_TTL),
+		Secure:  true,
+	}})
+}
+
+func fetchGoogleBooksPage(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		u,
 		nil,
@@ -80,23 +127,17 @@ func fetchGoogleBooksPage(u string) (*http.Response, error) {
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:129.0) Gecko/20100101 Firefox/129.0")
 	req.Header.Set("DNT", "1")
-	req.Header.Set("Cookie", fmt.Sprintf("SOCS=%s", generateSOCSCookie()))
 	req.Header.Set("Accept-Language", "en-GB,en;q=0.5")
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, nil
+	session := parser.GetContextSession(ctx)
+	setConsentCookie(session, req.URL)
+	return parser.HttpDo(&http.Client{Jar: session}, req)
 }
 
-func getBookDetailFromHtml(id string) (*book, error) {
+func getBookDetailFromHtml(ctx context.Context, id string) (*book, error) {
 	bookUrl := fmt.Sprintf("https://books.google.com/books?id=%s&redir_esc=y", id)
 
-	resp, err := fetchGoogleBooksPage(bookUrl)
+	resp, err := fetchGoogleBooksPage(ctx, bookUrl)
 
 	if err != nil {
 		return nil, err
@@ -110,12 +151,12 @@ func getBookDetailFromHtml(id string) (*book, error) {
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	bodyString := string(body)
 	bodyString = bodyString[strings.Index(bodyString, "</head>")+7:] + "</body>"
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(bodyString))
+	doc, err := parser.DecodeHTML(ctx, strings.NewReader(bodyString))
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +196,7 @@ func (Googlebooks) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	searchUrl := getSearchUrl(author, language, year_min, year_max)
 
-	resp, err := fetchGoogleBooksPage(searchUrl)
+	resp, err := fetchGoogleBooksPage(options.Context(), searchUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +212,7 @@ func (Googlebooks) Parse(options *parser.Options) (*feeds.Feed, error) {
 		authorQuery += url.QueryEscape("inauthor:") + url.QueryEscape(word) + "%20"
 	}
 	authorQuery = strings.TrimSuffix(authorQuery, "%20")
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(options.Context(), resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -191,9 +232,9 @@ func (Googlebooks) Parse(options *parser.Options) (*feeds.Feed, error) {
 	})
 
 	for _, bookId := range bookIds {
-		book, err := getBookDetailFromHtml(bookId)
+		book, err := getBookDetailFromHtml(options.Context(), bookId)
 		if err != nil {
-			log.Println(err)
+			options.Logger().Warn().Err(err).Msgf("unable to fetch the details of book %s", bookId)
 			continue
 		}
 
@@ -226,17 +267,7 @@ func (Googlebooks) Parse(options *parser.Options) (*feeds.Feed, error) {
 			item.Content = fmt.Sprintf("%s by %s announced for %s", book.Title, strings.Join(book.Authors, ", "), book.PublishedDate.Format("2006-01-02"))
 		}
 		item.Description = item.Content
-		imgExt := parser.GetFileTypeFromUrl(book.CoverUrl)
-		if !parser.IsImageType(imgExt) {
-			imgExt = "png"
-		}
-		if book.CoverUrl != "" {
-			item.Enclosure = &feeds.Enclosure{
-				Url:    book.CoverUrl,
-				Type:   "image/" + imgExt,
-				Length: "0",
-			}
-		}
+		options.AddImage(item, book.CoverUrl)
 		feed.Items = append(feed.Items, item)
 
 	}
@@ -246,6 +277,10 @@ func (Googlebooks) Parse(options *parser.Options) (*feeds.Feed, error) {
 	return &feed, nil
 }
 
+func (Googlebooks) SampleOptions() map[string]string {
+	return map[string]string{"author": "Amélie Nothomb"}
+}
+
 func (Googlebooks) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -2,10 +2,8 @@ package googlebooksapi
 
 import (
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
 	"strings"
 	"time"
@@ -20,6 +18,34 @@ func (Googlebooksapi) String() string {
 	return "googlebooksapi"
 }
 
+func init() {
+	parser.Register("googlebooksapi", GooglebooksapiParser)
+}
+
+func (Googlebooksapi) Description() string {
+	return "Books of an author, from the Google Books API"
+}
+
+func (Googlebooksapi) Homepage() string {
+	return "https://books.google.com"
+}
+
+func (Googlebooksapi) Category() string {
+	return "books"
+}
+
+func (Googlebooksapi) Icon() string {
+	return "https://books.google.com/favicon.ico"
+}
+
+func (Googlebooksapi) RefreshInterval() time.Duration {
+	return 24 * time.Hour
+}
+
+func (Googlebooksapi) SampleOptions() map[string]string {
+	return map[string]string{"author": "Amélie Nothomb"}
+}
+
 func (Googlebooksapi) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -82,24 +108,25 @@ func containsLoose(l []string, s string) bool {
 	return false
 }
 
-func listBooksByForYear(booksList map[string]*book, author, language string, year int) {
+func listBooksByForYear(options *parser.Options, booksList map[string]*book, author, language string, year int) error {
 	pageSize := 40
 	for page := 0; ; page++ {
 		url := fmt.Sprintf("https://www.googleapis.com/books/v1/volumes?q=inauthor:%%22%s%%22+%d&langRestrict=%s&printType=books&orderBy=relevance&showPreorders=true&maxResults=%d&startIndex=%d", url.QueryEscape(author), year, language, pageSize, page*pageSize)
 
-		res, err := parser.HttpGet(url, nil)
+		res, err := parser.HttpGetWithContext(options.Context(), url, nil)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		resBody, err := io.ReadAll(res.Body)
+		res.Body.Close()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		var volRes *volumesReponse
-		err = json.Unmarshal(resBody, &volRes)
+		err = parser.UnmarshalJSON(options.Context(), resBody, &volRes)
 
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		if len(volRes.Items) == 0 {
 			break
@@ -112,7 +139,7 @@ func listBooksByForYear(booksList map[string]*book, author, language string, yea
 			if containsLoose(volumeAuthors, author) && item.VolumeInfo.Language == language {
 				pubDate, err := dateparse.ParseStrict(item.VolumeInfo.PublishedDate)
 				if err != nil {
-					log.Println(err, item.VolumeInfo)
+					options.Logger().Warn().Err(err).Msgf("unable to parse the publication date of %s", item.VolumeInfo.Title)
 					continue
 				}
 
@@ -133,6 +160,7 @@ func listBooksByForYear(booksList map[string]*book, author, language string, yea
 			break
 		}
 	}
+	return nil
 }
 
 func (Googlebooksapi) Parse(options *parser.Options) (*feeds.Feed, error) {
@@ -146,7 +174,9 @@ func (Googlebooksapi) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	booksToSort := make(map[string]*book)
 	for year := year_min; year <= year_max; year++ {
-		listBooksByForYear(booksToSort, author, language, year)
+		if err := listBooksByForYear(options, booksToSort, author, language, year); err != nil {
+			return nil, err
+		}
 	}
 	for _, book := range booksToSort {
 		if book.PublishedDate.Year() < year_min || book.PublishedDate.Year() > year_max {
@@ -227,7 +227,8 @@ func hacktivityFeedQuery(options *parser.Options) (*http.Response, error) {
 	client := &http.Client{}
 	jsonValue, _ := json.Marshal(gql)
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		options.Context(),
 		"POST",
 		"https://hackerone.com/graphql",
 		strings.NewReader(string(jsonValue)),
@@ -239,5 +240,5 @@ func hacktivityFeedQuery(options *parser.Options) (*http.Response, error) {
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 	req.Header.Set("Content-Type", "application/json")
 
-	return client.Do(req)
+	return parser.HttpDo(client, req)
 }
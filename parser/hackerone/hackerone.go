@@ -1,7 +1,6 @@
 package hackerone
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -15,6 +14,30 @@ func (Hackerone) String() string {
 	return "hackerone"
 }
 
+func init() {
+	parser.Register("hackerone", HackeroneParser)
+}
+
+func (Hackerone) Description() string {
+	return "HackerOne Hacktivity reports"
+}
+
+func (Hackerone) Homepage() string {
+	return "https://hackerone.com"
+}
+
+func (Hackerone) Category() string {
+	return "security"
+}
+
+func (Hackerone) Icon() string {
+	return "https://hackerone.com/favicon.ico"
+}
+
+func (Hackerone) RefreshInterval() time.Duration {
+	return time.Hour
+}
+
 func (Hackerone) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -158,6 +181,19 @@ func buildItemContent(item *hackeroneItem) string {
 	return description
 }
 
+func setItemAttributes(newItem *feeds.Item, item *hackeroneItem, options *parser.Options) {
+	options.SetAttribute(newItem, "program", parser.Text(item.Team.Handle))
+	if item.SeverityRating != "" {
+		options.SetAttribute(newItem, "severity", parser.Text(item.SeverityRating))
+	}
+	if item.TotalAwardedAmount != 0 {
+		options.SetAttribute(newItem, "bounty", parser.Money{Amount: item.TotalAwardedAmount, Currency: item.Currency})
+	}
+	if item.Report.Substate != "" {
+		options.SetAttribute(newItem, "state", parser.Text(item.Report.Substate))
+	}
+}
+
 func feedAdapter(b *hackeroneFeed, options *parser.Options) (*feeds.Feed, error) {
 	feed := feeds.Feed{
 		Title:       options.Get("title").(string),
@@ -172,12 +208,12 @@ func feedAdapter(b *hackeroneFeed, options *parser.Options) (*feeds.Feed, error)
 		item := edge.Node
 		updatedAt, err := time.Parse(time.RFC3339, item.LatestDisclosableActivityAt)
 		if err != nil {
-			fmt.Println("error parsing date", err, item.LatestDisclosableActivityAt)
+			options.Logger().Warn().Err(err).Msgf("error parsing date %s", item.LatestDisclosableActivityAt)
 			continue
 		}
 		if item.Report.Url == "" {
 			if *options.Get("disclosed_only").(*bool) {
-				fmt.Printf("skipping disclosed item without a report url %v\n", item)
+				options.Logger().Warn().Msgf("skipping disclosed item without a report url %v", item)
 				continue
 			}
 			item.Report.Url = item.Team.Url
@@ -191,6 +227,7 @@ func feedAdapter(b *hackeroneFeed, options *parser.Options) (*feeds.Feed, error)
 			Id:          fmt.Sprint(updatedAt.Format(time.RFC3339), item.Id),
 			Updated:     updatedAt,
 		}
+		setItemAttributes(&newItem, &item, options)
 		feed.Items = append(feed.Items, &newItem)
 	}
 
@@ -220,7 +257,7 @@ func (Hackerone) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	var feed hackeroneFeed
 
-	if err := json.Unmarshal(data, &feed); err != nil {
+	if err := parser.UnmarshalJSON(options.Context(), data, &feed); err != nil {
 		return nil, err
 	}
 
@@ -141,7 +141,8 @@ func programsFeedQuery(options *parser.Options) (*http.Response, error) {
 	client := &http.Client{}
 	jsonValue, _ := json.Marshal(gql)
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		options.Context(),
 		"POST",
 		"https://hackerone.com/graphql",
 		strings.NewReader(string(jsonValue)),
@@ -153,5 +154,5 @@ func programsFeedQuery(options *parser.Options) (*http.Response, error) {
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 	req.Header.Set("Content-Type", "application/json")
 
-	return client.Do(req)
+	return parser.HttpDo(client, req)
 }
@@ -1,7 +1,6 @@
 package hackeronePrograms
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -15,6 +14,30 @@ func (HackeronePrograms) String() string {
 	return "hackeroneprograms"
 }
 
+func init() {
+	parser.Register("hackeronePrograms", HackeroneProgramsParser)
+}
+
+func (HackeronePrograms) Description() string {
+	return "HackerOne programs launches"
+}
+
+func (HackeronePrograms) Homepage() string {
+	return "https://hackerone.com"
+}
+
+func (HackeronePrograms) Category() string {
+	return "security"
+}
+
+func (HackeronePrograms) Icon() string {
+	return "https://hackerone.com/favicon.ico"
+}
+
+func (HackeronePrograms) RefreshInterval() time.Duration {
+	return 6 * time.Hour
+}
+
 func (HackeronePrograms) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -140,7 +163,7 @@ func feedAdapter(b *hackeroneProgramFeed, options *parser.Options) (*feeds.Feed,
 	for _, item := range b.Data.OpportunitiesSearch.Nodes {
 		updatedAt, err := time.Parse(time.RFC3339, item.LaunchedAt)
 		if err != nil {
-			fmt.Println("error parsing date", err, item.LaunchedAt)
+			options.Logger().Warn().Err(err).Msgf("error parsing date %s", item.LaunchedAt)
 			continue
 		}
 		newItem := feeds.Item{
@@ -152,6 +175,10 @@ func feedAdapter(b *hackeroneProgramFeed, options *parser.Options) (*feeds.Feed,
 			Id:          fmt.Sprint(updatedAt.Format(time.RFC3339), item.Id),
 			Updated:     updatedAt,
 		}
+		options.AddCategories(&newItem, getProgramType(&item))
+		if item.OffersBounties {
+			options.AddCategories(&newItem, "Bounty")
+		}
 		feed.Items = append(feed.Items, &newItem)
 	}
 
@@ -181,7 +208,7 @@ func (HackeronePrograms) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	var feed hackeroneProgramFeed
 
-	if err := json.Unmarshal(data, &feed); err != nil {
+	if err := parser.UnmarshalJSON(options.Context(), data, &feed); err != nil {
 		return nil, err
 	}
 
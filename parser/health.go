@@ -0,0 +1,233 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var CANARY_TIMEOUT = 60 * time.Second
+
+const CANARY_CONCURRENCY = 4
+
+// SampleOptionsProvider is implemented by the modules declaring the options
+// to use for canary parses, when their defaults aren't enough
+type SampleOptionsProvider interface {
+	SampleOptions() map[string]string
+}
+
+// RateLimitProvider is implemented by the expensive modules, declaring a
+// stricter default rate limit per client, such as "30/1h"
+type RateLimitProvider interface {
+	RateLimit() string
+}
+
+type ParseOutcome struct {
+	Time       time.Time `json:"time"`
+	Feed       string    `json:"feed,omitempty"`
+	Status     string    `json:"status"`
+	DurationMs int64     `json:"durationMs"`
+	Items      int       `json:"items"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"errorClass,omitempty"`
+}
+
+type ModuleHealth struct {
+	Status    string        `json:"status"`
+	LastParse *ParseOutcome `json:"lastParse,omitempty"`
+	Canary    *ParseOutcome `json:"canary,omitempty"`
+}
+
+var (
+	lastParses   = make(map[string]*ParseOutcome)
+	lastParsesMu sync.RWMutex
+)
+
+// GetErrorClass categorizes parse errors for health reports
+func GetErrorClass(err error) string {
+	var notFound *NotFoundError
+	var internal *InternalError
+	var badRequest *BadRequestError
+	var netErr net.Error
+	var breakage *BreakageError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "network"
+	case errors.As(err, &notFound):
+		return "not_found"
+	case errors.As(err, &internal):
+		return "internal"
+	case errors.As(err, &badRequest):
+		return "invalid"
+	case errors.As(err, &breakage):
+		return "breakage"
+	default:
+		return "parse"
+	}
+}
+
+func newParseOutcome(feed string, duration time.Duration, items int, err error) *ParseOutcome {
+	o := &ParseOutcome{
+		Time:       time.Now(),
+		Feed:       feed,
+		Status:     "ok",
+		DurationMs: duration.Milliseconds(),
+		Items:      items,
+	}
+	if err != nil {
+		o.Status = "error"
+		if _, ok := err.(*BreakageError); ok {
+			// the feed was served, but likely with wrong or missing content
+			o.Status = "broken"
+		}
+		o.Error = err.Error()
+		o.ErrorClass = GetErrorClass(err)
+	}
+	return o
+}
+
+// isClientError tells the errors caused by the requested options rather than by the module
+func isClientError(err error) bool {
+	var notFound *NotFoundError
+	var badRequest *BadRequestError
+	return errors.As(err, &notFound) || errors.As(err, &badRequest)
+}
+
+// RecordParse stores the outcome of the last parse of the module. Client
+// errors, such as a mistyped image, aren't module failures and are ignored.
+func RecordParse(module string, feed string, duration time.Duration, items int, err error) {
+	if isClientError(err) {
+		return
+	}
+	lastParsesMu.Lock()
+	defer lastParsesMu.Unlock()
+	lastParses[module] = newParseOutcome(feed, duration, items, err)
+}
+
+func GetLastParse(module string) *ParseOutcome {
+	lastParsesMu.RLock()
+	defer lastParsesMu.RUnlock()
+	return lastParses[module]
+}
+
+// GetSampleOptions returns the options of a canary parse, or false if the
+// module has required options without default nor sample value
+func GetSampleOptions(p Parser) (*Options, bool) {
+	var sample map[string]string
+	if s, ok := p.(SampleOptionsProvider); ok {
+		sample = s.SampleOptions()
+	}
+	o, err := NewOptions(p, sample)
+	if err != nil {
+		return nil, false
+	}
+	for _, option := range o.OptionsList {
+		if option.Required && option.Default == "" && sample[option.Flag] == "" {
+			return nil, false
+		}
+	}
+	return o, true
+}
+
+// RunCanary parses the module with its sample options
+func RunCanary(p Parser) *ParseOutcome {
+	o, ok := GetSampleOptions(p)
+	if !ok {
+		return &ParseOutcome{Time: time.Now(), Status: "skipped", Error: "no sample options"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CANARY_TIMEOUT)
+	defer cancel()
+	o.SetContext(ctx)
+
+	type result struct {
+		items int
+		err   error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	// the upstream requests are cancelled on timeout, the parse then returns
+	// in the background
+	go func() {
+		feed, err := ParseFeed(p, o, nil)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{items: len(feed.Items)}
+	}()
+	select {
+	case r := <-done:
+		return newParseOutcome("", time.Since(start), r.items, r.err)
+	case <-ctx.Done():
+		return newParseOutcome("", time.Since(start), 0, context.DeadlineExceeded)
+	}
+}
+
+// GetModulesHealth reports the health of the parsers, keyed by their name,
+// running canary parses if requested
+func GetModulesHealth(parsers []Parser, canary bool) map[string]*ModuleHealth {
+	health := make(map[string]*ModuleHealth, len(parsers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, CANARY_CONCURRENCY)
+
+	for _, p := range parsers {
+		h := &ModuleHealth{LastParse: GetLastParse(p.String())}
+		health[p.String()] = h
+		if !canary {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			outcome := RunCanary(p)
+			mu.Lock()
+			h.Canary = outcome
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, h := range health {
+		switch {
+		case h.Canary != nil && h.Canary.Status != "skipped":
+			h.Status = h.Canary.Status
+		case h.LastParse != nil:
+			h.Status = h.LastParse.Status
+		default:
+			h.Status = "unknown"
+		}
+	}
+	return health
+}
+
+// GetReadiness aggregates the modules health: ok when no module is failing,
+// degraded when some are, down when all of them are
+func GetReadiness(health map[string]*ModuleHealth) string {
+	failing := 0
+	for _, h := range health {
+		if h.Status == "error" || h.Status == "broken" {
+			failing++
+		}
+	}
+	switch {
+	case failing == 0:
+		return "ok"
+	case failing == len(health):
+		return "down"
+	default:
+		return "degraded"
+	}
+}
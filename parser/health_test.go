@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+type healthTestParser struct {
+	name     string
+	err      error
+	required bool
+}
+
+func (p healthTestParser) Parse(*Options) (*feeds.Feed, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &feeds.Feed{Items: []*feeds.Item{{Title: "item"}}}, nil
+}
+func (p healthTestParser) String() string { return p.name }
+func (p healthTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string", Required: p.required}}, Parser: p}
+}
+
+func TestGetModulesHealth(t *testing.T) {
+	parsers := []Parser{
+		healthTestParser{name: "healthok"},
+		healthTestParser{name: "healthbroken", err: NewNotFoundError("gone")},
+		healthTestParser{name: "healthskipped", required: true},
+	}
+	RecordParse("healthskipped", "/feed/healthskipped/1", time.Second, 0, fmt.Errorf("unexpected markup"))
+
+	health := GetModulesHealth(parsers, false)
+	if health["healthok"].Status != "unknown" || health["healthskipped"].Status != "error" {
+		t.Errorf("unexpected health without canary: %s %s", health["healthok"].Status, health["healthskipped"].Status)
+	}
+
+	health = GetModulesHealth(parsers, true)
+	if h := health["healthok"]; h.Status != "ok" || h.Canary.Items != 1 {
+		t.Errorf("unexpected healthok health %+v", h.Canary)
+	}
+	if h := health["healthbroken"]; h.Status != "error" || h.Canary.ErrorClass != "not_found" {
+		t.Errorf("unexpected healthbroken health %+v", h.Canary)
+	}
+	if h := health["healthskipped"]; h.Canary.Status != "skipped" || h.Status != "error" || h.LastParse.ErrorClass != "parse" {
+		t.Errorf("unexpected healthskipped health %+v", h)
+	}
+	if got := GetReadiness(health); got != "degraded" {
+		t.Errorf("GetReadiness() = %s, want degraded", got)
+	}
+	if got := GetReadiness(map[string]*ModuleHealth{"a": {Status: "error"}, "b": {Status: "unknown"}}); got != "degraded" {
+		t.Errorf("GetReadiness() = %s, want degraded", got)
+	}
+	if got := GetReadiness(map[string]*ModuleHealth{"a": {Status: "error"}, "b": {Status: "broken"}}); got != "down" {
+		t.Errorf("GetReadiness() = %s, want down", got)
+	}
+}
+
+func TestRecordParseClientErrors(t *testing.T) {
+	RecordParse("healthclient", "/feed/healthclient/1", time.Second, 3, nil)
+	RecordParse("healthclient", "/feed/healthclient/typo", time.Second, 0, NewNotFoundError("no such image"))
+	RecordParse("healthclient", "/feed/healthclient/1?count=x", time.Second, 0, NewBadRequestError("count must be a number"))
+	if last := GetLastParse("healthclient"); last.Status != "ok" || last.Feed != "/feed/healthclient/1" {
+		t.Errorf("client errors shouldn't be module failures: %+v", last)
+	}
+
+	RecordParse("healthfresh", "/feed/healthfresh/typo", time.Second, 0, NewNotFoundError("no such image"))
+	health := GetModulesHealth([]Parser{healthTestParser{name: "healthfresh"}}, false)
+	if got := GetReadiness(health); got != "ok" {
+		t.Errorf("a client error on a fresh instance shouldn't fail the readiness: %s", got)
+	}
+}
+
+type slowCanaryParser struct {
+	cancelled chan bool
+}
+
+func (p slowCanaryParser) Parse(o *Options) (*feeds.Feed, error) {
+	select {
+	case <-o.Context().Done():
+		p.cancelled <- true
+	case <-time.After(time.Second):
+		p.cancelled <- false
+	}
+	return nil, o.Context().Err()
+}
+func (slowCanaryParser) String() string { return "slowcanary" }
+func (p slowCanaryParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string"}}, Parser: p}
+}
+
+func TestRunCanaryTimeout(t *testing.T) {
+	timeout := CANARY_TIMEOUT
+	CANARY_TIMEOUT = 20 * time.Millisecond
+	defer func() { CANARY_TIMEOUT = timeout }()
+
+	p := slowCanaryParser{cancelled: make(chan bool, 1)}
+	if outcome := RunCanary(p); outcome.ErrorClass != "timeout" {
+		t.Errorf("unexpected canary outcome %+v", outcome)
+	}
+	if !<-p.cancelled {
+		t.Errorf("the timed out canary parse should be cancelled")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
 	"github.com/nbr23/rss-banquet/parser"
 )
@@ -16,6 +15,30 @@ func (InfoCon) String() string {
 	return "infocon"
 }
 
+func init() {
+	parser.Register("infocon", InfoConParser)
+}
+
+func (InfoCon) Description() string {
+	return "Conference talks recordings published on InfoCon"
+}
+
+func (InfoCon) Homepage() string {
+	return "https://infocon.org"
+}
+
+func (InfoCon) Category() string {
+	return "security"
+}
+
+func (InfoCon) Icon() string {
+	return "https://infocon.org/favicon.ico"
+}
+
+func (InfoCon) RefreshInterval() time.Duration {
+	return 24 * time.Hour
+}
+
 func (InfoCon) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -42,7 +65,7 @@ func (InfoCon) Parse(options *parser.Options) (*feeds.Feed, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error unescaping url")
 	}
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), url, nil)
 	regexesIgnore := []*regexp.Regexp{
 		regexp.MustCompile(`Thumbs\.db`),
 		regexp.MustCompile(`.*\.jpg`),
@@ -56,7 +79,7 @@ func (InfoCon) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(options.Context(), resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -6,17 +6,40 @@ import (
 	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
-
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
 	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/utils"
 )
 
 func (Lego) String() string {
 	return "lego"
 }
 
+func init() {
+	parser.Register("lego", LegoParser)
+}
+
+func (Lego) Description() string {
+	return "New and coming soon LEGO sets"
+}
+
+func (Lego) Homepage() string {
+	return "https://www.lego.com"
+}
+
+func (Lego) Category() string {
+	return "shopping"
+}
+
+func (Lego) Icon() string {
+	return "https://www.lego.com/favicon.ico"
+}
+
+func (Lego) RefreshInterval() time.Duration {
+	return 6 * time.Hour
+}
+
 func (Lego) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -26,6 +49,7 @@ func (Lego) GetOptions() parser.Options {
 				Type:     "string",
 				Help:     "category of the lego products (new, coming-soon)",
 				Default:  "new",
+				Enum:     []string{"new", "coming-soon"},
 			},
 		},
 		Parser: Lego{},
@@ -40,7 +64,7 @@ type legoItem struct {
 	AgeRange         string
 	PieceCount       string
 	AvailabilityText string
-	ImgUrl           string
+	ImgUrls          []string
 }
 
 func getLegoProductUrl(l *legoItem) string {
@@ -128,7 +152,7 @@ func feedAdapter(items []legoItem, options *parser.Options) (*feeds.Feed, error)
 
 	for _, item := range items {
 		if item.ProductCode == "" && item.Name == "" {
-			log.Warn().Msgf("Skipping item with empty product code and name %v", item)
+			options.Logger().Warn().Msgf("Skipping item with empty product code and name %v", item)
 			continue
 		}
 		newItem := feeds.Item{
@@ -138,14 +162,8 @@ func feedAdapter(items []legoItem, options *parser.Options) (*feeds.Feed, error)
 			Link:        &feeds.Link{Href: getLegoProductUrl(&item)},
 			Id:          guid(&item, feed),
 		}
-		imgExt := parser.GetFileTypeFromUrl(item.ImgUrl)
-		if !parser.IsImageType(imgExt) {
-			imgExt = "png"
-		}
-		newItem.Enclosure = &feeds.Enclosure{
-			Url:    item.ImgUrl,
-			Type:   "image/" + imgExt,
-			Length: "0",
+		for _, imgUrl := range item.ImgUrls {
+			options.AddImage(&newItem, imgUrl)
 		}
 		feed.Items = append(feed.Items, &newItem)
 	}
@@ -167,7 +185,7 @@ func getSlug(options *parser.Options) string {
 }
 
 func (Lego) Parse(options *parser.Options) (*feeds.Feed, error) {
-	resp, err := parser.HttpGet(getUrl(options), nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), getUrl(options), nil)
 
 	if err != nil {
 		return nil, err
@@ -179,7 +197,7 @@ func (Lego) Parse(options *parser.Options) (*feeds.Feed, error) {
 		return nil, fmt.Errorf("unable to fetch the product page, status code: %d", resp.StatusCode)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(options.Context(), resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -212,7 +230,12 @@ func (Lego) Parse(options *parser.Options) (*feeds.Feed, error) {
 		if l.AvailabilityText == "Coming Soon" && options.Get("category") == "new" {
 			return
 		}
-		l.ImgUrl = strings.Split(s.Find("ul[data-test=product-leaf-image-wrapper]").First().Find("source").First().AttrOr("srcset", ""), " ")[0]
+		s.Find("ul[data-test=product-leaf-image-wrapper]").First().Find("li").Each(func(i int, s *goquery.Selection) {
+			imgUrl := strings.Split(s.Find("source").First().AttrOr("srcset", ""), " ")[0]
+			if imgUrl != "" {
+				l.ImgUrls = utils.InsertUnique(l.ImgUrls, imgUrl)
+			}
+		})
 		products = append(products, l)
 	})
 
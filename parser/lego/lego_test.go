@@ -46,3 +46,15 @@ func TestLegoParseComingSoon(t *testing.T) {
 		``,
 	)
 }
+
+func TestLegoBadCategory(t *testing.T) {
+	o := parser.GetFullOptions(Lego{})
+	for _, option := range o.OptionsList {
+		if option.Flag == "category" {
+			option.Value = "old"
+		}
+	}
+	if err := parser.ValidateOptions(Lego{}, o); err == nil {
+		t.Errorf("the categories out of the option enum should be rejected")
+	}
+}
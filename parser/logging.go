@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// REQUEST_ID_MAX_LENGTH bounds the length of the request ids taken from the clients
+const REQUEST_ID_MAX_LENGTH = 128
+
+type requestIdKey struct{}
+
+// GetLogger returns the logger of the context, or the global logger
+func GetLogger(ctx context.Context) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
+
+// WithLogFields returns a context whose logger adds the fields to its events
+func WithLogFields(ctx context.Context, fields map[string]string) context.Context {
+	l := GetLogger(ctx).With()
+	for k, v := range fields {
+		l = l.Str(k, v)
+	}
+	logger := l.Logger()
+	return logger.WithContext(ctx)
+}
+
+func NewRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isValidRequestId(id string) bool {
+	if id == "" || len(id) > REQUEST_ID_MAX_LENGTH {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestId returns a context carrying the request id, logged by its logger
+func WithRequestId(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return WithLogFields(ctx, map[string]string{"request_id": id})
+}
+
+func GetRequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// RequestIdMiddleware takes the request id from the X-Request-ID header, or
+// generates one, and adds it to the response and the request context
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(REQUEST_ID_HEADER)
+		if !isValidRequestId(id) {
+			id = NewRequestId()
+		}
+		c.Header(REQUEST_ID_HEADER, id)
+		c.Request = c.Request.WithContext(WithRequestId(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type loggingTestParser struct{}
+
+func (loggingTestParser) Parse(o *Options) (*feeds.Feed, error) {
+	o.Logger().Warn().Msg("skipping item")
+	return &feeds.Feed{Title: "test", Link: &feeds.Link{Href: "https://example.com"}}, nil
+}
+func (loggingTestParser) String() string { return "loggingtest" }
+func (p loggingTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestRequestIdLogging(t *testing.T) {
+	var buf bytes.Buffer
+	defaultLogger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = defaultLogger }()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIdMiddleware())
+	p := loggingTestParser{}
+	Route(r, p, GetFullOptions(p))
+
+	req := httptest.NewRequest(http.MethodGet, "/feed/loggingtest/42", nil)
+	req.Header.Set(REQUEST_ID_HEADER, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(REQUEST_ID_HEADER); got != "abc-123" {
+		t.Errorf("response request id = %q, want abc-123", got)
+	}
+
+	var event map[string]string
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		t.Fatalf("unable to decode log %q: %s", line, err)
+	}
+	want := map[string]string{"request_id": "abc-123", "module": "loggingtest", "feed": "/feed/loggingtest/42", "message": "skipping item"}
+	for k, v := range want {
+		if event[k] != v {
+			t.Errorf("log field %s = %q, want %q", k, event[k], v)
+		}
+	}
+
+	// invalid request ids are replaced
+	req = httptest.NewRequest(http.MethodGet, "/feed/loggingtest/42", nil)
+	req.Header.Set(REQUEST_ID_HEADER, "bad id")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(REQUEST_ID_HEADER); len(got) != 32 {
+		t.Errorf("response request id = %q, want a generated one", got)
+	}
+}
@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+func TestMain(m *testing.M) {
+	// keep the baselines of the served test feeds in memory
+	config.SetValues(map[string]string{"BASELINES_FILE": ""})
+	os.Exit(m.Run())
+}
+
+// setConfigOption sets a config option until the end of the test
+func setConfigOption(t *testing.T, name string, value string) {
+	old := config.GetConfigOption(name)
+	config.SetValues(map[string]string{name: value})
+	t.Cleanup(func() { config.SetValues(map[string]string{name: old}) })
+}
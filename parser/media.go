@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/metrics"
+)
+
+const MEDIA_RESOLVE_CONCURRENCY = 8
+const MEDIA_CACHE_MAX_SIZE = 10000
+
+type Media struct {
+	Url    string
+	Type   string // MIME type, resolved from the upstream if empty
+	Length int64  // size in bytes, resolved from the upstream if 0
+	Medium string // image, video, audio, document
+	Width  int
+	Height int
+	Title  string
+}
+
+func (m *Media) IsImage() bool {
+	return m.Medium == "image" || strings.HasPrefix(m.Type, "image/")
+}
+
+type mediaInfo struct {
+	Type   string
+	Length int64
+}
+
+var (
+	mediaInfoCache   = make(map[string]mediaInfo)
+	mediaInfoCacheMu sync.RWMutex
+)
+
+// MediaTypeFromUrl guesses the MIME type of a resource from its url extension
+func MediaTypeFromUrl(u string) string {
+	p := u
+	if parsed, err := url.Parse(u); err == nil {
+		p = parsed.Path
+	}
+	ext := strings.ToLower(path.Ext(p))
+	if ext == "" {
+		return ""
+	}
+	t := mime.TypeByExtension(ext)
+	if t == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+func mediumFromType(t string) string {
+	switch {
+	case strings.HasPrefix(t, "image/"):
+		return "image"
+	case strings.HasPrefix(t, "video/"):
+		return "video"
+	case strings.HasPrefix(t, "audio/"):
+		return "audio"
+	case t != "":
+		return "document"
+	default:
+		return ""
+	}
+}
+
+func parseMediaResponse(resp *http.Response) mediaInfo {
+	var info mediaInfo
+	if t, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		info.Type = t
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Range: bytes 0-0/12345
+		cr := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(cr, "/"); i != -1 {
+			if l, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				info.Length = l
+			}
+		}
+	} else if resp.ContentLength > 0 {
+		info.Length = resp.ContentLength
+	}
+	return info
+}
+
+func fetchMediaInfo(ctx context.Context, u string) (mediaInfo, error) {
+	resp, err := HttpGetWithContext(ctx, u, map[string]any{"method": http.MethodHead})
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			info := parseMediaResponse(resp)
+			if info.Type != "" && info.Length > 0 {
+				return info, nil
+			}
+		}
+	}
+
+	// some CDNs don't answer HEAD requests properly, ask for the first byte instead
+	resp, err = HttpGetWithContext(ctx, u, map[string]any{"headers": map[string]string{"Range": "bytes=0-0"}})
+	if err != nil {
+		return mediaInfo{}, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return mediaInfo{}, fmt.Errorf("unable to fetch media %s, status code: %d", u, resp.StatusCode)
+	}
+	return parseMediaResponse(resp), nil
+}
+
+func getMediaInfoCached(ctx context.Context, u string) (mediaInfo, error) {
+	mediaInfoCacheMu.RLock()
+	info, ok := mediaInfoCache[u]
+	mediaInfoCacheMu.RUnlock()
+	if ok {
+		metrics.CacheHit("media_info")
+		return info, nil
+	}
+	metrics.CacheMiss("media_info")
+
+	info, err := fetchMediaInfo(ctx, u)
+	if err != nil {
+		return info, err
+	}
+
+	mediaInfoCacheMu.Lock()
+	if len(mediaInfoCache) >= MEDIA_CACHE_MAX_SIZE {
+		mediaInfoCache = make(map[string]mediaInfo)
+	}
+	mediaInfoCache[u] = info
+	mediaInfoCacheMu.Unlock()
+	return info, nil
+}
+
+// ResolveMedia fills in the missing MIME types and lengths of a single media
+func ResolveMedia(ctx context.Context, m *Media) {
+	if m.Type == "" || m.Length == 0 {
+		info, err := getMediaInfoCached(ctx, m.Url)
+		if err != nil {
+			GetLogger(ctx).Debug().Msgf("unable to resolve media %s: %s", m.Url, err)
+		}
+		if m.Type == "" {
+			m.Type = info.Type
+		}
+		if m.Length == 0 {
+			m.Length = info.Length
+		}
+	}
+	if m.Type == "" || m.Type == "application/octet-stream" || m.Type == "binary/octet-stream" {
+		if t := MediaTypeFromUrl(m.Url); t != "" {
+			m.Type = t
+		}
+	}
+	if m.Medium == "" {
+		m.Medium = mediumFromType(m.Type)
+	}
+}
+
+// ResolveFeedMedia resolves all the media attached to the feed items and
+// exposes the first one as the item enclosure
+func ResolveFeedMedia(f *feeds.Feed, o *Options) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, MEDIA_RESOLVE_CONCURRENCY)
+
+	for _, item := range f.Items {
+		ext := o.GetExtensions(item)
+		if ext == nil {
+			continue
+		}
+		for _, m := range ext.Media {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(m *Media) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				ResolveMedia(o.Context(), m)
+			}(m)
+		}
+	}
+	wg.Wait()
+
+	for _, item := range f.Items {
+		ext := o.GetExtensions(item)
+		if ext == nil || len(ext.Media) == 0 {
+			continue
+		}
+		m := ext.Media[0]
+		item.Enclosure = &feeds.Enclosure{
+			Url:    m.Url,
+			Type:   m.Type,
+			Length: strconv.FormatInt(m.Length, 10),
+		}
+	}
+}
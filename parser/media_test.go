@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/feeds"
+)
+
+func TestMediaTypeFromUrl(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/cover.jpg", "image/jpeg"},
+		{"https://example.com/cover.PNG?width=200", "image/png"},
+		{"https://example.com/cover.webp#top", "image/webp"},
+		{"https://example.com/cover", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := MediaTypeFromUrl(tt.url); got != tt.want {
+				t.Errorf("MediaTypeFromUrl() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFeedMedia(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/head.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", "1234")
+		case "/range":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "image/gif")
+			w.Header().Set("Content-Range", "bytes 0-0/5678")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte{0})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	o := &Options{}
+	item := &feeds.Item{Title: "item", Link: &feeds.Link{Href: "https://example.com"}}
+	o.AddImage(item, server.URL+"/head.png")
+	o.AddImage(item, server.URL+"/range")
+	o.AddImage(item, server.URL+"/missing.jpg")
+	f := &feeds.Feed{Title: "feed", Link: &feeds.Link{Href: "https://example.com"}, Items: []*feeds.Item{item}}
+
+	ResolveFeedMedia(f, o)
+
+	media := o.GetExtensions(item).Media
+	want := []Media{
+		{Type: "image/png", Length: 1234},
+		{Type: "image/gif", Length: 5678},
+		{Type: "image/jpeg", Length: 0},
+	}
+	for i, w := range want {
+		if media[i].Type != w.Type || media[i].Length != w.Length {
+			t.Errorf("media %d = %s %d, want %s %d", i, media[i].Type, media[i].Length, w.Type, w.Length)
+		}
+	}
+	if item.Enclosure == nil || item.Enclosure.Length != "1234" || item.Enclosure.Type != "image/png" {
+		t.Errorf("unexpected enclosure %v", item.Enclosure)
+	}
+
+	rss, err := ToRss(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`xmlns:media="http://search.yahoo.com/mrss/"`,
+		`<media:thumbnail url="` + server.URL + `/head.png">`,
+		`<media:content url="` + server.URL + `/range" type="image/gif" fileSize="5678" medium="image">`,
+	} {
+		if !strings.Contains(rss, s) {
+			t.Errorf("rss output missing %s:\n%s", s, rss)
+		}
+	}
+
+	json, err := ToJSON(f, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(json, `"image": "`+server.URL+`/head.png"`) {
+		t.Errorf("json output missing image:\n%s", json)
+	}
+}
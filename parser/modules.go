@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+// ModuleSetting is a setting of a module that isn't a feed option, such as an API token
+type ModuleSetting struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default,omitempty"`
+	// Type is string (default), int, bool, duration or proxy
+	Type string `json:"type,omitempty"`
+	// Secret settings are redacted from the help and logs
+	Secret bool `json:"secret,omitempty"`
+}
+
+// SettingsProvider is implemented by the modules having settings
+type SettingsProvider interface {
+	Settings() []ModuleSetting
+}
+
+// SettingHelp describes a module setting and its current value, redacted for secrets
+type SettingHelp struct {
+	ModuleSetting
+	Env   string `json:"env"`
+	Value string `json:"value,omitempty"`
+}
+
+// ModulesConfig is the modules config of the config file, completed with
+// the module environment variables
+type ModulesConfig struct {
+	modules  map[string]config.ModuleConfig
+	settings map[string]map[string]string
+}
+
+type modulesConfigKey struct{}
+
+// WithModulesConfig returns a copy of the config snapshot setting the modules
+// enabled, the defaults of their options and their settings
+func WithModulesConfig(s *config.Snapshot, c *ModulesConfig) *config.Snapshot {
+	return s.WithValue(modulesConfigKey{}, c)
+}
+
+// SetModulesConfig applies the modules config alone, keeping the rest of the current config
+func SetModulesConfig(c *ModulesConfig) {
+	config.Publish(WithModulesConfig(config.Current(), c))
+}
+
+func getModulesConfig() *ModulesConfig {
+	c, _ := config.Current().Value(modulesConfigKey{}).(*ModulesConfig)
+	return c
+}
+
+func getModuleConfig(module string) config.ModuleConfig {
+	c := getModulesConfig()
+	if c == nil {
+		return config.ModuleConfig{}
+	}
+	return c.modules[module]
+}
+
+func IsModuleEnabled(module string) bool {
+	return getModuleConfig(module).IsEnabled()
+}
+
+// applyModuleDefaults sets the defaults of the options configured for the module
+func applyModuleDefaults(module string, options OptionsList) {
+	defaults := getModuleConfig(module).Defaults
+	if len(defaults) == 0 {
+		return
+	}
+	for _, option := range options {
+		if value, ok := defaults[option.Flag]; ok && !option.IsStatic && !option.Required {
+			option.Default = value
+		}
+	}
+}
+
+func getSettings(p Parser) []ModuleSetting {
+	settings := []ModuleSetting{PROXY_SETTING}
+	if provider, ok := p.(SettingsProvider); ok {
+		settings = append(settings, provider.Settings()...)
+	}
+	return settings
+}
+
+func validateSetting(setting ModuleSetting, value string) error {
+	if value == "" {
+		return nil
+	}
+	var err error
+	switch setting.Type {
+	case "int":
+		_, err = strconv.Atoi(value)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "proxy":
+		return config.ValidateProxy(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s expected", setting.Type)
+	}
+	return nil
+}
+
+// resolveDefaults checks the configured option defaults and completes them with
+// the module environment variables
+func resolveDefaults(name string, p Parser, values map[string]string) (map[string]string, error) {
+	options := GetFullOptions(p).OptionsList
+	defaults := make(map[string]string, len(values))
+	maps.Copy(defaults, values)
+	for _, option := range options {
+		if option.IsStatic || option.Required {
+			continue
+		}
+		value, ok, err := config.LookupModuleEnv(name, option.Flag)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			defaults[option.Flag] = value
+		}
+	}
+	for flag, value := range defaults {
+		var option *Option
+		for _, o := range options {
+			if o.Flag == flag {
+				option = o
+			}
+		}
+		switch {
+		case option == nil:
+			return nil, fmt.Errorf("module %s has no option %s", name, flag)
+		case option.IsStatic || option.Required:
+			return nil, fmt.Errorf("module %s option %s can't have a default", name, flag)
+		case option.Type == "int":
+			if _, err := strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("module %s option %s: number expected", name, flag)
+			}
+		}
+	}
+	return defaults, nil
+}
+
+// resolveSettings reads the module settings from, by precedence, their
+// environment variable, the config file, or their default
+func resolveSettings(name string, p Parser, values map[string]string) (map[string]string, error) {
+	settings := getSettings(p)
+	for key := range values {
+		known := false
+		for _, setting := range settings {
+			known = known || key == setting.Name || key == setting.Name+config.FILE_SUFFIX
+		}
+		if !known {
+			return nil, fmt.Errorf("module %s has no setting %s", name, key)
+		}
+	}
+	resolved := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		value := setting.Default
+		if path, ok := values[setting.Name+config.FILE_SUFFIX]; ok {
+			v, err := config.ReadSecretFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("module %s setting %s: %w", name, setting.Name, err)
+			}
+			value = v
+		}
+		if v, ok := values[setting.Name]; ok {
+			value = v
+		}
+		v, ok, err := config.LookupModuleEnv(name, setting.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			value = v
+		}
+		if err := validateSetting(setting, value); err != nil {
+			return nil, fmt.Errorf("module %s setting %s: %w", name, setting.Name, err)
+		}
+		resolved[setting.Name] = value
+	}
+	return resolved, nil
+}
+
+// ResolveModulesConfig checks the modules config of the config file, modules
+// being named as in their feed URLs, and completes it with the module
+// environment variables
+func ResolveModulesConfig(parsers map[string]Parser, c map[string]config.ModuleConfig) (*ModulesConfig, error) {
+	byName := make(map[string]Parser, len(parsers))
+	for _, p := range parsers {
+		byName[p.String()] = p
+	}
+	for name := range c {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("unknown module %s", name)
+		}
+	}
+	resolved := &ModulesConfig{
+		modules:  make(map[string]config.ModuleConfig, len(byName)),
+		settings: make(map[string]map[string]string, len(byName)),
+	}
+	for name, p := range byName {
+		m := c[name]
+		defaults, err := resolveDefaults(name, p, m.Defaults)
+		if err != nil {
+			return nil, err
+		}
+		settings, err := resolveSettings(name, p, m.Settings)
+		if err != nil {
+			return nil, err
+		}
+		resolved.modules[name] = config.ModuleConfig{Enabled: m.Enabled, Defaults: defaults}
+		resolved.settings[name] = settings
+	}
+	return resolved, nil
+}
+
+func getModuleSetting(p Parser, name string) string {
+	if c := getModulesConfig(); c != nil {
+		if value, ok := c.settings[p.String()][name]; ok {
+			return value
+		}
+	}
+	for _, setting := range getSettings(p) {
+		if setting.Name == name {
+			return setting.Default
+		}
+	}
+	return ""
+}
+
+// Setting returns the value of a setting of the module, empty when unset
+func (o *Options) Setting(name string) string {
+	if o.Parser == nil {
+		return ""
+	}
+	return getModuleSetting(o.Parser, name)
+}
+
+func (o *Options) SettingInt(name string) int {
+	// validated when resolved
+	value, _ := strconv.Atoi(o.Setting(name))
+	return value
+}
+
+func (o *Options) SettingBool(name string) bool {
+	value, _ := strconv.ParseBool(o.Setting(name))
+	return value
+}
+
+func (o *Options) SettingDuration(name string) time.Duration {
+	value, _ := time.ParseDuration(o.Setting(name))
+	return value
+}
+
+// GetSettingsHelp describes the settings of the module with their current values
+func GetSettingsHelp(p Parser) []SettingHelp {
+	var help []SettingHelp
+	for _, setting := range getSettings(p) {
+		h := SettingHelp{ModuleSetting: setting, Env: config.ModuleEnvName(p.String(), setting.Name), Value: getModuleSetting(p, setting.Name)}
+		if setting.Secret && h.Value != "" {
+			h.Value = DEBUG_REDACTED
+		}
+		help = append(help, h)
+	}
+	return help
+}
+
+// GetSettingsHelpText is GetSettingsHelp formatted as GetHelp
+func GetSettingsHelpText(p Parser) string {
+	var help strings.Builder
+	for _, setting := range GetSettingsHelp(p) {
+		fmt.Fprintf(&help, "\t - setting %s (%s): %s", setting.Name, setting.Env, setting.Description)
+		if setting.Value != "" {
+			fmt.Fprintf(&help, " (value: %s)", setting.Value)
+		} else if setting.Default != "" {
+			fmt.Fprintf(&help, " (default: %s)", setting.Default)
+		}
+		help.WriteString("\n")
+	}
+	return help.String()
+}
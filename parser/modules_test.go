@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+func TestModulesConfig(t *testing.T) {
+	disabled := false
+	modules := map[string]Parser{"routeTest": routeTestParser{}}
+	for _, invalid := range []map[string]config.ModuleConfig{
+		{"missing": {}},
+		{"routetest": {Defaults: map[string]string{"gone": "1"}}},
+		{"routetest": {Defaults: map[string]string{"image": "a"}}},
+		{"routetest": {Defaults: map[string]string{"count": "many"}}},
+		{"routetest": {Settings: map[string]string{"TOKEN": "a"}}},
+	} {
+		if _, err := ResolveModulesConfig(modules, invalid); err == nil {
+			t.Errorf("ResolveModulesConfig(%v) should fail", invalid)
+		}
+	}
+
+	t.Setenv("BANQUET_MODULE_ROUTETEST_COUNT", "5")
+	c, err := ResolveModulesConfig(modules, map[string]config.ModuleConfig{
+		"routetest": {Defaults: map[string]string{"platform": "linux/arm64", "count": "3"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetModulesConfig(c)
+	defer SetModulesConfig(nil)
+	o := GetFullOptions(routeTestParser{})
+	if o.Get("platform") != "linux/arm64" || o.Get("count") != 5 {
+		t.Errorf("the configured defaults should apply, environment first: %v %v", o.Get("platform"), o.Get("count"))
+	}
+	match, err := NewRouteMatcher(modules).Match("/feed/routetest/image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(match.Values()) != 1 {
+		t.Errorf("options left to the configured defaults should be omitted: %v", match.Values())
+	}
+
+	c, err = ResolveModulesConfig(modules, map[string]config.ModuleConfig{"routetest": {Enabled: &disabled}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetModulesConfig(c)
+	if IsModuleEnabled("routetest") {
+		t.Errorf("routetest should be disabled")
+	}
+	if _, err := NewRouteMatcher(modules).Match("/feed/routetest/image"); err == nil {
+		t.Errorf("Match() should fail for disabled modules")
+	}
+}
+
+type settingsTestParser struct{}
+
+func (settingsTestParser) Parse(*Options) (*feeds.Feed, error) { return nil, nil }
+func (settingsTestParser) String() string                      { return "settingstest" }
+func (settingsTestParser) GetOptions() Options                 { return Options{} }
+func (settingsTestParser) Settings() []ModuleSetting {
+	return []ModuleSetting{
+		{Name: "TOKEN", Secret: true},
+		{Name: "USERNAME"},
+		{Name: "RETRIES", Type: "int", Default: "3"},
+	}
+}
+
+func TestModuleSettings(t *testing.T) {
+	p := settingsTestParser{}
+	modules := map[string]Parser{"settingsTest": p}
+	o := &Options{Parser: p}
+	if o.SettingInt("RETRIES") != 3 || o.Setting("TOKEN") != "" {
+		t.Errorf("the settings defaults should apply before the config is resolved")
+	}
+
+	secret := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BANQUET_MODULE_SETTINGSTEST_USERNAME", "env")
+	c, err := ResolveModulesConfig(modules, map[string]config.ModuleConfig{
+		"settingstest": {Settings: map[string]string{"TOKEN_FILE": secret, "USERNAME": "file", "RETRIES": "5"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetModulesConfig(c)
+	defer SetModulesConfig(nil)
+	if o.Setting("TOKEN") != "s3cr3t" || o.Setting("USERNAME") != "env" || o.SettingInt("RETRIES") != 5 {
+		t.Errorf("unexpected settings %q %q %d", o.Setting("TOKEN"), o.Setting("USERNAME"), o.SettingInt("RETRIES"))
+	}
+	for _, h := range GetSettingsHelp(p) {
+		if h.Name == "TOKEN" && (h.Value != DEBUG_REDACTED || h.Env != "BANQUET_MODULE_SETTINGSTEST_TOKEN") {
+			t.Errorf("the secret should be redacted: %+v", h)
+		}
+	}
+
+	if _, err := ResolveModulesConfig(modules, map[string]config.ModuleConfig{"settingstest": {Settings: map[string]string{"RETRIES": "many"}}}); err == nil {
+		t.Errorf("ResolveModulesConfig() should validate the settings types")
+	}
+	t.Setenv("BANQUET_MODULE_SETTINGSTEST_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := ResolveModulesConfig(modules, nil); err == nil {
+		t.Errorf("ResolveModulesConfig() should fail on missing secret files")
+	}
+}
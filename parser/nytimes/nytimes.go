@@ -18,6 +18,34 @@ func (NYTimes) String() string {
 	return "nytimes"
 }
 
+func init() {
+	parser.Register("nytimes", NYTimesParser)
+}
+
+func (NYTimes) Description() string {
+	return "Latest articles of a New York Times author"
+}
+
+func (NYTimes) Homepage() string {
+	return "https://www.nytimes.com"
+}
+
+func (NYTimes) Category() string {
+	return "news"
+}
+
+func (NYTimes) Icon() string {
+	return "https://www.nytimes.com/favicon.ico"
+}
+
+func (NYTimes) RefreshInterval() time.Duration {
+	return time.Hour
+}
+
+func (NYTimes) SampleOptions() map[string]string {
+	return map[string]string{"author": "paul-krugman"}
+}
+
 func (NYTimes) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -38,7 +66,7 @@ func (NYTimes) Parse(options *parser.Options) (*feeds.Feed, error) {
 		return nil, fmt.Errorf("author is required")
 	}
 
-	work, err := getGraphQLResponse(author)
+	work, err := getGraphQLResponse(options.Context(), author)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch articles: %w", err)
 	}
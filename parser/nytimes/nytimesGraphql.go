@@ -2,42 +2,48 @@ package nytimes
 
 import (
 	"compress/gzip"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/nbr23/rss-banquet/parser"
 )
 
 const NYT_GRAPHQL_HASH = "57cb59fc351b816edf094c214f5ef56532145dd548fdf88103396b349640aa62"
 
-func getNYTimesToken() (string, error) {
-	resp, err := http.Get(fmt.Sprintf("https://www.nytimes.com/"))
+const NYT_TOKEN = "nyt-token"
+
+// getNYTimesToken scrapes the token from the homepage, valid until rejected
+func getNYTimesToken(ctx context.Context) (string, time.Duration, error) {
+	resp, err := parser.HttpGetWithContext(parser.WithSensitiveResponse(ctx), "https://www.nytimes.com/", nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch token: %w", err)
+		return "", 0, fmt.Errorf("failed to fetch token: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to fetch token, status code: %d", resp.StatusCode)
+		return "", 0, fmt.Errorf("failed to fetch token, status code: %d", resp.StatusCode)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return "", 0, fmt.Errorf("failed to read response body: %w", err)
 	}
 	body := string(bodyBytes)
 	tokenStart := strings.Index(body, `"nyt-token":"`)
 	if tokenStart == -1 {
-		return "", fmt.Errorf("nyt-token not found in response")
+		return "", 0, fmt.Errorf("nyt-token not found in response")
 	}
 	tokenStart += len(`"nyt-token":"`)
 	tokenEnd := strings.Index(body[tokenStart:], `"`)
 	if tokenEnd == -1 {
-		return "", fmt.Errorf("nyt-token end not found in response")
+		return "", 0, fmt.Errorf("nyt-token end not found in response")
 	}
 	token := body[tokenStart : tokenStart+tokenEnd]
-	return token, nil
+	return token, 0, nil
 }
 
 func getGraphQLQuery(author string) (string, string) {
@@ -47,15 +53,11 @@ func getGraphQLQuery(author string) (string, string) {
 	return url.QueryEscape(variables), url.QueryEscape(extensions)
 }
 
-func getGraphQLResponse(author string) (*AnyWork, error) {
-	token, err := getNYTimesToken()
-	if err != nil {
-		return nil, err
-	}
+func sendGraphQLRequest(ctx context.Context, author string, token string) (*http.Response, error) {
 	variables, extensions := getGraphQLQuery(author)
 	myurl := fmt.Sprintf("https://samizdat-graphql.nytimes.com/graphql/v2?operationName=BylineQuery&variables=%s&extensions=%s", variables, extensions)
 
-	req, err := http.NewRequest("GET", myurl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", myurl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +81,13 @@ func getGraphQLResponse(author string) (*AnyWork, error) {
 	req.Header.Set("Priority", "u=4")
 	req.Header.Set("TE", "trailers")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return parser.HttpDo(&http.Client{}, req)
+}
+
+func getGraphQLResponse(ctx context.Context, author string) (*AnyWork, error) {
+	resp, err := parser.GetContextSession(ctx).DoWithToken(ctx, NYT_TOKEN, getNYTimesToken, func(token string) (*http.Response, error) {
+		return sendGraphQLRequest(ctx, author, token)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +110,7 @@ func getGraphQLResponse(author string) (*AnyWork, error) {
 		return nil, err
 	}
 	var graphqlResponse GraphQLResponse
-	err = json.Unmarshal(body, &graphqlResponse)
+	err = parser.UnmarshalJSON(ctx, body, &graphqlResponse)
 	if err != nil {
 		return nil, err
 	}
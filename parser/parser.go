@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"context"
 	"crypto/sha256"
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -12,9 +15,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/feeds"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/metrics"
 	"github.com/nbr23/rss-banquet/style"
 )
 
@@ -31,8 +39,9 @@ func GetFullOptions(p Parser) *Options {
 			Flag:     "feedFormat",
 			Required: false,
 			Type:     "string",
-			Help:     "feed output format (rss, atom, json)",
+			Help:     "feed output format (rss, atom, json, text)",
 			Default:  "rss",
+			Enum:     []string{"rss", "atom", "json", "text"},
 		},
 		{
 			Flag:     "route",
@@ -43,10 +52,23 @@ func GetFullOptions(p Parser) *Options {
 			IsStatic: true,
 		},
 	}, opts.OptionsList...)
+	applyModuleDefaults(p.String(), opts.OptionsList)
 
 	return &opts
 }
 
+// NewOptions returns the full options of the parser set to the given values
+func NewOptions(p Parser, values map[string]string) (*Options, error) {
+	o := GetFullOptions(p)
+	for flag, value := range values {
+		if !slices.ContainsFunc(o.OptionsList, func(option *Option) bool { return option.Flag == flag }) {
+			return nil, fmt.Errorf("unknown option: %s", flag)
+		}
+		o.Set(flag, value)
+	}
+	return o, nil
+}
+
 func GetLatestDate(dates []time.Time) time.Time {
 	latestDate := dates[0]
 	for _, date := range dates {
@@ -61,8 +83,37 @@ func GetGuid(ss []string) string {
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(ss))))
 }
 
-func GetRemoteFileLastModified(url string) (time.Time, error) {
-	resp, err := http.Head(url)
+// GetItemGuid returns the item id, falling back to its link, then to a hash of
+// its title and description
+func GetItemGuid(item *feeds.Item) string {
+	if item.Id != "" {
+		return item.Id
+	}
+	if item.Link != nil && item.Link.Href != "" {
+		return item.Link.Href
+	}
+	return GetGuid([]string{item.Title, item.Description})
+}
+
+// GetNewItems returns the items of the feed missing from seen, oldest first,
+// along with seen updated with their guids and truncated to its last max entries
+func GetNewItems(f *feeds.Feed, seen []string, max int) ([]*feeds.Item, []string) {
+	var newItems []*feeds.Item
+	for i := len(f.Items) - 1; i >= 0; i-- {
+		guid := GetItemGuid(f.Items[i])
+		if !slices.Contains(seen, guid) {
+			newItems = append(newItems, f.Items[i])
+			seen = append(seen, guid)
+		}
+	}
+	if len(seen) > max {
+		seen = seen[len(seen)-max:]
+	}
+	return newItems, seen
+}
+
+func GetRemoteFileLastModified(ctx context.Context, url string) (time.Time, error) {
+	resp, err := HttpGetWithContext(ctx, url, map[string]any{"method": http.MethodHead})
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -91,40 +142,31 @@ func FeedToText(f *feeds.Feed) string {
 	return strings.TrimSpace(txt)
 }
 
-func ServeFeed(c *gin.Context, f *feeds.Feed) {
-	switch c.Query("feedFormat") {
+// RenderFeed serializes the feed in the given format, returning its content type
+func RenderFeed(f *feeds.Feed, o *Options, format string) (string, string, error) {
+	switch format {
 	case "json":
-		json, err := f.ToJSON()
-		if err != nil {
-			c.String(500, "error parsing feed")
-			return
-		}
-		c.Data(200, "application/json", []byte(json))
-		return
+		json, err := ToJSON(f, o)
+		return "application/json", json, err
 	case "atom":
-		atom, err := f.ToAtom()
-		if err != nil {
-			c.String(500, "error parsing feed")
-			return
-		}
-		atom = style.InjectAtomStyle(atom)
-		c.Data(200, "application/xml", []byte(atom))
-		return
+		atom, err := ToAtom(f, o)
+		return "application/xml", style.InjectAtomStyle(atom), err
 	case "text":
-		text := FeedToText(f)
-		c.Data(200, "text/plain", []byte(text))
-		return
+		return "text/plain", FeedToText(f), nil
 	// case "rss":
 	default:
-		rss, err := f.ToRss()
-		if err != nil {
-			c.String(500, "error parsing feed")
-			return
-		}
-		rss = style.InjectRssStyle(rss)
-		c.Data(200, "application/xml", []byte(rss))
+		rss, err := ToRss(f, o)
+		return "application/xml", style.InjectRssStyle(rss), err
+	}
+}
+
+func ServeFeed(c *gin.Context, f *feeds.Feed, o *Options) {
+	contentType, data, err := RenderFeed(f, o, c.Query("feedFormat"))
+	if err != nil {
+		c.String(500, "error parsing feed")
 		return
 	}
+	c.Data(200, contentType, []byte(data))
 }
 
 type Option struct {
@@ -137,6 +179,8 @@ type Option struct {
 	Type      string      `json:"type"`
 	IsPath    bool        `json:"isPath"`
 	IsStatic  bool        `json:"isStatic"` // static options are exposed through the API
+	// Enum lists the accepted values, of each element for stringSlice options
+	Enum []string `json:"enum,omitempty"`
 }
 
 func (o Options) GetOptionsCopy() OptionsList {
@@ -152,6 +196,7 @@ func (o Options) GetOptionsCopy() OptionsList {
 			Type:      option.Type,
 			IsPath:    option.IsPath,
 			IsStatic:  option.IsStatic,
+			Enum:      option.Enum,
 		})
 	}
 	return opts
@@ -160,6 +205,25 @@ func (o Options) GetOptionsCopy() OptionsList {
 type Options struct {
 	OptionsList OptionsList
 	Parser      Parser
+	extensions  *feedExtensions
+	ctx         context.Context
+}
+
+// Context returns the context of the parse, carrying its logger
+func (o *Options) Context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+func (o *Options) SetContext(ctx context.Context) {
+	o.ctx = ctx
+}
+
+// Logger returns the logger of the parse, logging the module, feed and request id
+func (o *Options) Logger() *zerolog.Logger {
+	return GetLogger(o.Context())
 }
 
 type OptionsList []*Option
@@ -262,7 +326,7 @@ func (o Options) GetHelp() string {
 	return help
 }
 
-func Route(g *gin.Engine, p Parser, o *Options) gin.IRoutes {
+func RoutePath(o *Options) string {
 	urlPath := []string{o.Get("route").(string)}
 	for _, option := range o.OptionsList {
 		if option.IsStatic {
@@ -276,26 +340,124 @@ func Route(g *gin.Engine, p Parser, o *Options) gin.IRoutes {
 			urlPath = append(urlPath, fmt.Sprintf("%s%s", prefix, option.Flag))
 		}
 	}
-	route := fmt.Sprintf("/feed/%s", strings.Join(urlPath, "/"))
-
-	return g.GET(route, func(c *gin.Context) {
-		options := o.GetOptionsCopy()
-		for _, option := range options {
-			if option.Required {
-				if c.Param(option.Flag) == "" {
-					log.Error().Msgf("missing required parameter: %s", option.Flag)
-					c.String(400, "missing required parameter: %s", option.Flag)
-					return
-				} else {
-					option.Value = c.Param(option.Flag)
-				}
+	return fmt.Sprintf("/feed/%s", strings.Join(urlPath, "/"))
+}
+
+// GetRequestOptions reads the options values from the route parameters and the query string
+func GetRequestOptions(c *gin.Context, o *Options) (OptionsList, error) {
+	options := o.GetOptionsCopy()
+	if o.Parser != nil {
+		// the defaults may have been reloaded since the route was registered
+		applyModuleDefaults(o.Parser.String(), options)
+	}
+	for _, option := range options {
+		if option.Required {
+			if c.Param(option.Flag) == "" {
+				return nil, fmt.Errorf("missing required parameter: %s", option.Flag)
 			} else {
-				if c.Query(option.Flag) != "" {
-					option.Value = c.Query(option.Flag)
-				}
+				option.Value = c.Param(option.Flag)
+			}
+		} else {
+			if c.Query(option.Flag) != "" {
+				option.Value = c.Query(option.Flag)
 			}
 		}
-		feed, err := p.Parse(&Options{OptionsList: options, Parser: p})
+	}
+	return options, nil
+}
+
+// ServedFeed is a feed parsed, or read from the cache, as /feed/... serves it
+type ServedFeed struct {
+	Feed      *feeds.Feed
+	Cached    bool
+	Anomalies []string
+}
+
+// ParseServedFeed reads the feed served at u from the cache, or parses it,
+// checks it for breakages, records its health and metrics, filters it and
+// caches it. The options and filter are expected to be validated.
+func ParseServedFeed(p Parser, o *Options, u *url.URL, filter *FeedFilter) (*ServedFeed, error) {
+	// validated on startup and reload
+	cacheTtl, _ := GetFeedCacheTtl()
+	feedKey := FeedKey(u)
+	if cacheTtl > 0 {
+		if feed, ok := lookupFeedCache(feedKey, o); ok {
+			return &ServedFeed{Feed: feed, Cached: true}, nil
+		}
+	}
+	start := time.Now()
+	feed, err := parseModuleFeed(p, o)
+	if err != nil {
+		RecordParse(p.String(), feedKey, time.Since(start), 0, err)
+		return nil, err
+	}
+	items := len(feed.Items)
+	// the filters may legitimately leave no items, the baseline is the unfiltered feed
+	baselineKey := BaselineKey(u, o)
+	anomalies := CheckFeedBreakage(p.String(), baselineKey, feed)
+	metrics.ObserveFeed(p.String(), baselineKey, items)
+	finishFeed(feed, o, filter)
+	var breakage error
+	if len(anomalies) > 0 {
+		breakage = &BreakageError{Anomalies: anomalies}
+		o.Logger().Warn().Strs("anomalies", anomalies).Msg("possible scraper breakage")
+		if config.GetConfigOption("BREAKAGE_ITEM") == "true" {
+			AddBreakageItem(feed, feedKey, anomalies)
+		}
+	}
+	RecordParse(p.String(), feedKey, time.Since(start), items, breakage)
+	cacheFeed(feedKey, feed, o, cacheTtl)
+	return &ServedFeed{Feed: feed, Anomalies: anomalies}, nil
+}
+
+// ParsePreviewFeed reads the feed served at u from the cache, or parses it,
+// filters it and caches it like ParseServedFeed. Its parse is compared with
+// the baseline of the feed but left out of it, as of the health and feed
+// metrics of the served feeds. The options are validated by the parse.
+func ParsePreviewFeed(p Parser, o *Options, u *url.URL, filter *FeedFilter) (*ServedFeed, error) {
+	// validated on startup and reload
+	cacheTtl, _ := GetFeedCacheTtl()
+	feedKey := FeedKey(u)
+	if cacheTtl > 0 {
+		if feed, ok := lookupFeedCache(feedKey, o); ok {
+			return &ServedFeed{Feed: feed, Cached: true}, nil
+		}
+	}
+	feed, err := parseModuleFeed(p, o)
+	if err != nil {
+		return nil, err
+	}
+	anomalies := PeekFeedBreakage(BaselineKey(u, o), feed)
+	finishFeed(feed, o, filter)
+	cacheFeed(feedKey, feed, o, cacheTtl)
+	return &ServedFeed{Feed: feed, Anomalies: anomalies}, nil
+}
+
+func Route(g *gin.Engine, p Parser, o *Options) gin.IRoutes {
+	return g.GET(RoutePath(o), func(c *gin.Context) {
+		if !IsModuleEnabled(p.String()) {
+			c.String(404, "module disabled")
+			return
+		}
+		options, err := GetRequestOptions(c, o)
+		if err != nil {
+			GetLogger(c.Request.Context()).Error().Msg(err.Error())
+			c.String(400, err.Error())
+			return
+		}
+		feedKey := FeedKey(c.Request.URL)
+		parseOptions := &Options{OptionsList: options, Parser: p}
+		parseOptions.SetContext(WithLogFields(c.Request.Context(), map[string]string{"feed": feedKey}))
+		filter, err := ParseFeedFilter(c.Request.URL.Query(), parseOptions)
+		if err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		if err := ValidateOptions(p, parseOptions); err != nil {
+			c.String(400, err.Error())
+			return
+		}
+		served, err := ParseServedFeed(p, parseOptions, c.Request.URL, filter)
 		if err != nil {
 			switch err.(type) {
 			case *NotFoundError:
@@ -304,17 +466,70 @@ func Route(g *gin.Engine, p Parser, o *Options) gin.IRoutes {
 			case *InternalError:
 				c.String(500, err.Error())
 				return
+			case *BadRequestError:
+				c.String(400, err.Error())
+				return
 			default:
-				log.Error().Msgf("error parsing feed: %s", err)
+				parseOptions.Logger().Error().Msgf("error parsing feed: %s", err)
 				c.String(500, "error parsing feed")
 				return
 			}
 		}
-		SortFeedEntries(feed)
-		ServeFeed(c, feed)
+		if cacheTtl, _ := GetFeedCacheTtl(); cacheTtl > 0 {
+			if served.Cached {
+				c.Header(CACHE_HEADER, "hit")
+			} else {
+				c.Header(CACHE_HEADER, "miss")
+			}
+		}
+		if len(served.Anomalies) > 0 {
+			c.Header(BREAKAGE_HEADER, fmt.Sprintf("possible scraper breakage: %s", strings.Join(served.Anomalies, ", ")))
+		}
+		for _, link := range GetContextFeedLinks(c) {
+			parseOptions.AddFeedLink(link.Rel, link.Href)
+		}
+		ServeFeed(c, served.Feed, parseOptions)
 	})
 }
 
+// ParseFeed parses the feed and applies the filter, sort and media resolution of served feeds
+func ParseFeed(p Parser, o *Options, filter *FeedFilter) (*feeds.Feed, error) {
+	feed, err := parseModuleFeed(p, o)
+	if err != nil {
+		return nil, err
+	}
+	finishFeed(feed, o, filter)
+	return feed, nil
+}
+
+// parseModuleFeed parses the feed of the module, before any filter
+func parseModuleFeed(p Parser, o *Options) (*feeds.Feed, error) {
+	ctx, span := StartSpan(o.Context(), "parse "+p.String(), append(getOptionsAttributes(o), attribute.String("banquet.module", p.String()))...)
+	o.SetContext(WithLogFields(withModule(ctx, p), map[string]string{"module": p.String()}))
+	if err := ValidateOptions(p, o); err != nil {
+		EndSpan(span, err)
+		return nil, err
+	}
+	start := time.Now()
+	feed, err := p.Parse(o)
+	if err != nil {
+		metrics.ObserveParse(p.String(), time.Since(start), 0, err)
+		EndSpan(span, err)
+		return nil, err
+	}
+	metrics.ObserveParse(p.String(), time.Since(start), len(feed.Items), nil)
+	span.SetAttributes(attribute.Int("banquet.items", len(feed.Items)))
+	EndSpan(span, nil)
+	return feed, nil
+}
+
+// finishFeed applies the filter, sort and media resolution of served feeds
+func finishFeed(feed *feeds.Feed, o *Options, filter *FeedFilter) {
+	filter.Apply(feed, o)
+	SortFeedEntries(feed)
+	ResolveFeedMedia(feed, o)
+}
+
 type NotFoundError struct {
 	message string
 }
@@ -323,6 +538,11 @@ type InternalError struct {
 	message string
 }
 
+// BadRequestError is the error of a parse with invalid options
+type BadRequestError struct {
+	message string
+}
+
 func (e *NotFoundError) Error() string {
 	return fmt.Sprintf("NotFoundError: %s", e.message)
 }
@@ -339,6 +559,14 @@ func NewInternalError(message string) *InternalError {
 	return &InternalError{message: message}
 }
 
+func (e *BadRequestError) Error() string {
+	return fmt.Sprintf("BadRequestError: %s", e.message)
+}
+
+func NewBadRequestError(message string) *BadRequestError {
+	return &BadRequestError{message: message}
+}
+
 func SortFeedEntries(f *feeds.Feed) {
 	sort.Slice(f.Items, func(i, j int) bool {
 		return f.Items[i].Created.After(f.Items[j].Created)
@@ -366,27 +594,20 @@ func (o Options) AddFlags(f *flag.FlagSet) {
 	}
 }
 
-func GetFileTypeFromUrl(url string) string {
-	parts := strings.Split(strings.Split(url, "?")[0], ".")
-
-	if len(parts) == 0 {
-		return ""
-	}
-	return parts[len(parts)-1]
+func HttpGet(url string, options map[string]any) (*http.Response, error) {
+	return HttpGetWithContext(context.Background(), url, options)
 }
 
-func IsImageType(t string) bool {
-	switch t {
-	case "png", "jpg", "jpeg", "gif":
-		return true
-	default:
-		return false
+// HttpGetWithContext fetches the url, logging the request with the logger of the context
+func HttpGetWithContext(ctx context.Context, url string, options map[string]any) (*http.Response, error) {
+	method := http.MethodGet
+	if m, ok := options["method"].(string); ok {
+		method = m
 	}
-}
 
-func HttpGet(url string, options map[string]any) (*http.Response, error) {
-	req, err := http.NewRequest(
-		"GET",
+	req, err := http.NewRequestWithContext(
+		ctx,
+		method,
 		url,
 		nil,
 	)
@@ -405,12 +626,54 @@ func HttpGet(url string, options map[string]any) (*http.Response, error) {
 		}
 	}
 
-	client := &http.Client{}
+	return HttpDo(&http.Client{}, req)
+}
 
+// HttpDo sends the request with the client, recording its metrics and
+// logging it with the logger of the request context
+func HttpDo(client *http.Client, req *http.Request) (*http.Response, error) {
+	logger := GetLogger(req.Context())
+	ctx, span := otel.Tracer(TRACER_NAME).Start(req.Context(), fmt.Sprintf("%s %s", req.Method, req.URL.Host),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+			attribute.String("server.address", req.URL.Host),
+		),
+	)
+	req = req.WithContext(ctx)
+	queued := time.Now()
+	release, err := waitPoliteness(req)
+	if err != nil {
+		EndSpan(span, err)
+		return nil, err
+	}
+	wait := time.Since(queued)
+	span.SetAttributes(attribute.Int64("banquet.queued_ms", wait.Milliseconds()))
+	client, proxy := withProxy(withSession(client, req), req)
+	if proxy != "" {
+		span.SetAttributes(attribute.String("banquet.proxy", proxy))
+	}
+	start := time.Now()
 	resp, err := client.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		release()
+	} else {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	EndSpan(span, err)
+	if capture := GetDebugCapture(ctx); capture != nil {
+		capture.record(req, resp, proxy, wait, duration, err)
+	}
 	if err != nil {
+		metrics.ObserveUpstream(req.URL.Host, 0, duration)
+		logger.Warn().Str("upstream_url", req.URL.String()).Str("method", req.Method).Str("proxy", proxy).Dur("duration", duration).Err(err).Msg("upstream request failed")
 		return nil, err
 	}
+	metrics.ObserveUpstream(req.URL.Host, resp.StatusCode, duration)
+	logger.Debug().Str("upstream_url", req.URL.String()).Str("method", req.Method).Str("proxy", proxy).Int("status", resp.StatusCode).Dur("queued", wait).Dur("duration", duration).Msg("upstream request")
 
 	return resp, nil
 }
@@ -3,6 +3,8 @@ package parser
 import (
 	"reflect"
 	"testing"
+
+	"github.com/gorilla/feeds"
 )
 
 func TestOptionsList_Get(t *testing.T) {
@@ -190,3 +192,24 @@ func TestOptionsList_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNewItems(t *testing.T) {
+	feed := &feeds.Feed{Items: []*feeds.Item{
+		{Id: "3"},
+		{Title: "2", Link: &feeds.Link{Href: "https://example.com/2"}},
+		{Title: "1"},
+		{Id: "3"},
+	}}
+	unlinked := GetItemGuid(feed.Items[2])
+	if unlinked != GetGuid([]string{"1", ""}) || GetItemGuid(feed.Items[1]) != "https://example.com/2" {
+		t.Errorf("unexpected guids %s %s", unlinked, GetItemGuid(feed.Items[1]))
+	}
+
+	newItems, seen := GetNewItems(feed, []string{"0", unlinked}, 3)
+	if len(newItems) != 2 || newItems[0] != feed.Items[3] || newItems[1] != feed.Items[1] {
+		t.Errorf("the new items should be listed once, oldest first: %v", newItems)
+	}
+	if want := []string{unlinked, "3", "https://example.com/2"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("seen = %v, want the last %v", seen, want)
+	}
+}
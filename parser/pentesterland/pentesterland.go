@@ -1,7 +1,6 @@
 package pentesterland
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
@@ -15,6 +14,30 @@ func (PentesterLand) String() string {
 	return "pentesterland"
 }
 
+func init() {
+	parser.Register("pentesterland", PentesterLandParser)
+}
+
+func (PentesterLand) Description() string {
+	return "Bug bounty writeups listed by Pentester Land"
+}
+
+func (PentesterLand) Homepage() string {
+	return "https://pentester.land"
+}
+
+func (PentesterLand) Category() string {
+	return "security"
+}
+
+func (PentesterLand) Icon() string {
+	return "https://pentester.land/favicon.ico"
+}
+
+func (PentesterLand) RefreshInterval() time.Duration {
+	return 24 * time.Hour
+}
+
 func (PentesterLand) GetOptions() parser.Options {
 	return parser.Options{}
 }
@@ -65,7 +88,22 @@ func buildItemContent(item *pentesterLandItem, isHtml bool) string {
 	return description
 }
 
-func feedAdapter(j *pentesterLandJson) (*feeds.Feed, error) {
+func setItemAttributes(newItem *feeds.Item, item *pentesterLandItem, options *parser.Options) {
+	if len(item.Bugs) > 0 {
+		options.SetAttribute(newItem, "bugs", parser.Tags(item.Bugs))
+	}
+	options.AddCategories(newItem, item.Bugs...)
+	if len(item.Programs) > 0 && item.Programs[0] != "-" {
+		options.SetAttribute(newItem, "programs", parser.Tags(item.Programs))
+	}
+	if item.Bounty != "-" {
+		if bounty, err := parser.ParseMoney(item.Bounty); err == nil {
+			options.SetAttribute(newItem, "bounty", bounty)
+		}
+	}
+}
+
+func feedAdapter(j *pentesterLandJson, options *parser.Options) (*feeds.Feed, error) {
 	feed := feeds.Feed{
 		Title:       "PentesterLand",
 		Description: "PentesterLand",
@@ -78,7 +116,7 @@ func feedAdapter(j *pentesterLandJson) (*feeds.Feed, error) {
 	for _, d := range j.Data {
 		var item feeds.Item
 		if len(d.Links) == 0 {
-			fmt.Println("skipping item without a link", d)
+			options.Logger().Warn().Msgf("skipping item without a link %v", d)
 			continue
 		}
 
@@ -93,6 +131,7 @@ func feedAdapter(j *pentesterLandJson) (*feeds.Feed, error) {
 		}
 		item.Content = buildItemContent(&d, true)
 		item.Description = buildItemContent(&d, false)
+		setItemAttributes(&item, &d, options)
 		feed.Items = append(feed.Items, &item)
 	}
 	return &feed, nil
@@ -100,7 +139,7 @@ func feedAdapter(j *pentesterLandJson) (*feeds.Feed, error) {
 
 func (PentesterLand) Parse(options *parser.Options) (*feeds.Feed, error) {
 	url := "https://pentester.land/writeups.json"
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), url, nil)
 
 	if err != nil {
 		return nil, err
@@ -115,9 +154,9 @@ func (PentesterLand) Parse(options *parser.Options) (*feeds.Feed, error) {
 
 	var feed pentesterLandJson
 
-	if err := json.Unmarshal(data, &feed); err != nil {
+	if err := parser.UnmarshalJSON(options.Context(), data, &feed); err != nil {
 		return nil, err
 	}
 
-	return feedAdapter(&feed)
+	return feedAdapter(&feed, options)
 }
@@ -15,6 +15,30 @@ func (PoCOrGTFO) String() string {
 	return "pocorgtfo"
 }
 
+func init() {
+	parser.Register("pocorgtfo", PoCOrGTFOParser)
+}
+
+func (PoCOrGTFO) Description() string {
+	return "PoC||GTFO issues"
+}
+
+func (PoCOrGTFO) Homepage() string {
+	return "https://www.alchemistowl.org/pocorgtfo/"
+}
+
+func (PoCOrGTFO) Category() string {
+	return "security"
+}
+
+func (PoCOrGTFO) Icon() string {
+	return "https://www.alchemistowl.org/favicon.ico"
+}
+
+func (PoCOrGTFO) RefreshInterval() time.Duration {
+	return 7 * 24 * time.Hour
+}
+
 func (PoCOrGTFO) GetOptions() parser.Options {
 	return parser.Options{}
 }
@@ -29,7 +53,7 @@ func (PoCOrGTFO) Parse(options *parser.Options) (*feeds.Feed, error) {
 	pubRegex := regexp.MustCompile(`(?i)^(PoC\|\|GTFO 0x[0-9a-fA-F]{2})`)
 	dateRegex := regexp.MustCompile(`(?i)^PoC\|\|GTFO 0x[0-9a-fA-F]{2}, ([^,]+),`)
 
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), url, nil)
 
 	if err != nil {
 		return nil, err
@@ -41,7 +65,7 @@ func (PoCOrGTFO) Parse(options *parser.Options) (*feeds.Feed, error) {
 		return nil, fmt.Errorf("unable to fetch the update page, status code: %d", resp.StatusCode)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(options.Context(), resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -58,12 +82,12 @@ func (PoCOrGTFO) Parse(options *parser.Options) (*feeds.Feed, error) {
 			item.Id = guid([]string{item.Title})
 			date := dateRegex.FindStringSubmatch(s.Text())
 			if len(date) < 2 || len(date[1]) <= 1 {
-				fmt.Println("unable to find date", s.Text())
+				options.Logger().Warn().Msgf("unable to find the date of %s", item.Title)
 				return
 			}
 			item.Created, err = time.Parse("January 2006", date[1])
 			if err != nil {
-				fmt.Println("unable to parse date", s.Text())
+				options.Logger().Warn().Err(err).Msgf("unable to parse the date of %s", item.Title)
 				return
 			}
 			feed.Items = append(feed.Items, &item)
@@ -0,0 +1,319 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+const ROBOTS_TXT_TTL = 24 * time.Hour
+const ROBOTS_TXT_TIMEOUT = 10 * time.Second
+
+// Politeness limits the requests of a module to its upstream hosts
+type Politeness = config.PolitenessConfig
+
+// PolitenessProvider is implemented by the modules making many requests to
+// the same hosts, declaring their default politeness
+type PolitenessProvider interface {
+	Politeness() Politeness
+}
+
+type hostLimiter struct {
+	sem  chan struct{}
+	mu   sync.Mutex
+	next time.Time
+}
+
+type robotsTxt struct {
+	crawlDelay time.Duration
+	expires    time.Time
+}
+
+var (
+	hostLimiters = make(map[string]*hostLimiter)
+	// the upstreams config the limiters were created with
+	limitersUpstreams *config.UpstreamsConfig
+	politenessMu      sync.Mutex
+	robotsTxts        = make(map[string]robotsTxt)
+	robotsTxtsMu      sync.Mutex
+	robotsTxtHttp     = &http.Client{Timeout: ROBOTS_TXT_TIMEOUT}
+)
+
+type moduleKey struct{}
+
+func withModule(ctx context.Context, p Parser) context.Context {
+	return context.WithValue(ctx, moduleKey{}, p)
+}
+
+// SetUpstreamsConfig applies the per host and per module politeness and
+// proxies overrides alone, keeping the rest of the current config
+func SetUpstreamsConfig(c *config.UpstreamsConfig) {
+	s := *config.Current()
+	file := *s.File
+	file.Upstreams = c
+	s.File = &file
+	config.Publish(&s)
+}
+
+func matchHost(pattern string, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// findHostPattern returns the pattern matching host: the host itself, or else
+// the longest matching wildcard, so that the most specific rule applies
+func findHostPattern[V any](patterns map[string]V, host string) (string, bool) {
+	if _, ok := patterns[host]; ok {
+		return host, true
+	}
+	best := ""
+	for pattern := range patterns {
+		if strings.HasPrefix(pattern, "*.") && matchHost(pattern, host) && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	return best, best != ""
+}
+
+// GetDefaultPoliteness returns the HOST_CONCURRENCY and HOST_DELAY politeness
+func GetDefaultPoliteness() (Politeness, error) {
+	concurrency, err := strconv.Atoi(config.GetConfigOption("HOST_CONCURRENCY"))
+	if err != nil || concurrency < 0 {
+		return Politeness{}, fmt.Errorf("invalid HOST_CONCURRENCY: positive number expected")
+	}
+	delay, err := time.ParseDuration(config.GetConfigOption("HOST_DELAY"))
+	if err != nil || delay < 0 {
+		return Politeness{}, fmt.Errorf("invalid HOST_DELAY: positive duration expected")
+	}
+	return Politeness{Concurrency: concurrency, Delay: delay}, nil
+}
+
+// getPoliteness returns the politeness of the requests made to host during a
+// parse, with the key of the limiter enforcing it: the settings of the host,
+// or of the module, or the global ones
+func getPoliteness(ctx context.Context, upstreams *config.UpstreamsConfig, host string) (string, Politeness) {
+	if pattern, ok := findHostPattern(upstreams.Hosts, host); ok {
+		return host, upstreams.Hosts[pattern]
+	}
+	if module, ok := ctx.Value(moduleKey{}).(Parser); ok {
+		if p, ok := upstreams.Modules[module.String()]; ok {
+			return module.String() + "|" + host, p
+		}
+		if provider, ok := module.(PolitenessProvider); ok {
+			return module.String() + "|" + host, provider.Politeness()
+		}
+	}
+	// validated on startup
+	p, _ := GetDefaultPoliteness()
+	return host, p
+}
+
+func getLimiter(key string, p Politeness) *hostLimiter {
+	l, ok := hostLimiters[key]
+	if !ok {
+		l = &hostLimiter{}
+		if p.Concurrency > 0 {
+			l.sem = make(chan struct{}, p.Concurrency)
+		}
+		hostLimiters[key] = l
+	}
+	return l
+}
+
+type limit struct {
+	limiter *hostLimiter
+	delay   time.Duration
+}
+
+// getHostLimits returns the limits of the requests made to host, the host
+// wide limit last: the module settings add up to the global ones of the host
+func getHostLimits(ctx context.Context, host string) []limit {
+	politenessMu.Lock()
+	defer politenessMu.Unlock()
+	upstreams := config.Current().Upstreams()
+	if upstreams != limitersUpstreams {
+		// the limiters are created again with the new settings
+		hostLimiters = make(map[string]*hostLimiter)
+		limitersUpstreams = upstreams
+	}
+	key, p := getPoliteness(ctx, upstreams, host)
+	limits := []limit{{getLimiter(key, p), p.Delay}}
+	if key != host {
+		// validated on startup
+		p, _ := GetDefaultPoliteness()
+		limits = append(limits, limit{getLimiter(host, p), p.Delay})
+	}
+	return limits
+}
+
+// acquire waits for a request slot, returning the function releasing it
+func (l *hostLimiter) acquire(ctx context.Context, delay time.Duration) (func(), error) {
+	release := func() {}
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+			release = func() { <-l.sem }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if delay <= 0 {
+		return release, nil
+	}
+	l.mu.Lock()
+	start := time.Now()
+	if l.next.After(start) {
+		start = l.next
+	}
+	l.next = start.Add(delay)
+	l.mu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return release, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return release, nil
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	}
+}
+
+// parseCrawlDelay returns the crawl-delay of the robots.txt group of the user
+// agent, or of the * group
+func parseCrawlDelay(robots string, userAgent string) time.Duration {
+	product := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	var agents []string
+	inRules := false
+	delays := make(map[string]time.Duration)
+	scanner := bufio.NewScanner(strings.NewReader(robots))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents = nil
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			for _, agent := range agents {
+				delays[agent] = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inRules = true
+		}
+	}
+	if d, ok := delays[product]; ok && product != "" {
+		return d
+	}
+	return delays["*"]
+}
+
+func fetchCrawlDelay(ctx context.Context, scheme string, host string) time.Duration {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s/robots.txt", scheme, host), nil)
+	if err != nil {
+		return 0
+	}
+	userAgent := config.GetConfigOption("USER_AGENT")
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	// fetched through the proxy of the requests it applies to
+	client, _ := withProxy(robotsTxtHttp, req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+	var robots strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		robots.WriteString(scanner.Text() + "\n")
+	}
+	return parseCrawlDelay(robots.String(), userAgent)
+}
+
+// getCrawlDelay returns the crawl-delay of the host robots.txt, fetched once a day
+func getCrawlDelay(ctx context.Context, scheme string, host string) time.Duration {
+	robotsTxtsMu.Lock()
+	cached, ok := robotsTxts[host]
+	robotsTxtsMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.crawlDelay
+	}
+	delay := fetchCrawlDelay(ctx, scheme, host)
+	robotsTxtsMu.Lock()
+	robotsTxts[host] = robotsTxt{crawlDelay: delay, expires: time.Now().Add(ROBOTS_TXT_TTL)}
+	robotsTxtsMu.Unlock()
+	return delay
+}
+
+// waitPoliteness queues the request until the limits of its host allow it,
+// returning the function to call once it's done
+func waitPoliteness(req *http.Request) (func(), error) {
+	host := strings.ToLower(req.URL.Hostname())
+	limits := getHostLimits(req.Context(), host)
+	if config.GetConfigOption("ROBOTS_TXT") == "true" {
+		last := &limits[len(limits)-1]
+		last.delay = max(last.delay, getCrawlDelay(req.Context(), req.URL.Scheme, req.URL.Host))
+	}
+	// always acquired in the same order, the module limit first
+	releases := make([]func(), 0, len(limits))
+	release := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for _, l := range limits {
+		r, err := l.limiter.acquire(req.Context(), l.delay)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+// releaseBody releases the request slot once the response body is closed,
+// the body being still downloaded from the upstream until then
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+func TestParseCrawlDelay(t *testing.T) {
+	robots := `# comment
+User-agent: *
+Disallow: /private
+Crawl-delay: 2
+
+User-agent: rss-banquet
+User-agent: other
+Crawl-delay: 0.5
+`
+	if d := parseCrawlDelay(robots, "rss-banquet/1.0"); d != 500*time.Millisecond {
+		t.Errorf("crawl-delay of the user agent group = %s", d)
+	}
+	if d := parseCrawlDelay(robots, "Mozilla/5.0"); d != 2*time.Second {
+		t.Errorf("crawl-delay of the * group = %s", d)
+	}
+	if d := parseCrawlDelay("User-agent: *\nDisallow:\n", ""); d != 0 {
+		t.Errorf("crawl-delay without directive = %s", d)
+	}
+}
+
+type politenessTestParser struct{}
+
+func (politenessTestParser) Parse(*Options) (*feeds.Feed, error) { return nil, nil }
+func (politenessTestParser) String() string                      { return "politenesstest" }
+func (politenessTestParser) GetOptions() Options                 { return Options{} }
+func (politenessTestParser) Politeness() Politeness {
+	return Politeness{Concurrency: 1, Delay: 20 * time.Millisecond}
+}
+
+func TestGetPoliteness(t *testing.T) {
+	SetUpstreamsConfig(&config.UpstreamsConfig{
+		Hosts: map[string]config.PolitenessConfig{
+			"*.example.com":     {Concurrency: 3},
+			"*.cdn.example.com": {Concurrency: 5},
+			"cdn.example.com":   {Concurrency: 6},
+		},
+		Modules: map[string]config.PolitenessConfig{"overridden": {Delay: time.Second}},
+	})
+	defer SetUpstreamsConfig(nil)
+
+	ctx := withModule(context.Background(), politenessTestParser{})
+	if key, p := getPoliteness(ctx, config.Current().Upstreams(), "www.example.com"); key != "www.example.com" || p.Concurrency != 3 {
+		t.Errorf("host settings should come first: %s %+v", key, p)
+	}
+	for range 20 {
+		if _, p := getPoliteness(ctx, config.Current().Upstreams(), "img.cdn.example.com"); p.Concurrency != 5 {
+			t.Fatalf("the longest wildcard should apply: %+v", p)
+		}
+		if _, p := getPoliteness(ctx, config.Current().Upstreams(), "cdn.example.com"); p.Concurrency != 6 {
+			t.Fatalf("the exact host should apply: %+v", p)
+		}
+	}
+	if key, p := getPoliteness(ctx, config.Current().Upstreams(), "example.org"); key != "politenesstest|example.org" || p.Concurrency != 1 {
+		t.Errorf("module defaults should apply: %s %+v", key, p)
+	}
+	if _, p := getPoliteness(context.Background(), config.Current().Upstreams(), "example.org"); p.Concurrency != 4 || p.Delay != 0 {
+		t.Errorf("global settings should apply outside of module parses: %+v", p)
+	}
+}
+
+func TestPoliteness(t *testing.T) {
+	var current, peak atomic.Int32
+	var mu sync.Mutex
+	var starts []time.Time
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		current.Add(-1)
+	}))
+	defer upstream.Close()
+
+	u, _ := url.Parse(upstream.URL)
+	SetUpstreamsConfig(&config.UpstreamsConfig{
+		Hosts: map[string]config.PolitenessConfig{u.Hostname(): {Concurrency: 2, Delay: 15 * time.Millisecond}},
+	})
+	defer SetUpstreamsConfig(nil)
+
+	// the first slot is taken no earlier than now, the sixth one 5 delays later
+	queued := time.Now()
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := HttpGet(upstream.URL, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 concurrent requests, got %d", peak.Load())
+	}
+	if len(starts) != 6 {
+		t.Fatalf("requests should be queued rather than failed, got %d", len(starts))
+	}
+	if d := starts[len(starts)-1].Sub(queued); d < 5*15*time.Millisecond {
+		t.Errorf("requests should be spaced by the delay, took %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := HttpGetWithContext(ctx, upstream.URL, nil); err == nil {
+		t.Errorf("queued requests should stop with their context")
+	}
+}
+
+func TestPolitenessModuleAndHost(t *testing.T) {
+	var current, peak atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		current.Add(-1)
+	}))
+	defer upstream.Close()
+
+	setConfigOption(t, "HOST_CONCURRENCY", "1")
+	// the limiters are created again with the new HOST_CONCURRENCY
+	SetUpstreamsConfig(&config.UpstreamsConfig{})
+	defer SetUpstreamsConfig(nil)
+
+	// the module settings add up to the limits of the host, shared with the other modules
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			if i%2 == 0 {
+				ctx = withModule(ctx, politenessTestParser{})
+			}
+			resp, err := HttpGetWithContext(ctx, upstream.URL, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 1 {
+		t.Errorf("expected at most 1 concurrent request to the host, got %d", peak.Load())
+	}
+
+	// the slot is held until the response body is closed
+	resp, err := HttpGet(upstream.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := HttpGetWithContext(ctx, upstream.URL, nil); err == nil {
+		t.Errorf("the request should wait for the previous response body to be closed")
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	if resp, err := HttpGet(upstream.URL, nil); err != nil {
+		t.Errorf("the slot should be released on close: %s", err)
+	} else {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/metrics"
+)
+
+// PREVIEW_PATH prefixes the feed paths previewed, eg /api/preview/feed/lego
+const PREVIEW_PATH = "/api/preview"
+
+// PREVIEW_ITEMS is the number of items of the previewed feeds
+const PREVIEW_ITEMS = 10
+
+// Preview is a feed parsed for the generator UI
+type Preview struct {
+	Module  string            `json:"module,omitempty"`
+	Options map[string]string `json:"options,omitempty"`
+	// Unknown lists the query parameters that are neither options nor filters
+	Unknown    []string `json:"unknown,omitempty"`
+	Cached     bool     `json:"cached"`
+	DurationMs int64    `json:"durationMs"`
+	ItemsCount int      `json:"itemsCount"`
+	Error      string   `json:"error,omitempty"`
+	ErrorClass string   `json:"errorClass,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	// Anomalies lists the possible scraper breakages found, as in the X-Banquet-Warning header
+	Anomalies []string `json:"anomalies,omitempty"`
+	// Feed is the JSON Feed of the first PREVIEW_ITEMS items
+	Feed json.RawMessage `json:"feed,omitempty"`
+}
+
+// PreviewFeedUrl returns the feed URL previewed by a request, its token removed
+func PreviewFeedUrl(u *url.URL) (*url.URL, bool) {
+	path, ok := strings.CutPrefix(u.Path, PREVIEW_PATH)
+	if !ok || !strings.HasPrefix(path, "/feed/") {
+		return nil, false
+	}
+	feedUrl := *u
+	query := feedUrl.Query()
+	query.Del(TOKEN_PARAMETER)
+	feedUrl.RawQuery = query.Encode()
+	feedUrl.Path = path
+	feedUrl.RawPath = ""
+	return &feedUrl, true
+}
+
+func getErrorStatus(err error) int {
+	switch err.(type) {
+	case *NotFoundError:
+		return http.StatusNotFound
+	case *BadRequestError:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// PreviewHandler serves the feeds of /api/preview/feed/... through the same
+// parse and cache path as /feed/..., returning their first items as JSON
+// along with the parse duration, warnings and error. The previews are left
+// out of the health and breakage baselines of the served feeds.
+func PreviewHandler(matcher *RouteMatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		preview := &Preview{}
+		u, ok := PreviewFeedUrl(c.Request.URL)
+		if !ok {
+			preview.Error, preview.ErrorClass = "not a feed path", "invalid"
+			c.JSON(http.StatusNotFound, preview)
+			return
+		}
+		match, err := matcher.Match(u.RequestURI())
+		if err != nil {
+			preview.Error, preview.ErrorClass = err.Error(), "invalid"
+			c.JSON(http.StatusBadRequest, preview)
+			return
+		}
+		preview.Module, preview.Options, preview.Unknown = match.Module, match.Values(), match.Unknown
+		c.Set(metrics.MODULE_KEY, match.Parser.String())
+		if !IsModuleEnabled(match.Parser.String()) {
+			preview.Error, preview.ErrorClass = "module disabled", "not_found"
+			c.JSON(http.StatusNotFound, preview)
+			return
+		}
+
+		ctx, warnings := WithWarnings(c.Request.Context())
+		o := &Options{OptionsList: match.Options, Parser: match.Parser}
+		o.SetContext(WithLogFields(ctx, map[string]string{"feed": FeedKey(u)}))
+		filter, err := ParseFeedFilter(match.Query, o)
+		if err != nil {
+			preview.Error, preview.ErrorClass = err.Error(), "invalid"
+			c.JSON(http.StatusBadRequest, preview)
+			return
+		}
+		start := time.Now()
+		served, err := ParsePreviewFeed(match.Parser, o, u, filter)
+		preview.DurationMs = time.Since(start).Milliseconds()
+		preview.Warnings = warnings.Warnings()
+		if err != nil {
+			preview.Error, preview.ErrorClass = err.Error(), GetErrorClass(err)
+			c.JSON(getErrorStatus(err), preview)
+			return
+		}
+
+		feed := served.Feed
+		preview.Cached, preview.Anomalies = served.Cached, served.Anomalies
+		preview.ItemsCount = len(feed.Items)
+		first := *feed
+		first.Items = feed.Items[:min(len(feed.Items), PREVIEW_ITEMS)]
+		data, err := ToJSON(&first, o)
+		if err != nil {
+			preview.Error, preview.ErrorClass = err.Error(), GetErrorClass(err)
+			c.JSON(http.StatusInternalServerError, preview)
+			return
+		}
+		preview.Feed = json.RawMessage(data)
+		c.JSON(http.StatusOK, preview)
+	}
+}
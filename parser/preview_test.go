@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+)
+
+type previewTestParser struct{}
+
+var validations atomic.Int32
+
+func (previewTestParser) Parse(o *Options) (*feeds.Feed, error) {
+	if o.Get("id") == "missing" {
+		return nil, NewNotFoundError("no such feed")
+	}
+	o.Logger().Warn().Msg("layout changed")
+	feed := &feeds.Feed{Title: "preview"}
+	for i := range 15 {
+		feed.Items = append(feed.Items, &feeds.Item{Title: fmt.Sprintf("item %d", i), Link: &feeds.Link{Href: "https://example.com"}})
+	}
+	return feed, nil
+}
+func (previewTestParser) String() string { return "previewtest" }
+func (previewTestParser) Validate(o *Options) error {
+	if o.Get("id") != "invalid" {
+		return nil
+	}
+	validations.Add(1)
+	return NewBadRequestError("invalid id")
+}
+func (p previewTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	setConfigOption(t, "FEED_CACHE_TTL", "1m")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET(PREVIEW_PATH+"/feed/*path", PreviewHandler(NewRouteMatcher(map[string]Parser{"previewTest": previewTestParser{}})))
+	get := func(path string) (int, Preview) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, PREVIEW_PATH+path, nil))
+		var preview Preview
+		if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		return w.Code, preview
+	}
+
+	code, preview := get("/feed/previewtest/1?token=secret&gone=1")
+	if code != 200 || preview.Module != "previewTest" || preview.ItemsCount != 15 || preview.Cached {
+		t.Errorf("unexpected preview %d %+v", code, preview)
+	}
+	var feed struct{ Items []any }
+	if err := json.Unmarshal(preview.Feed, &feed); err != nil || len(feed.Items) != PREVIEW_ITEMS {
+		t.Errorf("the preview should have %d items: %v", PREVIEW_ITEMS, err)
+	}
+	if len(preview.Warnings) != 1 || len(preview.Unknown) != 1 || preview.Unknown[0] != "gone" {
+		t.Errorf("the warnings and unknown options should be reported: %+v", preview)
+	}
+	if _, preview := get("/feed/previewtest/1?gone=1"); !preview.Cached || !IsFeedCached("/feed/previewtest/1?gone=1") {
+		t.Errorf("the previewed feeds should be cached as the served ones")
+	}
+	if last := GetLastParse("previewtest"); last != nil {
+		t.Errorf("the previewed parses shouldn't be recorded as the served ones: %+v", last)
+	}
+	baselinesMu.Lock()
+	_, ok := baselines["/feed/previewtest/1"]
+	baselinesMu.Unlock()
+	if ok {
+		t.Errorf("the previewed feeds shouldn't update the breakage baselines")
+	}
+	if code, preview := get("/feed/previewtest/invalid"); code != 400 || preview.ErrorClass != "invalid" || validations.Load() != 1 {
+		t.Errorf("the options should be validated once: %d %+v, %d validations", code, preview, validations.Load())
+	}
+	if code, preview := get("/feed/previewtest/1?attr.=1"); code != 400 || preview.ErrorClass != "invalid" {
+		t.Errorf("unexpected invalid filter preview %d %+v", code, preview)
+	}
+
+	if code, preview := get("/feed/previewtest/missing"); code != 404 || preview.ErrorClass != "not_found" {
+		t.Errorf("unexpected error preview %d %+v", code, preview)
+	}
+	if code, preview := get("/feed/gone"); code != 400 || preview.Error == "" {
+		t.Errorf("unexpected unknown module preview %d %+v", code, preview)
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+// PROXY_SETTING is the setting of every module overriding the global proxy
+var PROXY_SETTING = ModuleSetting{
+	Name:        "PROXY",
+	Description: "Proxy of the module upstream requests, overriding BANQUET_GLOBAL_PROXY, or direct",
+	Type:        "proxy",
+	Secret:      true,
+}
+
+var (
+	proxyTransports   = make(map[string]*http.Transport)
+	proxyTransportsMu sync.Mutex
+)
+
+// getProxy returns the proxy of the requests made to host during a parse, and
+// where it is configured: the proxies of the host patterns, or the module, or
+// the global one. An empty proxy leaves the choice to the environment.
+func getProxy(ctx context.Context, host string) (string, string) {
+	proxies := config.Current().Upstreams().Proxies
+	if pattern, ok := findHostPattern(proxies, host); ok {
+		return proxies[pattern], "host " + pattern
+	}
+	if module, ok := ctx.Value(moduleKey{}).(Parser); ok {
+		if proxy := getModuleSetting(module, PROXY_SETTING.Name); proxy != "" {
+			return proxy, "module " + module.String()
+		}
+	}
+	if proxy := config.GetConfigOption("PROXY"); proxy != "" {
+		return proxy, "global"
+	}
+	return "", ""
+}
+
+func getProxyTransport(proxy string) (*http.Transport, error) {
+	proxyTransportsMu.Lock()
+	defer proxyTransportsMu.Unlock()
+	if t, ok := proxyTransports[proxy]; ok {
+		return t, nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	if proxy != config.PROXY_DIRECT {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	proxyTransports[proxy] = t
+	return t, nil
+}
+
+// withProxy returns the client sending the request through its proxy, and
+// the proxy description, credentials redacted. Clients with their own
+// transport are left untouched.
+func withProxy(client *http.Client, req *http.Request) (*http.Client, string) {
+	proxy, source := getProxy(req.Context(), req.URL.Hostname())
+	if proxy == "" || client.Transport != nil {
+		return client, ""
+	}
+	t, err := getProxyTransport(proxy)
+	if err != nil {
+		// validated on startup and reload
+		return client, ""
+	}
+	proxied := *client
+	proxied.Transport = t
+	if proxy == config.PROXY_DIRECT {
+		return &proxied, proxy + " (" + source + ")"
+	}
+	u, _ := url.Parse(proxy)
+	return &proxied, u.Redacted() + " (" + source + ")"
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+type proxyTestParser struct{}
+
+func (proxyTestParser) Parse(*Options) (*feeds.Feed, error) { return nil, nil }
+func (proxyTestParser) String() string                      { return "proxytest" }
+func (proxyTestParser) GetOptions() Options                 { return Options{} }
+
+func TestProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "direct")
+	}))
+	defer upstream.Close()
+	proxyUrl := strings.Replace(proxy.URL, "http://", "http://user:pass@", 1)
+
+	t.Setenv("BANQUET_MODULE_PROXYTEST_PROXY", proxyUrl)
+	modules, err := ResolveModulesConfig(map[string]Parser{"proxytest": proxyTestParser{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetModulesConfig(modules)
+	defer SetModulesConfig(nil)
+
+	get := func(ctx context.Context, u string) string {
+		resp, err := HttpGetWithContext(ctx, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	ctx, capture := WithDebugCapture(withModule(context.Background(), proxyTestParser{}))
+	if body := get(ctx, "http://upstream.example/feed"); body != "proxied" {
+		t.Errorf("the module requests should be proxied: %s", body)
+	}
+	if len(proxied) != 1 || !strings.HasPrefix(proxied[0], "http://upstream.example/feed Basic ") {
+		t.Errorf("unexpected proxied requests %v", proxied)
+	}
+	if p := capture.Requests[0].Proxy; p != strings.Replace(proxyUrl, "pass", "xxxxx", 1)+" (module proxytest)" {
+		t.Errorf("the proxy should be shown redacted: %s", p)
+	}
+	if body := get(context.Background(), upstream.URL); body != "direct" {
+		t.Errorf("requests outside of the module should not be proxied: %s", body)
+	}
+
+	SetUpstreamsConfig(&config.UpstreamsConfig{Proxies: map[string]string{"127.0.0.1": config.PROXY_DIRECT}})
+	defer SetUpstreamsConfig(nil)
+	if body := get(withModule(context.Background(), proxyTestParser{}), upstream.URL); body != "direct" {
+		t.Errorf("the host proxy should override the module one: %s", body)
+	}
+}
+
+func TestGetProxyHostPatterns(t *testing.T) {
+	SetUpstreamsConfig(&config.UpstreamsConfig{Proxies: map[string]string{
+		"*.example.com":     "http://wildcard:8080",
+		"*.cdn.example.com": "http://cdn:8080",
+		"api.example.com":   config.PROXY_DIRECT,
+	}})
+	defer SetUpstreamsConfig(nil)
+
+	tests := map[string]string{
+		"www.example.com":     "host *.example.com",
+		"img.cdn.example.com": "host *.cdn.example.com",
+		"api.example.com":     "host api.example.com",
+	}
+	for range 20 {
+		for host, want := range tests {
+			if _, source := getProxy(context.Background(), host); source != want {
+				t.Fatalf("getProxy(%s) configured by %s, want %s", host, source, want)
+			}
+		}
+	}
+}
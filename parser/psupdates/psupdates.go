@@ -1,11 +1,13 @@
 package psupdates
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"regexp"
 
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/gorilla/feeds"
@@ -16,6 +18,30 @@ func (PSUpdates) String() string {
 	return "psupdates"
 }
 
+func init() {
+	parser.Register("psupdates", PSUpdatesParser)
+}
+
+func (PSUpdates) Description() string {
+	return "PlayStation system software updates"
+}
+
+func (PSUpdates) Homepage() string {
+	return "https://www.playstation.com"
+}
+
+func (PSUpdates) Category() string {
+	return "gaming"
+}
+
+func (PSUpdates) Icon() string {
+	return "https://www.playstation.com/favicon.ico"
+}
+
+func (PSUpdates) RefreshInterval() time.Duration {
+	return 12 * time.Hour
+}
+
 func (PSUpdates) GetOptions() parser.Options {
 	return parser.Options{
 		OptionsList: []*parser.Option{
@@ -25,6 +51,7 @@ func (PSUpdates) GetOptions() parser.Options {
 				Type:     "string",
 				Help:     "hardware of the updates",
 				Default:  "ps5",
+				Enum:     []string{"ps4", "ps5"},
 			},
 			{
 				Flag:     "local",
@@ -65,9 +92,9 @@ func getHardwareURL(hardware string, local string) string {
 	return fmt.Sprintf("https://www.playstation.com/%s/support/hardware/%s/system-software-info/", strings.ToLower(local), strings.ToLower(hardware))
 }
 
-func getUpdateFileUrl(hardware string, local string) (string, error) {
+func getUpdateFileUrl(ctx context.Context, hardware string, local string) (string, error) {
 	url := fmt.Sprintf("https://www.playstation.com/%s/support/hardware/%s/system-software/", strings.ToLower(local), strings.ToLower(hardware))
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(ctx, url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -77,7 +104,7 @@ func getUpdateFileUrl(hardware string, local string) (string, error) {
 		return "", fmt.Errorf("unable to fetch the update page, status code: %d", resp.StatusCode)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(ctx, resp.Body)
 	if err != nil {
 		return "", err
 	}
@@ -101,7 +128,7 @@ func (PSUpdates) Parse(options *parser.Options) (*feeds.Feed, error) {
 	local := options.Get("local").(string)
 	url := getHardwareURL(hardware, local)
 
-	resp, err := parser.HttpGet(url, nil)
+	resp, err := parser.HttpGetWithContext(options.Context(), url, nil)
 
 	if err != nil {
 		return nil, err
@@ -113,7 +140,7 @@ func (PSUpdates) Parse(options *parser.Options) (*feeds.Feed, error) {
 		return nil, fmt.Errorf("unable to fetch the update page, status code: %d", resp.StatusCode)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	doc, err := parser.DecodeHTML(options.Context(), resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -125,12 +152,12 @@ func (PSUpdates) Parse(options *parser.Options) (*feeds.Feed, error) {
 		return nil, err
 	}
 
-	fileUrl, err := getUpdateFileUrl(hardware, local)
+	fileUrl, err := getUpdateFileUrl(options.Context(), hardware, local)
 	if err != nil {
 		return nil, err
 	}
 
-	update.Created, err = parser.GetRemoteFileLastModified(fileUrl)
+	update.Created, err = parser.GetRemoteFileLastModified(options.Context(), fileUrl)
 	if err != nil {
 		return nil, err
 	}
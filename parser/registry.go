@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DescriptionProvider is implemented by the modules describing their feeds
+type DescriptionProvider interface {
+	Description() string
+}
+
+// HomepageProvider is implemented by the modules scraping a website
+type HomepageProvider interface {
+	Homepage() string
+}
+
+// CategoryProvider is implemented by the modules grouped in a category, such as books or security
+type CategoryProvider interface {
+	Category() string
+}
+
+// IconProvider is implemented by the modules having an icon, as an URL
+type IconProvider interface {
+	Icon() string
+}
+
+// RefreshIntervalProvider is implemented by the modules recommending how
+// often their feeds should be polled
+type RefreshIntervalProvider interface {
+	RefreshInterval() time.Duration
+}
+
+// Validator is implemented by the modules checking their options values
+// before parsing, invalid options being rejected with a 400
+type Validator interface {
+	Validate(o *Options) error
+}
+
+// validateEnums checks the options values against their Enum, each element
+// of the stringSlice ones
+func validateEnums(o *Options) error {
+	for _, option := range o.OptionsList {
+		if option.IsStatic || len(option.Enum) == 0 {
+			continue
+		}
+		value, _, err := o.OptionsList.Get(option.Flag)
+		if err != nil {
+			return err
+		}
+		values, ok := value.([]string)
+		if !ok {
+			values = []string{fmt.Sprint(value)}
+		}
+		for _, v := range values {
+			if !slices.Contains(option.Enum, v) {
+				return fmt.Errorf("invalid %s %s: expected one of %s", option.Flag, v, strings.Join(option.Enum, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateOptions checks the options values against their Enum, then runs
+// the Validate hook of the module
+func ValidateOptions(p Parser, o *Options) error {
+	if err := validateEnums(o); err != nil {
+		return NewBadRequestError(err.Error())
+	}
+	v, ok := p.(Validator)
+	if !ok {
+		return nil
+	}
+	if err := v.Validate(o); err != nil {
+		return NewBadRequestError(err.Error())
+	}
+	return nil
+}
+
+var (
+	registry   = make(map[string]func() Parser)
+	registryMu sync.RWMutex
+)
+
+// Register makes a module available under name, from the init function of its package
+func Register(name string, constructor func() Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("module %s registered twice", name))
+	}
+	registry[name] = constructor
+}
+
+// GetModules returns the constructors of the registered modules by name
+func GetModules() map[string]func() Parser {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return maps.Clone(registry)
+}
+
+// GetModuleNames returns the sorted names of the registered modules
+func GetModuleNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// ModuleInfo is the metadata of a module
+type ModuleInfo struct {
+	Name string `json:"name"`
+	// Route is the module name in the feed URLs
+	Route           string            `json:"route"`
+	Description     string            `json:"description,omitempty"`
+	Homepage        string            `json:"homepage,omitempty"`
+	Category        string            `json:"category,omitempty"`
+	Icon            string            `json:"icon,omitempty"`
+	RefreshInterval string            `json:"refreshInterval,omitempty"`
+	SampleOptions   map[string]string `json:"sampleOptions,omitempty"`
+	ExamplePath     string            `json:"examplePath"`
+}
+
+// formatDuration formats d without its zero minutes and seconds, eg 1h
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func GetModuleInfo(name string, p Parser) ModuleInfo {
+	info := ModuleInfo{Name: name, Route: p.String(), ExamplePath: ExamplePath(GetFullOptions(p))}
+	if d, ok := p.(DescriptionProvider); ok {
+		info.Description = d.Description()
+	}
+	if h, ok := p.(HomepageProvider); ok {
+		info.Homepage = h.Homepage()
+	}
+	if c, ok := p.(CategoryProvider); ok {
+		info.Category = c.Category()
+	}
+	if i, ok := p.(IconProvider); ok {
+		info.Icon = i.Icon()
+	}
+	if r, ok := p.(RefreshIntervalProvider); ok {
+		info.RefreshInterval = formatDuration(r.RefreshInterval())
+	}
+	if s, ok := p.(SampleOptionsProvider); ok {
+		info.SampleOptions = s.SampleOptions()
+	}
+	return info
+}
+
+// GetHelp formats the metadata as Options.GetHelp
+func (info ModuleInfo) GetHelp() string {
+	var help string
+	if info.Description != "" {
+		help += fmt.Sprintf("\t%s\n", info.Description)
+	}
+	for _, field := range [][2]string{
+		{"homepage", info.Homepage},
+		{"category", info.Category},
+		{"refresh interval", info.RefreshInterval},
+	} {
+		if field[1] != "" {
+			help += fmt.Sprintf("\t%s: %s\n", field[0], field[1])
+		}
+	}
+	return help
+}
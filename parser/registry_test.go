@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+)
+
+type registryTestParser struct {
+	parses *int
+}
+
+func (p registryTestParser) Parse(*Options) (*feeds.Feed, error) {
+	*p.parses++
+	return &feeds.Feed{Title: "registry"}, nil
+}
+func (registryTestParser) String() string      { return "registrytest" }
+func (registryTestParser) Description() string { return "Registry test feeds" }
+func (registryTestParser) Category() string    { return "test" }
+func (registryTestParser) RefreshInterval() time.Duration {
+	return 6 * time.Hour
+}
+func (registryTestParser) Validate(o *Options) error {
+	if o.Get("kind") != "a" && o.Get("kind") != "b" {
+		return fmt.Errorf("kind must be a or b")
+	}
+	return nil
+}
+func (p registryTestParser) GetOptions() Options {
+	return Options{OptionsList: OptionsList{
+		{Flag: "kind", Type: "string", Required: true},
+		{Flag: "sizes", Type: "stringSlice", Default: "s", Enum: []string{"s", "m", "l"}},
+		{Flag: "color", Type: "string", Default: "red", Enum: []string{"red", "blue"}},
+	}, Parser: p}
+}
+
+func TestRegister(t *testing.T) {
+	parses := 0
+	constructor := func() Parser { return registryTestParser{parses: &parses} }
+	Register("registryTest", constructor)
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "registryTest")
+		registryMu.Unlock()
+	}()
+
+	if _, ok := GetModules()["registryTest"]; !ok || !slices.Contains(GetModuleNames(), "registryTest") {
+		t.Errorf("registryTest should be registered")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Register() should panic on duplicates")
+			}
+		}()
+		Register("registryTest", constructor)
+	}()
+
+	info := GetModuleInfo("registryTest", constructor())
+	if info.Route != "registrytest" || info.Category != "test" || info.RefreshInterval != "6h" || info.ExamplePath != "/feed/registrytest/:kind" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if help := info.GetHelp(); !strings.Contains(help, "Registry test feeds") || !strings.Contains(help, "refresh interval: 6h") {
+		t.Errorf("unexpected help %q", help)
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	parses := 0
+	p := registryTestParser{parses: &parses}
+	Route(r, p, GetFullOptions(p))
+
+	for path, want := range map[string]int{
+		"/feed/registrytest/a":                      200,
+		"/feed/registrytest/c":                      400,
+		"/feed/registrytest/b?sizes=s,l&color=blue": 200,
+		"/feed/registrytest/a?sizes=s,xl":           400,
+		"/feed/registrytest/a?color=green":          400,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s: status %d, want %d", path, w.Code, want)
+		}
+	}
+	if parses != 2 {
+		t.Errorf("invalid options shouldn't be parsed, got %d parses", parses)
+	}
+
+	o := GetFullOptions(p)
+	o.OptionsList[0].Value = "c"
+	_, err := ParseFeed(p, o, nil)
+	var badRequest *BadRequestError
+	if !errors.As(err, &badRequest) || GetErrorClass(err) != "invalid" {
+		t.Errorf("ParseFeed() should fail with a BadRequestError: %v", err)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{time.Hour: "1h", 90 * time.Minute: "1h30m", 30 * time.Second: "30s", 168 * time.Hour: "168h"} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%s) = %s, want %s", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gorilla/feeds"
+)
+
+const MEDIA_NAMESPACE = "http://search.yahoo.com/mrss/"
+const ATOM_NAMESPACE = "http://www.w3.org/2005/Atom"
+
+// The gorilla/feeds serializers are wrapped so that the item extensions can
+// be added to their output
+
+type mediaContent struct {
+	XMLName  xml.Name `xml:"media:content"`
+	Url      string   `xml:"url,attr"`
+	Type     string   `xml:"type,attr,omitempty"`
+	FileSize string   `xml:"fileSize,attr,omitempty"`
+	Medium   string   `xml:"medium,attr,omitempty"`
+	Width    int      `xml:"width,attr,omitempty"`
+	Height   int      `xml:"height,attr,omitempty"`
+	Title    string   `xml:"media:title,omitempty"`
+}
+
+type mediaThumbnail struct {
+	XMLName xml.Name `xml:"media:thumbnail"`
+	Url     string   `xml:"url,attr"`
+	Width   int      `xml:"width,attr,omitempty"`
+	Height  int      `xml:"height,attr,omitempty"`
+}
+
+type mediaElements struct {
+	Thumbnail *mediaThumbnail
+	Contents  []*mediaContent
+}
+
+func newMediaElements(ext *ItemExtensions) mediaElements {
+	var m mediaElements
+	if ext == nil {
+		return m
+	}
+	for _, media := range ext.Media {
+		c := &mediaContent{
+			Url:    media.Url,
+			Type:   media.Type,
+			Medium: media.Medium,
+			Width:  media.Width,
+			Height: media.Height,
+			Title:  media.Title,
+		}
+		if media.Length > 0 {
+			c.FileSize = strconv.FormatInt(media.Length, 10)
+		}
+		m.Contents = append(m.Contents, c)
+		if m.Thumbnail == nil && media.IsImage() {
+			m.Thumbnail = &mediaThumbnail{Url: media.Url, Width: media.Width, Height: media.Height}
+		}
+	}
+	return m
+}
+
+type rssCategory struct {
+	XMLName xml.Name `xml:"category"`
+	Domain  string   `xml:"domain,attr,omitempty"`
+	Value   string   `xml:",chardata"`
+}
+
+type atomCategory struct {
+	XMLName xml.Name `xml:"category"`
+	Term    string   `xml:"term,attr"`
+	Scheme  string   `xml:"scheme,attr,omitempty"`
+	Label   string   `xml:"label,attr,omitempty"`
+}
+
+type itemCategory struct {
+	Domain string
+	Value  string
+}
+
+// attributes are exposed as categories after the item ones, using their name as the domain
+func getItemCategories(ext *ItemExtensions) []itemCategory {
+	var categories []itemCategory
+	if ext == nil {
+		return categories
+	}
+	for _, c := range ext.Categories {
+		categories = append(categories, itemCategory{Value: c})
+	}
+	for _, name := range ext.Attributes.Names() {
+		if tags, ok := ext.Attributes[name].(Tags); ok {
+			for _, tag := range tags {
+				categories = append(categories, itemCategory{Domain: name, Value: tag})
+			}
+			continue
+		}
+		categories = append(categories, itemCategory{Domain: name, Value: ext.Attributes[name].String()})
+	}
+	return categories
+}
+
+func newRssCategories(ext *ItemExtensions) []*rssCategory {
+	var categories []*rssCategory
+	for _, c := range getItemCategories(ext) {
+		categories = append(categories, &rssCategory{Domain: c.Domain, Value: c.Value})
+	}
+	return categories
+}
+
+func newAtomCategories(ext *ItemExtensions) []*atomCategory {
+	var categories []*atomCategory
+	for _, c := range getItemCategories(ext) {
+		category := &atomCategory{Term: c.Value, Scheme: c.Domain}
+		if c.Domain != "" {
+			category.Label = fmt.Sprintf("%s: %s", c.Domain, c.Value)
+		}
+		categories = append(categories, category)
+	}
+	return categories
+}
+
+type jsonAttribute struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// jsonExtension is exposed as the `_banquet` JSON Feed extension object
+type jsonExtension struct {
+	Attributes map[string]jsonAttribute `json:"attributes,omitempty"`
+}
+
+func newJSONExtension(ext *ItemExtensions) *jsonExtension {
+	if ext == nil || len(ext.Attributes) == 0 {
+		return nil
+	}
+	e := &jsonExtension{Attributes: make(map[string]jsonAttribute)}
+	for name, value := range ext.Attributes {
+		e.Attributes[name] = jsonAttribute{Type: value.Kind(), Value: value}
+	}
+	return e
+}
+
+type rssItem struct {
+	*feeds.RssItem
+	Categories []*rssCategory
+	mediaElements
+}
+
+type rssAtomLink struct {
+	XMLName xml.Name `xml:"atom:link"`
+	Href    string   `xml:"href,attr"`
+	Rel     string   `xml:"rel,attr"`
+}
+
+type rssChannel struct {
+	*feeds.RssFeed
+	Links []*rssAtomLink
+	Items []*rssItem `xml:"item"`
+}
+
+type rssFeedXml struct {
+	XMLName          xml.Name `xml:"rss"`
+	Version          string   `xml:"version,attr"`
+	ContentNamespace string   `xml:"xmlns:content,attr"`
+	MediaNamespace   string   `xml:"xmlns:media,attr"`
+	AtomNamespace    string   `xml:"xmlns:atom,attr,omitempty"`
+	Channel          *rssChannel
+}
+
+func (r *rssFeedXml) FeedXml() interface{} {
+	return r
+}
+
+type atomEntry struct {
+	*feeds.AtomEntry
+	Categories []*atomCategory
+	mediaElements
+}
+
+type atomFeed struct {
+	*feeds.AtomFeed
+	MediaNamespace string            `xml:"xmlns:media,attr"`
+	Link           []*feeds.AtomLink `xml:"link"`
+	Entries        []*atomEntry      `xml:"entry"`
+}
+
+func (a *atomFeed) FeedXml() interface{} {
+	return a
+}
+
+type jsonItem struct {
+	*feeds.JSONItem
+	Extension *jsonExtension `json:"_banquet,omitempty"`
+}
+
+type jsonFeed struct {
+	*feeds.JSONFeed
+	Items []*jsonItem `json:"items,omitempty"`
+}
+
+func ToRss(f *feeds.Feed, o *Options) (string, error) {
+	channel := (&feeds.Rss{Feed: f}).RssFeed()
+	c := &rssChannel{RssFeed: channel}
+	for _, link := range o.GetFeedLinks() {
+		c.Links = append(c.Links, &rssAtomLink{Href: link.Href, Rel: link.Rel})
+	}
+	for i, item := range channel.Items {
+		ext := o.GetExtensions(f.Items[i])
+		c.Items = append(c.Items, &rssItem{
+			RssItem:       item,
+			Categories:    newRssCategories(ext),
+			mediaElements: newMediaElements(ext),
+		})
+	}
+	x := &rssFeedXml{
+		Version:          "2.0",
+		ContentNamespace: "http://purl.org/rss/1.0/modules/content/",
+		MediaNamespace:   MEDIA_NAMESPACE,
+		Channel:          c,
+	}
+	if len(c.Links) > 0 {
+		x.AtomNamespace = ATOM_NAMESPACE
+	}
+	return feeds.ToXML(x)
+}
+
+func ToAtom(f *feeds.Feed, o *Options) (string, error) {
+	feed := (&feeds.Atom{Feed: f}).AtomFeed()
+	a := &atomFeed{AtomFeed: feed, MediaNamespace: MEDIA_NAMESPACE}
+	if feed.Link != nil {
+		a.Link = append(a.Link, feed.Link)
+	}
+	for _, link := range o.GetFeedLinks() {
+		a.Link = append(a.Link, &feeds.AtomLink{Href: link.Href, Rel: link.Rel})
+	}
+	for i, entry := range feed.Entries {
+		ext := o.GetExtensions(f.Items[i])
+		if ext != nil {
+			// the first media is already exposed as the enclosure link
+			for j, media := range ext.Media {
+				if j == 0 && f.Items[i].Enclosure != nil && f.Items[i].Enclosure.Url == media.Url {
+					continue
+				}
+				link := feeds.AtomLink{Href: media.Url, Rel: "enclosure", Type: media.Type}
+				if media.Length > 0 {
+					link.Length = strconv.FormatInt(media.Length, 10)
+				}
+				entry.Links = append(entry.Links, link)
+			}
+		}
+		a.Entries = append(a.Entries, &atomEntry{
+			AtomEntry:     entry,
+			Categories:    newAtomCategories(ext),
+			mediaElements: newMediaElements(ext),
+		})
+	}
+	return feeds.ToXML(a)
+}
+
+func newJSONFeed(f *feeds.Feed, o *Options) *jsonFeed {
+	feed := (&feeds.JSON{Feed: f}).JSONFeed()
+	j := &jsonFeed{JSONFeed: feed}
+	for _, link := range o.GetFeedLinks() {
+		switch link.Rel {
+		case "self":
+			j.FeedUrl = link.Href
+		case "hub":
+			j.Hubs = append(j.Hubs, &feeds.JSONHub{Type: "WebSub", Url: link.Href})
+		}
+	}
+	for i, item := range feed.Items {
+		ext := o.GetExtensions(f.Items[i])
+		if ext != nil {
+			item.Attachments = nil
+			for _, media := range ext.Media {
+				if item.Image == "" && media.IsImage() {
+					item.Image = media.Url
+				}
+				attachment := feeds.JSONAttachment{
+					Url:      media.Url,
+					MIMEType: media.Type,
+					Title:    media.Title,
+				}
+				if media.Length <= math.MaxInt32 {
+					attachment.Size = int32(media.Length)
+				}
+				item.Attachments = append(item.Attachments, attachment)
+			}
+		}
+		if ext != nil {
+			item.Tags = ext.Categories
+		}
+		j.Items = append(j.Items, &jsonItem{JSONItem: item, Extension: newJSONExtension(ext)})
+	}
+	return j
+}
+
+func ToJSON(f *feeds.Feed, o *Options) (string, error) {
+	data, err := json.MarshalIndent(newJSONFeed(f, o), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToJSONItems serializes each item of the feed as a JSON Feed item
+func ToJSONItems(f *feeds.Feed, o *Options) ([]string, error) {
+	var items []string
+	for _, item := range newJSONFeed(f, o).Items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, string(data))
+	}
+	return items, nil
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+const FEED_LINKS_CONTEXT_KEY = "feedLinks"
+
+// AddContextFeedLink adds a link to the feed served by the request, for middlewares running before Route
+func AddContextFeedLink(c *gin.Context, rel string, href string) {
+	c.Set(FEED_LINKS_CONTEXT_KEY, append(GetContextFeedLinks(c), FeedLink{Rel: rel, Href: href}))
+}
+
+func GetContextFeedLinks(c *gin.Context) []FeedLink {
+	links, _ := c.Get(FEED_LINKS_CONTEXT_KEY)
+	l, _ := links.([]FeedLink)
+	return l
+}
+
+const TOKEN_PARAMETER = "token"
+
+// TOKEN_NAME_KEY is the gin context key of the name of the valid access token of the request
+const TOKEN_NAME_KEY = "tokenName"
+
+// FeedKey identifies a served feed by its path and the query parameters affecting its items
+func FeedKey(u *url.URL) string {
+	query := u.Query()
+	query.Del("feedFormat")
+	query.Del(TOKEN_PARAMETER)
+	if len(query) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + query.Encode()
+}
+
+// RedactUrl returns the url without its token query parameter, for logs, traces and links
+func RedactUrl(u *url.URL) *url.URL {
+	query := u.Query()
+	if !query.Has(TOKEN_PARAMETER) {
+		return u
+	}
+	redacted := *u
+	query.Del(TOKEN_PARAMETER)
+	redacted.RawQuery = query.Encode()
+	return &redacted
+}
+
+// GetRequestToken returns the access token of the request, read from its
+// Authorization header (bearer token or basic auth password) or token query parameter
+func GetRequestToken(c *gin.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if _, password, ok := c.Request.BasicAuth(); ok {
+		return password
+	}
+	return c.Query(TOKEN_PARAMETER)
+}
+
+// GetBaseUrl returns the public URL of the server, from the BASE_URL config or the request
+func GetBaseUrl(c *gin.Context) string {
+	baseUrl := config.GetConfigOption("BASE_URL")
+	if baseUrl != "" {
+		return strings.TrimSuffix(baseUrl, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
+}
+
+func escapePathValue(option *Option, value string) string {
+	if !option.IsPath {
+		return url.PathEscape(value)
+	}
+	segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// FeedPath builds the path serving the feed with the given options values,
+// options left to their default value are omitted from the query string
+func FeedPath(o *Options, values map[string]string) (string, error) {
+	urlPath := []string{o.Get("route").(string)}
+	query := url.Values{}
+	known := make(map[string]bool)
+	for _, option := range o.OptionsList {
+		known[option.Flag] = true
+		if option.IsStatic {
+			continue
+		}
+		value, ok := values[option.Flag]
+		if option.Required {
+			if !ok || value == "" {
+				value = option.Default
+			}
+			if value == "" {
+				return "", fmt.Errorf("missing required parameter: %s", option.Flag)
+			}
+			urlPath = append(urlPath, escapePathValue(option, value))
+		} else if ok && value != option.Default {
+			query.Set(option.Flag, value)
+		}
+	}
+	for flag := range values {
+		if !known[flag] {
+			return "", fmt.Errorf("unknown option: %s", flag)
+		}
+	}
+	path := fmt.Sprintf("/feed/%s", strings.Join(urlPath, "/"))
+	if len(query) > 0 {
+		path = fmt.Sprintf("%s?%s", path, query.Encode())
+	}
+	return path, nil
+}
+
+// ExamplePath builds a feed path from the options defaults, required options
+// without default are left as route placeholders
+func ExamplePath(o *Options) string {
+	urlPath := []string{o.Get("route").(string)}
+	for _, option := range o.OptionsList {
+		if option.IsStatic || !option.Required {
+			continue
+		}
+		if option.Default != "" {
+			urlPath = append(urlPath, escapePathValue(option, option.Default))
+		} else {
+			urlPath = append(urlPath, fmt.Sprintf(":%s", option.Flag))
+		}
+	}
+	return fmt.Sprintf("/feed/%s", strings.Join(urlPath, "/"))
+}
+
+type RouteMatch struct {
+	Module  string
+	Parser  Parser
+	Options OptionsList
+	Query   url.Values
+	// Unknown lists the query parameters that are neither options nor filters
+	Unknown []string
+}
+
+// Parse parses the matched feed as Route would serve it
+func (m *RouteMatch) Parse() (*feeds.Feed, *Options, error) {
+	return m.ParseWithContext(context.Background())
+}
+
+// ParseWithContext parses the matched feed with the context, carrying its logger
+func (m *RouteMatch) ParseWithContext(ctx context.Context) (*feeds.Feed, *Options, error) {
+	o := &Options{OptionsList: m.Options, Parser: m.Parser, ctx: ctx}
+	filter, err := ParseFeedFilter(m.Query, o)
+	if err != nil {
+		return nil, nil, err
+	}
+	feed, err := ParseFeed(m.Parser, o, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return feed, o, nil
+}
+
+// Format returns the requested feed format
+func (m *RouteMatch) Format() string {
+	return m.Query.Get("feedFormat")
+}
+
+// Values returns the options set to a non default value
+func (m *RouteMatch) Values() map[string]string {
+	values := make(map[string]string)
+	for _, option := range m.Options {
+		if option.IsStatic {
+			continue
+		}
+		value, ok := option.Value.(string)
+		if !ok || value == option.Default {
+			continue
+		}
+		if option.IsPath {
+			value = strings.TrimPrefix(value, "/")
+		}
+		values[option.Flag] = value
+	}
+	return values
+}
+
+// RouteMatcher resolves feed URLs to modules with the routes used by the server
+type RouteMatcher struct {
+	engine *gin.Engine
+}
+
+type matchResultKey struct{}
+
+type matchResult struct {
+	match *RouteMatch
+	err   error
+}
+
+func NewRouteMatcher(modules map[string]Parser) *RouteMatcher {
+	engine := gin.New()
+	for name, p := range modules {
+		o := GetFullOptions(p)
+		engine.GET(RoutePath(o), func(c *gin.Context) {
+			res := c.Request.Context().Value(matchResultKey{}).(*matchResult)
+			if !IsModuleEnabled(p.String()) {
+				res.err = fmt.Errorf("module %s is disabled", name)
+				return
+			}
+			options, err := GetRequestOptions(c, o)
+			if err != nil {
+				res.err = err
+				return
+			}
+			match := &RouteMatch{Module: name, Parser: p, Options: options, Query: c.Request.URL.Query()}
+			parseOptions := &Options{OptionsList: options, Parser: p}
+			for key := range c.Request.URL.Query() {
+				if slices.ContainsFunc(options, func(option *Option) bool { return option.Flag == key }) {
+					continue
+				}
+				if IsFilterParameter(key, parseOptions) {
+					continue
+				}
+				match.Unknown = append(match.Unknown, key)
+			}
+			sort.Strings(match.Unknown)
+			res.match = match
+		})
+	}
+	return &RouteMatcher{engine: engine}
+}
+
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// Match resolves a feed URL, absolute or relative, served under any path prefix
+func (m *RouteMatcher) Match(feedUrl string) (*RouteMatch, error) {
+	u, err := url.Parse(feedUrl)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.Index(u.Path, "/feed/")
+	if i < 0 {
+		return nil, fmt.Errorf("not a feed url: %s", feedUrl)
+	}
+	u.Path = u.Path[i:]
+	u.RawPath = ""
+
+	res := &matchResult{}
+	ctx := context.WithValue(context.Background(), matchResultKey{}, res)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.RequestURI(), nil)
+	if err != nil {
+		return nil, err
+	}
+	m.engine.ServeHTTP(&discardResponseWriter{header: make(http.Header)}, req)
+	if res.err != nil {
+		return nil, res.err
+	}
+	if res.match == nil {
+		return nil, fmt.Errorf("no module serves %s", u.Path)
+	}
+	return res.match, nil
+}
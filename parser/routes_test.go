@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+)
+
+type routeTestParser struct{}
+
+func (routeTestParser) Parse(*Options) (*feeds.Feed, error) { return nil, nil }
+func (routeTestParser) String() string                      { return "routetest" }
+func (routeTestParser) GetOptions() Options {
+	return Options{
+		OptionsList: OptionsList{
+			{Flag: "image", Required: true, Type: "string", IsPath: true},
+			{Flag: "platform", Type: "string", Default: "linux/amd64"},
+			{Flag: "count", Type: "int", Default: "10"},
+		},
+		Parser: routeTestParser{},
+	}
+}
+
+func TestFeedPath(t *testing.T) {
+	o := GetFullOptions(routeTestParser{})
+	path, err := FeedPath(o, map[string]string{"image": "nbr23/rss banquet:latest", "count": "20", "platform": "linux/amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/feed/routetest/nbr23/rss%20banquet:latest?count=20"; path != want {
+		t.Errorf("FeedPath() = %s, want %s", path, want)
+	}
+	if _, err := FeedPath(o, map[string]string{"count": "20"}); err == nil {
+		t.Errorf("FeedPath() should fail without the required option")
+	}
+	if _, err := FeedPath(o, map[string]string{"image": "a", "gone": "1"}); err == nil {
+		t.Errorf("FeedPath() should fail with an unknown option")
+	}
+	if got := ExamplePath(o); got != "/feed/routetest/:image" {
+		t.Errorf("ExamplePath() = %s", got)
+	}
+}
+
+func TestRouteMatcher(t *testing.T) {
+	m := NewRouteMatcher(map[string]Parser{"routeTest": routeTestParser{}})
+
+	match, err := m.Match("https://example.com/rss/feed/routetest/nbr23/rss%20banquet:latest?count=20&attr.tag=1&gone=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.Module != "routeTest" {
+		t.Errorf("unexpected module %s", match.Module)
+	}
+	want := map[string]string{"image": "nbr23/rss banquet:latest", "count": "20"}
+	if got := match.Values(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(match.Unknown, []string{"gone"}) {
+		t.Errorf("Unknown = %v", match.Unknown)
+	}
+
+	if _, err := m.Match("https://example.com/feed/missing"); err == nil {
+		t.Errorf("Match() should fail for unknown modules")
+	}
+}
+
+func TestRedactUrl(t *testing.T) {
+	u, _ := url.Parse("/feed/routetest/nbr23?token=secret&count=20")
+	if got := RedactUrl(u).RequestURI(); got != "/feed/routetest/nbr23?count=20" {
+		t.Errorf("RedactUrl() = %s", got)
+	}
+	if u.RawQuery != "token=secret&count=20" {
+		t.Errorf("RedactUrl() shouldn't modify its argument")
+	}
+}
+
+func TestGetBaseUrl(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/opml", nil)
+	c.Request.Host = "banquet.local:8080"
+	if got := GetBaseUrl(c); got != "http://banquet.local:8080" {
+		t.Errorf("GetBaseUrl() = %s", got)
+	}
+	c.Request.Header.Set("X-Forwarded-Proto", "https")
+	if got := GetBaseUrl(c); got != "https://banquet.local:8080" {
+		t.Errorf("GetBaseUrl() = %s, want the forwarded scheme", got)
+	}
+	setConfigOption(t, "BASE_URL", "https://banquet.example.com/")
+	if got := GetBaseUrl(c); got != "https://banquet.example.com" {
+		t.Errorf("GetBaseUrl() = %s, want the BASE_URL", got)
+	}
+}
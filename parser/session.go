@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+// Session is the state a module keeps across parses: the cookies of its
+// upstream requests and its tokens, persisted in the CACHE_DIR
+type Session struct {
+	module string
+	jar    *cookiejar.Jar
+	mu     sync.Mutex
+	// cookies are the cookies set by url, as persisted
+	cookies map[string]map[string]*http.Cookie
+	tokens  map[string]SessionToken
+	// fetchMu serializes the token fetches, concurrent requests sharing a fetch
+	fetchMu sync.Mutex
+}
+
+type SessionToken struct {
+	Value string `json:"value"`
+	// ExpireAt is zero for the tokens valid until rejected
+	ExpireAt time.Time `json:"expireAt,omitempty"`
+}
+
+type persistedSession struct {
+	Cookies map[string][]*http.Cookie `json:"cookies"`
+	Tokens  map[string]SessionToken   `json:"tokens"`
+}
+
+var (
+	sessions   = make(map[string]*Session)
+	sessionsMu sync.Mutex
+)
+
+func newSession(module string) *Session {
+	// the jar can't fail without options
+	jar, _ := cookiejar.New(nil)
+	return &Session{module: module, jar: jar, cookies: make(map[string]map[string]*http.Cookie), tokens: make(map[string]SessionToken)}
+}
+
+func sessionFile(module string) string {
+	return GetCacheFile(fmt.Sprintf("session-%s.json", module))
+}
+
+// GetSession returns the session of the module, restored from the CACHE_DIR on first use
+func GetSession(module string) *Session {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	if s, ok := sessions[module]; ok {
+		return s
+	}
+	s := newSession(module)
+	s.load()
+	sessions[module] = s
+	return s
+}
+
+// GetContextSession returns the session of the module parsing with the
+// context, or a session kept for the caller only outside of module parses
+func GetContextSession(ctx context.Context) *Session {
+	if module, ok := ctx.Value(moduleKey{}).(Parser); ok {
+		return GetSession(module.String())
+	}
+	return newSession("")
+}
+
+func (o *Options) Session() *Session {
+	return GetContextSession(o.Context())
+}
+
+func (s *Session) load() {
+	path := sessionFile(s.module)
+	if path == "" {
+		return
+	}
+	var persisted persistedSession
+	if err := utils.ReadJSONFile(path, &persisted); err != nil {
+		GetLogger(context.Background()).Warn().Err(err).Msgf("unable to load the session %s", path)
+		return
+	}
+	now := time.Now()
+	for rawUrl, cookies := range persisted.Cookies {
+		u, err := url.Parse(rawUrl)
+		if err != nil {
+			continue
+		}
+		var valid []*http.Cookie
+		for _, cookie := range cookies {
+			if cookie.Expires.IsZero() || cookie.Expires.After(now) {
+				valid = append(valid, cookie)
+			}
+		}
+		s.SetCookies(u, valid)
+	}
+	for name, token := range persisted.Tokens {
+		if token.ExpireAt.IsZero() || token.ExpireAt.After(now) {
+			s.tokens[name] = token
+		}
+	}
+}
+
+// Save persists the session in the CACHE_DIR
+func (s *Session) Save() error {
+	path := sessionFile(s.module)
+	if path == "" || s.module == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	persisted := persistedSession{Cookies: make(map[string][]*http.Cookie, len(s.cookies)), Tokens: s.tokens}
+	for u, cookies := range s.cookies {
+		for _, cookie := range cookies {
+			persisted.Cookies[u] = append(persisted.Cookies[u], cookie)
+		}
+	}
+	return utils.WriteJSONFile(path, persisted)
+}
+
+// SetCookies implements http.CookieJar, recording the cookies to persist
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.jar.SetCookies(u, cookies)
+	key := u.Scheme + "://" + u.Host
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cookies[key] == nil {
+		s.cookies[key] = make(map[string]*http.Cookie)
+	}
+	for _, cookie := range cookies {
+		c := *cookie
+		if c.MaxAge > 0 {
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		if c.MaxAge < 0 || !c.Expires.IsZero() && c.Expires.Before(time.Now()) {
+			delete(s.cookies[key], c.Name)
+			continue
+		}
+		s.cookies[key][c.Name] = &c
+	}
+}
+
+// Cookies implements http.CookieJar
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	return s.jar.Cookies(u)
+}
+
+// Cookie returns the value of the named cookie sent to u, or ""
+func (s *Session) Cookie(u *url.URL, name string) string {
+	for _, cookie := range s.jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// Token returns the named token, fetched when missing or expired, the
+// concurrent requests sharing a single fetch. fetch returns the token and its
+// lifetime, 0 for a token valid until rejected.
+func (s *Session) Token(ctx context.Context, name string, fetch func(ctx context.Context) (string, time.Duration, error)) (string, error) {
+	if token, ok := s.getToken(name); ok {
+		return token, nil
+	}
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	// fetched while waiting
+	if token, ok := s.getToken(name); ok {
+		return token, nil
+	}
+	value, ttl, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	token := SessionToken{Value: value}
+	if ttl > 0 {
+		token.ExpireAt = time.Now().Add(ttl)
+	}
+	s.mu.Lock()
+	s.tokens[name] = token
+	s.mu.Unlock()
+	if err := s.Save(); err != nil {
+		GetLogger(ctx).Warn().Err(err).Msgf("unable to persist the %s session", s.module)
+	}
+	return value, nil
+}
+
+func (s *Session) getToken(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[name]
+	if !ok || !token.ExpireAt.IsZero() && time.Now().After(token.ExpireAt) {
+		return "", false
+	}
+	return token.Value, true
+}
+
+// InvalidateToken drops the token rejected by an upstream, unless it was
+// already refreshed by a concurrent request
+func (s *Session) InvalidateToken(name string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens[name].Value == value {
+		delete(s.tokens, name)
+	}
+}
+
+// DoWithToken sends the request built with the named token, refreshing the
+// token and retrying once when the upstream rejects it with a 401 or 403
+func (s *Session) DoWithToken(ctx context.Context, name string, fetch func(ctx context.Context) (string, time.Duration, error), do func(token string) (*http.Response, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := s.Token(ctx, name, fetch)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := do(token)
+		if err != nil || attempt > 0 || resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden {
+			return resp, err
+		}
+		resp.Body.Close()
+		GetLogger(ctx).Debug().Msgf("%s rejected with status %d, refreshing it", name, resp.StatusCode)
+		s.InvalidateToken(name, token)
+	}
+}
+
+// withSession returns the client keeping the cookies in the module session,
+// clients with their own jar being left untouched
+func withSession(client *http.Client, req *http.Request) *http.Client {
+	if client.Jar != nil {
+		return client
+	}
+	if _, ok := req.Context().Value(moduleKey{}).(Parser); !ok {
+		return client
+	}
+	c := *client
+	c.Jar = GetContextSession(req.Context())
+	return &c
+}
+
+// SaveSessions persists the sessions of the modules
+func SaveSessions(ctx context.Context) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	for module, s := range sessions {
+		if err := s.Save(); err != nil {
+			GetLogger(ctx).Error().Err(err).Str("module", module).Msg("unable to persist the session")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/feeds"
+)
+
+type sessionTestParser struct{}
+
+func (sessionTestParser) Parse(*Options) (*feeds.Feed, error) { return nil, nil }
+func (sessionTestParser) String() string                      { return "sessiontest" }
+func (sessionTestParser) GetOptions() Options                 { return Options{} }
+
+func TestSessionToken(t *testing.T) {
+	s := newSession("")
+	var fetches atomic.Int32
+	fetch := func(ctx context.Context) (string, time.Duration, error) {
+		n := fetches.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return "token" + string(rune('0'+n)), 0, nil
+	}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := s.Token(context.Background(), "t", fetch); err != nil || token != "token1" {
+				t.Errorf("unexpected token %s %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches.Load() != 1 {
+		t.Errorf("the concurrent requests should share a fetch: %d fetches", fetches.Load())
+	}
+
+	var sent []string
+	resp, err := s.DoWithToken(context.Background(), "t", fetch, func(token string) (*http.Response, error) {
+		sent = append(sent, token)
+		status := http.StatusOK
+		if token == "token1" {
+			status = http.StatusUnauthorized
+		}
+		rec := httptest.NewRecorder()
+		rec.WriteHeader(status)
+		return rec.Result(), nil
+	})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("the rejected token should be refreshed: %v %v", resp, err)
+	}
+	if len(sent) != 2 || sent[1] != "token2" {
+		t.Errorf("unexpected tokens sent %v", sent)
+	}
+}
+
+func TestSessionPersistence(t *testing.T) {
+	setConfigOption(t, "CACHE_DIR", t.TempDir())
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", MaxAge: 3600})
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer upstream.Close()
+
+	ctx := withModule(context.Background(), sessionTestParser{})
+	for _, status := range []int{http.StatusOK, http.StatusNoContent} {
+		resp, err := HttpGetWithContext(ctx, upstream.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("the session cookie should be sent back: status %d", resp.StatusCode)
+		}
+	}
+	if _, err := GetSession("sessiontest").Token(ctx, "t", func(context.Context) (string, time.Duration, error) { return "secret", time.Hour, nil }); err != nil {
+		t.Fatal(err)
+	}
+	SaveSessions(ctx)
+
+	sessionsMu.Lock()
+	delete(sessions, "sessiontest")
+	sessionsMu.Unlock()
+	restored := GetSession("sessiontest")
+	u, _ := url.Parse(upstream.URL)
+	if restored.Cookie(u, "session") != "abc" {
+		t.Errorf("the cookies should be restored")
+	}
+	if token, ok := restored.getToken("t"); !ok || token != "secret" {
+		t.Errorf("the tokens should be restored")
+	}
+}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/nbr23/rss-banquet/config"
+)
+
+// ShutdownHook is implemented by the modules with state to persist or
+// resources to release when the server stops
+type ShutdownHook interface {
+	Shutdown(ctx context.Context) error
+}
+
+// RunShutdownHooks runs the shutdown hooks of the parsers, logging their errors
+func RunShutdownHooks(ctx context.Context, parsers []Parser) {
+	for _, p := range parsers {
+		hook, ok := p.(ShutdownHook)
+		if !ok {
+			continue
+		}
+		if err := hook.Shutdown(ctx); err != nil {
+			GetLogger(ctx).Error().Err(err).Str("module", p.String()).Msg("shutdown hook failed")
+		}
+	}
+	SaveSessions(ctx)
+	SaveBaselines()
+}
+
+// GetCacheFile returns the path of a cache persisted across restarts, or ""
+// when CACHE_DIR isn't set
+func GetCacheFile(name string) string {
+	dir := config.GetConfigOption("CACHE_DIR")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The spans are recorded through the global tracer provider, which doesn't
+// record anything unless tracing is enabled
+
+const TRACER_NAME = "github.com/nbr23/rss-banquet/parser"
+
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TRACER_NAME).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records the error, if any, and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func getOptionsAttributes(o *Options) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, option := range o.OptionsList {
+		if option.IsStatic {
+			continue
+		}
+		value, _, err := o.OptionsList.Get(option.Flag)
+		if err != nil {
+			continue
+		}
+		attrs = append(attrs, attribute.String("banquet.option."+option.Flag, formatOptionValue(value)))
+	}
+	return attrs
+}
+
+func formatOptionValue(value any) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case *bool:
+		return strconv.FormatBool(v != nil && *v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// DecodeHTML parses the HTML document read from r in a span
+func DecodeHTML(ctx context.Context, r io.Reader) (*goquery.Document, error) {
+	_, span := StartSpan(ctx, "decode html")
+	doc, err := goquery.NewDocumentFromReader(r)
+	EndSpan(span, err)
+	return doc, err
+}
+
+// DecodeJSON decodes the JSON document read from r into v in a span
+func DecodeJSON(ctx context.Context, r io.Reader, v any) error {
+	_, span := StartSpan(ctx, "decode json")
+	err := json.NewDecoder(r).Decode(v)
+	EndSpan(span, err)
+	return err
+}
+
+// UnmarshalJSON decodes the JSON data into v in a span
+func UnmarshalJSON(ctx context.Context, data []byte, v any) error {
+	_, span := StartSpan(ctx, "decode json", attribute.Int("banquet.size", len(data)))
+	err := json.Unmarshal(data, v)
+	EndSpan(span, err)
+	return err
+}
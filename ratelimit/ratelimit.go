@@ -0,0 +1,235 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/metrics"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+// BUCKETS_MAX_SIZE is the number of clients tracked by a limiter before the idle ones are dropped
+const BUCKETS_MAX_SIZE = 10000
+
+const NO_LIMIT = "none"
+
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseLimit parses a <requests>/<duration> limit, returning nil for no limit
+func ParseLimit(s string) (*Limit, error) {
+	if s == "" || s == NO_LIMIT {
+		return nil, nil
+	}
+	requests, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid rate limit %s: <requests>/<duration> expected", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %s: positive number of requests expected", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %s: positive duration expected", s)
+	}
+	return &Limit{Requests: n, Window: d}, nil
+}
+
+func (l *Limit) rate() float64 {
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// stricter returns the limit allowing the fewest requests over time
+func stricter(a, b *Limit) *Limit {
+	if a == nil || (b != nil && b.rate() < a.rate()) {
+		return b
+	}
+	return a
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket per key, allowing bursts of the limit requests
+type Limiter struct {
+	limit   Limit
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(float64(l.limit.Requests), b.tokens+now.Sub(b.last).Seconds()*l.limit.rate())
+	b.last = now
+}
+
+// Allow consumes a request of the key, or returns how long to wait for the next one
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= BUCKETS_MAX_SIZE {
+			l.dropIdle(now)
+		}
+		b = &bucket{tokens: float64(l.limit.Requests), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.limit.rate() * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// dropIdle forgets the keys whose buckets are full again
+func (l *Limiter) dropIdle(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type moduleLimiters struct {
+	client *Limiter
+	module *Limiter
+}
+
+// RateLimiter limits the feed requests per client and module, and per module
+type RateLimiter struct {
+	matcher *parser.RouteMatcher
+	modules map[string]*moduleLimiters
+}
+
+func newLimiter(limit *Limit) *Limiter {
+	if limit == nil {
+		return nil
+	}
+	return NewLimiter(*limit)
+}
+
+// New configures the limits of the modules: the RATE_LIMIT and RATE_LIMIT_MODULE
+// options, overridden by the stricter defaults of the modules and by the
+// rate_limits of the config file. It returns nil when no module is limited.
+func New(modules map[string]parser.Parser, overrides map[string]config.RateLimitConfig) (*RateLimiter, error) {
+	return NewWithLimits(modules, overrides, config.GetConfigOption("RATE_LIMIT"), config.GetConfigOption("RATE_LIMIT_MODULE"))
+}
+
+// NewWithLimits is New with the given client and module limits, for configs not applied yet
+func NewWithLimits(modules map[string]parser.Parser, overrides map[string]config.RateLimitConfig, client string, module string) (*RateLimiter, error) {
+	clientLimit, err := ParseLimit(client)
+	if err != nil {
+		return nil, err
+	}
+	moduleLimit, err := ParseLimit(module)
+	if err != nil {
+		return nil, err
+	}
+	for name := range overrides {
+		if _, ok := modules[name]; !ok {
+			return nil, fmt.Errorf("rate limits of unknown module %s", name)
+		}
+	}
+
+	r := &RateLimiter{matcher: parser.NewRouteMatcher(modules), modules: make(map[string]*moduleLimiters)}
+	for name, p := range modules {
+		client, module := clientLimit, moduleLimit
+		if provider, ok := p.(parser.RateLimitProvider); ok {
+			limit, err := ParseLimit(provider.RateLimit())
+			if err != nil {
+				return nil, fmt.Errorf("module %s: %w", name, err)
+			}
+			client = stricter(client, limit)
+		}
+		if override, ok := overrides[name]; ok {
+			if override.Client != "" {
+				if client, err = ParseLimit(override.Client); err != nil {
+					return nil, fmt.Errorf("module %s: %w", name, err)
+				}
+			}
+			if override.Module != "" {
+				if module, err = ParseLimit(override.Module); err != nil {
+					return nil, fmt.Errorf("module %s: %w", name, err)
+				}
+			}
+		}
+		if client != nil || module != nil {
+			r.modules[name] = &moduleLimiters{client: newLimiter(client), module: newLimiter(module)}
+		}
+	}
+	if len(r.modules) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// getClient identifies the client by its access token, or its IP
+func getClient(c *gin.Context) string {
+	if name := c.GetString(parser.TOKEN_NAME_KEY); name != "" {
+		return "token:" + name
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func reject(c *gin.Context, module string, limit string, wait time.Duration) {
+	metrics.RateLimited(module, limit)
+	parser.GetLogger(c.Request.Context()).Warn().Str("module", module).Str("limit", limit).Str("client", getClient(c)).Msg("rate limit exceeded")
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"status": "error", "error": fmt.Sprintf("%s rate limit exceeded", limit)})
+}
+
+// Middleware rejects the feed requests and previews over the limits with a 429, the
+// feeds served from the cache being exempt
+func (r *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feedUrl := c.Request.URL
+		if u, ok := parser.PreviewFeedUrl(feedUrl); ok {
+			feedUrl = u
+		} else if !strings.HasPrefix(feedUrl.Path, "/feed/") {
+			c.Next()
+			return
+		}
+		match, err := r.matcher.Match(feedUrl.RequestURI())
+		if err != nil {
+			c.Next()
+			return
+		}
+		limiters, ok := r.modules[match.Module]
+		if !ok || parser.IsFeedCached(parser.FeedKey(feedUrl)) {
+			c.Next()
+			return
+		}
+		now := time.Now()
+		if limiters.client != nil {
+			if ok, wait := limiters.client.Allow(getClient(c), now); !ok {
+				reject(c, match.Module, "client", wait)
+				return
+			}
+		}
+		if limiters.module != nil {
+			if ok, wait := limiters.module.Allow(match.Module, now); !ok {
+				reject(c, match.Module, "module", wait)
+				return
+			}
+		}
+		c.Next()
+	}
+}
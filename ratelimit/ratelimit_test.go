@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	name  string
+	limit string
+}
+
+func (p testParser) Parse(*parser.Options) (*feeds.Feed, error) {
+	return &feeds.Feed{Title: p.name, Items: []*feeds.Item{{Title: "item", Link: &feeds.Link{Href: "https://example.com"}}}}, nil
+}
+func (p testParser) String() string    { return p.name }
+func (p testParser) RateLimit() string { return p.limit }
+func (p testParser) GetOptions() parser.Options {
+	return parser.Options{OptionsList: parser.OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestParseLimit(t *testing.T) {
+	l, err := ParseLimit("30/1h")
+	if err != nil || l.Requests != 30 || l.Window != time.Hour {
+		t.Errorf("ParseLimit(30/1h) = %v, %v", l, err)
+	}
+	for _, s := range []string{"", NO_LIMIT} {
+		if l, err := ParseLimit(s); l != nil || err != nil {
+			t.Errorf("ParseLimit(%q) = %v, %v, want no limit", s, l, err)
+		}
+	}
+	for _, s := range []string{"30", "0/1h", "a/1h", "30/0s", "30/hour"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Errorf("ParseLimit(%q) should fail", s)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(Limit{Requests: 2, Window: time.Minute})
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	ok, wait := l.Allow("a", now)
+	if ok || wait != 30*time.Second {
+		t.Errorf("Allow() = %v, %v, want false, 30s", ok, wait)
+	}
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Errorf("keys should be limited separately")
+	}
+	if ok, _ := l.Allow("a", now.Add(30*time.Second)); !ok {
+		t.Errorf("the bucket should have been refilled")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	config.SetValues(map[string]string{"BASELINES_FILE": "", "FEED_CACHE_TTL": "1m", "RATE_LIMIT": "5/1m"})
+	defer config.SetValues(map[string]string{"FEED_CACHE_TTL": "0s", "RATE_LIMIT": ""})
+
+	modules := map[string]parser.Parser{
+		"cheap":     testParser{name: "cheap"},
+		"expensive": testParser{name: "expensive", limit: "1/1h"},
+		"unlimited": testParser{name: "unlimited", limit: "1/1h"},
+	}
+	r, err := New(modules, map[string]config.RateLimitConfig{"unlimited": {Client: NO_LIMIT}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(r.Middleware())
+	for _, p := range modules {
+		parser.Route(engine, p, parser.GetFullOptions(p))
+	}
+	engine.GET(parser.PREVIEW_PATH+"/feed/*path", parser.PreviewHandler(parser.NewRouteMatcher(modules)))
+
+	get := func(path string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/feed/expensive/1", "10.0.0.1"); w.Code != 200 {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	if w := get("/feed/expensive/1", "10.0.0.1"); w.Code != 200 || w.Header().Get(parser.CACHE_HEADER) != "hit" {
+		t.Errorf("cached feeds should be exempt: status %d, cache %s", w.Code, w.Header().Get(parser.CACHE_HEADER))
+	}
+	w := get("/feed/expensive/2", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("expected a 429 with Retry-After, got %d, %s", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := get("/feed/expensive/2", "10.0.0.2"); w.Code != 200 {
+		t.Errorf("clients should be limited separately: status %d", w.Code)
+	}
+	if w := get(parser.PREVIEW_PATH+"/feed/expensive/3", "10.0.0.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("previews should be limited as the feeds: status %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := get("/feed/unlimited/"+string(rune('a'+i)), "10.0.0.1"); w.Code != 200 {
+			t.Errorf("overridden module should not be limited: status %d", w.Code)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		get("/feed/cheap/"+string(rune('a'+i)), "10.0.0.1")
+	}
+	if w := get("/feed/cheap/z", "10.0.0.1"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("RATE_LIMIT should apply to the other modules: status %d", w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nbr23/rss-banquet/access"
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/ratelimit"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// CONFIG_SNAPSHOT_KEY pins the config snapshot of a request in its context
+const CONFIG_SNAPSHOT_KEY = "configSnapshot"
+
+// middlewareKey stores the middlewares built from the config in its snapshot
+type middlewareKey string
+
+const (
+	ACCESS_MIDDLEWARE     middlewareKey = "access"
+	RATE_LIMIT_MIDDLEWARE middlewareKey = "rateLimit"
+)
+
+// loadConfig loads and validates the CONFIG_FILE, returning the config to apply
+func loadConfig() (*config.Snapshot, error) {
+	c, err := config.GetConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	values, err := config.ResolveSettings(c.Settings)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := parser.ResolveModulesConfig(getParsers(), c.Modules)
+	if err != nil {
+		return nil, err
+	}
+	return parser.WithModulesConfig(config.NewSnapshot(values, c), modules), nil
+}
+
+// withMiddlewares validates the access and rate limits of the config, before
+// it is applied, returning a copy of the config storing their middlewares
+func withMiddlewares(s *config.Snapshot) (*config.Snapshot, error) {
+	var accessHandler, rateLimitHandler gin.HandlerFunc
+	if s.File.Access != nil {
+		ctl, err := access.New(s.File.Access, parser.NewRouteMatcher(getParsers()))
+		if err != nil {
+			return nil, err
+		}
+		accessHandler = ctl.Middleware()
+	}
+	limiter, err := ratelimit.NewWithLimits(getParsers(), s.File.RateLimits, s.Values["RATE_LIMIT"], s.Values["RATE_LIMIT_MODULE"])
+	if err != nil {
+		return nil, err
+	}
+	if limiter != nil {
+		rateLimitHandler = limiter.Middleware()
+	}
+	return s.WithValue(ACCESS_MIDDLEWARE, accessHandler).WithValue(RATE_LIMIT_MIDDLEWARE, rateLimitHandler), nil
+}
+
+// configMiddleware pins the current config snapshot in the request context,
+// so that the middlewares of the request all run with the same config
+func configMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(CONFIG_SNAPSHOT_KEY, config.Current())
+		c.Next()
+	}
+}
+
+// snapshotMiddleware runs the middleware stored in the config snapshot of the request
+func snapshotMiddleware(key middlewareKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(CONFIG_SNAPSHOT_KEY)
+		s, ok := value.(*config.Snapshot)
+		if !ok {
+			s = config.Current()
+		}
+		h, _ := s.Value(key).(gin.HandlerFunc)
+		if h == nil {
+			c.Next()
+			return
+		}
+		h(c)
+	}
+}
+
+// reloadConfig applies the CONFIG_FILE if it is valid, keeping the current config otherwise
+func reloadConfig() {
+	s, err := loadConfig()
+	if err == nil {
+		s, err = withMiddlewares(s)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("config reload failed, keeping the current config")
+		return
+	}
+
+	old := config.Current().File
+	c := s.File
+	changes := config.Publish(s)
+	if level, err := zerolog.ParseLevel(config.GetConfigOption("LOG_LEVEL")); err == nil {
+		zerolog.SetGlobalLevel(level)
+	}
+	parser.ClearFeedCache()
+
+	for _, change := range changes {
+		if change.RequiresRestart() {
+			log.Warn().Msgf("config: %s, applied on restart", change)
+		} else {
+			log.Info().Msgf("config: %s", change)
+		}
+	}
+	for _, change := range config.DiffFileConfig(old, c) {
+		log.Info().Msgf("config: %s", change)
+	}
+	if !reflect.DeepEqual(old.Notifier, c.Notifier) || !reflect.DeepEqual(old.Feeds, c.Feeds) && c.Notifier != nil {
+		log.Warn().Msg("config: notifier changes applied on restart")
+	}
+	log.Info().Msg("config reloaded")
+}
+
+func hashFile(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
+
+// watchConfig reloads the config on SIGHUP, and when the CONFIG_FILE changes
+func watchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	path := config.GetConfigOption("CONFIG_FILE")
+	var tick <-chan time.Time
+	if interval := getDurationOption("CONFIG_WATCH_INTERVAL"); path != "" && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	hash := hashFile(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Msg("SIGHUP received, reloading the config")
+			hash = hashFile(path)
+			reloadConfig()
+		case <-tick:
+			if h := hashFile(path); h != hash {
+				hash = h
+				log.Info().Msgf("%s changed, reloading the config", path)
+				reloadConfig()
+			}
+		}
+	}
+}
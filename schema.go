@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/openapi"
+	"github.com/rs/zerolog/log"
+)
+
+func runSchema(args []string) {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rss-banquet schema [options]\n")
+		fmt.Fprintf(os.Stderr, "Prints the JSON Schema of the config file, for editors to validate it\n")
+		flags.PrintDefaults()
+	}
+	withOpenAPI := flags.Bool("openapi", false, "print the OpenAPI document of the feeds instead")
+	baseUrl := flags.String("base-url", config.GetConfigOption("BASE_URL"), "server url of the OpenAPI document")
+	flags.Parse(args)
+
+	var doc any = openapi.ConfigSchema(getParsers())
+	if *withOpenAPI {
+		doc = openapi.NewDocument(getParsers(), *baseUrl)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+}
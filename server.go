@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/rs/zerolog/log"
+)
+
+func getDurationOption(name string) time.Duration {
+	d, err := time.ParseDuration(config.GetConfigOption(name))
+	if err != nil {
+		log.Fatal().Msgf("invalid %s: %s", name, err)
+	}
+	return d
+}
+
+func newHttpServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: getDurationOption("READ_HEADER_TIMEOUT"),
+		ReadTimeout:       getDurationOption("READ_TIMEOUT"),
+		WriteTimeout:      getDurationOption("WRITE_TIMEOUT"),
+		IdleTimeout:       getDurationOption("IDLE_TIMEOUT"),
+	}
+}
+
+func listen(port string) (net.Listener, error) {
+	socket := config.GetConfigOption("UNIX_SOCKET")
+	if socket == "" {
+		return net.Listen("tcp", fmt.Sprintf(":%s", port))
+	}
+	// left over by an unclean stop
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return net.Listen("unix", socket)
+}
+
+// serve runs the server until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to SHUTDOWN_TIMEOUT for the in-flight requests
+func serve(srv *http.Server, port string) error {
+	shutdownTimeout := getDurationOption("SHUTDOWN_TIMEOUT")
+	l, err := listen(port)
+	if err != nil {
+		return err
+	}
+	certFile, keyFile := config.GetConfigOption("TLS_CERT_FILE"), config.GetConfigOption("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Info().Msgf("listening on %s", l.Addr())
+		if certFile != "" {
+			errs <- srv.ServeTLS(l, certFile, keyFile)
+		} else {
+			errs <- srv.Serve(l)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Info().Msgf("shutting down, waiting up to %s for the in-flight requests", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("unable to drain the in-flight requests: %w", err)
+	}
+	return nil
+}
+
+// runShutdownHooks lets the modules persist their state before exiting
+func runShutdownHooks() {
+	var parsers []parser.Parser
+	for _, p := range getParsers() {
+		parsers = append(parsers, p)
+	}
+	parser.RunShutdownHooks(context.Background(), parsers)
+}
@@ -0,0 +1,295 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+const STREAM_PATH = "/stream"
+
+const HEARTBEAT_INTERVAL = 30 * time.Second
+
+// BUFFER_SIZE is the number of events kept per feed for Last-Event-ID resumes
+const BUFFER_SIZE = 100
+
+// SEEN_GUIDS_MAX bounds the number of guids remembered per feed
+const SEEN_GUIDS_MAX = 1000
+
+const CLIENT_BUFFER_SIZE = 16
+
+// MAX_TOPICS bounds the number of feeds streamed at once
+const MAX_TOPICS = 100
+
+var errTooManyTopics = errors.New("too many feeds streamed")
+
+type Event struct {
+	Id   int64  `json:"id"`
+	Data string `json:"data"`
+}
+
+type topicState struct {
+	LastId int64    `json:"last_id"`
+	Seen   []string `json:"seen"`
+	Polled bool     `json:"polled"`
+	Events []Event  `json:"events"`
+}
+
+type topic struct {
+	match   *parser.RouteMatch
+	clients map[chan Event]struct{}
+	cancel  context.CancelFunc
+}
+
+type Streamer struct {
+	matcher   *parser.RouteMatcher
+	interval  time.Duration
+	stateFile string
+
+	mu     sync.Mutex
+	state  map[string]*topicState
+	topics map[string]*topic
+}
+
+func NewStreamer(matcher *parser.RouteMatcher, interval time.Duration, stateFile string) (*Streamer, error) {
+	s := &Streamer{
+		matcher:   matcher,
+		interval:  interval,
+		stateFile: stateFile,
+		state:     make(map[string]*topicState),
+		topics:    make(map[string]*topic),
+	}
+	if err := utils.ReadJSONFile(stateFile, &s.state); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Streamer) RegisterRoutes(r *gin.Engine) {
+	r.GET(STREAM_PATH+"/feed/*path", s.handleStream)
+}
+
+func getLastEventId(c *gin.Context) int64 {
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		// EventSource can't set headers on the first connection
+		lastEventId = c.Query("lastEventId")
+	}
+	id, err := strconv.ParseInt(lastEventId, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+func (s *Streamer) handleStream(c *gin.Context) {
+	query := c.Request.URL.Query()
+	query.Del("lastEventId")
+	feedUrl := &url.URL{Path: "/feed" + c.Param("path"), RawQuery: query.Encode()}
+	match, err := s.matcher.Match(feedUrl.String())
+	if err != nil {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+	// the feeds are keyed by their query, which mustn't grow with made up parameters
+	if len(match.Unknown) > 0 {
+		c.String(http.StatusBadRequest, fmt.Sprintf("unknown options for module %s: %s", match.Module, strings.Join(match.Unknown, ", ")))
+		return
+	}
+
+	key := parser.FeedKey(feedUrl)
+	events, backlog, err := s.subscribe(key, match, getLastEventId(c))
+	if err != nil {
+		c.String(http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer s.unsubscribe(key, events)
+
+	// streams outlive the server WRITE_TIMEOUT
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	for _, e := range backlog {
+		writeEvent(c, e)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(c, e)
+		case <-heartbeat.C:
+			c.Writer.WriteString(": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func writeEvent(c *gin.Context, e Event) {
+	c.Render(-1, sse.Event{Id: strconv.FormatInt(e.Id, 10), Event: "item", Data: e.Data})
+}
+
+// subscribe registers a client, returning the buffered events following lastEventId
+func (s *Streamer) subscribe(key string, match *parser.RouteMatch, lastEventId int64) (chan Event, []Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.topics[key]
+	if !ok && len(s.topics) >= MAX_TOPICS {
+		return nil, nil, errTooManyTopics
+	}
+
+	var backlog []Event
+	if state, ok := s.state[key]; ok && lastEventId >= 0 {
+		for _, e := range state.Events {
+			if e.Id > lastEventId {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	events := make(chan Event, CLIENT_BUFFER_SIZE)
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		t = &topic{match: match, clients: make(map[chan Event]struct{}), cancel: cancel}
+		s.topics[key] = t
+		go s.poll(ctx, key, t)
+	}
+	t.clients[events] = struct{}{}
+	return events, backlog, nil
+}
+
+func (s *Streamer) unsubscribe(key string, events chan Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.topics[key]
+	if !ok {
+		return
+	}
+	if _, ok := t.clients[events]; ok {
+		delete(t.clients, events)
+		close(events)
+	}
+	// feeds are only parsed and remembered while being streamed
+	if len(t.clients) == 0 {
+		t.cancel()
+		delete(s.topics, key)
+		delete(s.state, key)
+		s.save()
+	}
+}
+
+// Close disconnects the clients, which resume with their last event id once
+// the server is back
+func (s *Streamer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.topics {
+		for client := range t.clients {
+			delete(t.clients, client)
+			close(client)
+		}
+		t.cancel()
+		delete(s.topics, key)
+	}
+}
+
+func (s *Streamer) poll(ctx context.Context, key string, t *topic) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.pollTopic(ctx, key, t); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("stream: %s: %s", key, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Streamer) pollTopic(ctx context.Context, key string, t *topic) error {
+	feed, o, err := t.match.ParseWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the last client left during the parse
+	if ctx.Err() != nil {
+		return nil
+	}
+	state, ok := s.state[key]
+	if !ok {
+		state = &topicState{}
+		s.state[key] = state
+	}
+	newItems, seen := parser.GetNewItems(feed, state.Seen, SEEN_GUIDS_MAX)
+	state.Seen = seen
+
+	// the first poll of a feed only records its current items
+	if !state.Polled {
+		state.Polled = true
+		s.save()
+		return nil
+	}
+	if len(newItems) == 0 {
+		return nil
+	}
+
+	feed.Items = newItems
+	items, err := parser.ToJSONItems(feed, o)
+	if err != nil {
+		return err
+	}
+	for _, data := range items {
+		state.LastId++
+		e := Event{Id: state.LastId, Data: data}
+		state.Events = append(state.Events, e)
+		for client := range t.clients {
+			select {
+			case client <- e:
+			default:
+				// slow clients are disconnected, and resume with their last event id
+				delete(t.clients, client)
+				close(client)
+			}
+		}
+	}
+	if len(state.Events) > BUFFER_SIZE {
+		state.Events = state.Events[len(state.Events)-BUFFER_SIZE:]
+	}
+	s.save()
+	return nil
+}
+
+// save must be called with the lock held
+func (s *Streamer) save() {
+	if err := utils.WriteJSONFile(s.stateFile, s.state); err != nil {
+		log.Error().Msgf("stream: unable to save state %s: %s", s.stateFile, err)
+	}
+}
@@ -0,0 +1,195 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	items []*feeds.Item
+}
+
+func (p *testParser) Parse(*parser.Options) (*feeds.Feed, error) {
+	return &feeds.Feed{Title: "test", Items: p.items}, nil
+}
+func (p *testParser) String() string { return "test" }
+func (p *testParser) GetOptions() parser.Options {
+	return parser.Options{Parser: p}
+}
+
+func newItem(id string) *feeds.Item {
+	return &feeds.Item{Id: id, Title: "title " + id, Link: &feeds.Link{Href: "https://example.com/" + id}, Created: time.Now()}
+}
+
+func waitFor(t *testing.T, s *Streamer, condition func() bool) {
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		ok := condition()
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+// readEvent returns the id and data lines of the next event, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	var id, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != "":
+			return id, data
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimPrefix(line, "id:")
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func TestStreamer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &testParser{items: []*feeds.Item{newItem("1")}}
+	s, err := NewStreamer(parser.NewRouteMatcher(map[string]parser.Parser{"test": p}), time.Hour, filepath.Join(t.TempDir(), "stream.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	s.RegisterRoutes(r)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream/feed/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream;charset=utf-8" {
+		t.Errorf("unexpected content type %s", resp.Header.Get("Content-Type"))
+	}
+	key := "/feed/test"
+	waitFor(t, s, func() bool { return s.state[key] != nil && s.state[key].Polled })
+
+	p.items = append([]*feeds.Item{newItem("2")}, p.items...)
+	s.mu.Lock()
+	topic := s.topics[key]
+	s.mu.Unlock()
+	if err := s.pollTopic(context.Background(), key, topic); err != nil {
+		t.Fatal(err)
+	}
+	id, data := readEvent(t, bufio.NewReader(resp.Body))
+	if id != "1" || !strings.Contains(data, `"id":"2"`) {
+		t.Errorf("unexpected event %s: %s", id, data)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/stream/feed/test?feedFormat=json", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := readEvent(t, bufio.NewReader(resumed.Body)); id != "1" {
+		t.Errorf("resuming should replay the buffered events, got %s", id)
+	}
+	resp.Body.Close()
+	resumed.Body.Close()
+	waitFor(t, s, func() bool { return len(s.topics) == 0 })
+	if len(s.state) != 0 {
+		t.Errorf("the state of a feed should be dropped with its last client")
+	}
+
+	for path, status := range map[string]int{
+		"/stream/feed/missing":        http.StatusNotFound,
+		"/stream/feed/test?unknown=1": http.StatusBadRequest,
+	} {
+		resp, err = http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: unexpected status %d, want %d", path, resp.StatusCode, status)
+		}
+	}
+}
+
+func TestStreamerMaxTopics(t *testing.T) {
+	s, err := NewStreamer(nil, time.Hour, filepath.Join(t.TempDir(), "stream.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	match := &parser.RouteMatch{Module: "test", Parser: &testParser{}}
+	for i := 0; i < MAX_TOPICS; i++ {
+		if _, _, err := s.subscribe(fmt.Sprintf("/feed/test/%d", i), match, -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := s.subscribe("/feed/test/new", match, -1); err != errTooManyTopics {
+		t.Errorf("subscribe() = %v, want %v", err, errTooManyTopics)
+	}
+	if _, _, err := s.subscribe("/feed/test/0", match, -1); err != nil {
+		t.Errorf("the feeds already streamed should accept more clients: %s", err)
+	}
+}
+
+func TestStreamerClose(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &testParser{items: []*feeds.Item{newItem("1")}}
+	s, err := NewStreamer(parser.NewRouteMatcher(map[string]parser.Parser{"test": p}), time.Hour, filepath.Join(t.TempDir(), "stream.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	s.RegisterRoutes(r)
+	server := httptest.NewUnstartedServer(r)
+	// streams aren't cut by the server write timeout
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stream/feed/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	waitFor(t, s, func() bool { return len(s.topics) == 1 })
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		done <- err
+	}()
+	s.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("the stream should end cleanly: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() should end the streams")
+	}
+	if len(s.topics) != 0 {
+		t.Errorf("the topics should stop being polled")
+	}
+}
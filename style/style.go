@@ -12,6 +12,7 @@ func InjectAtomStyle(x string) string {
 
 var RssStyle = `<xsl:stylesheet
                 xmlns:xsl="http://www.w3.org/1999/XSL/Transform"
+                xmlns:media="http://search.yahoo.com/mrss/"
                 xmlns:fo="http://www.w3.org/1999/XSL/Format"
                 version="1.0">
   <xsl:output method="html"/>
@@ -130,13 +131,22 @@ body {
           <div class="item-list">
             <xsl:for-each select="/rss/channel/item">
             <div class="item">
-            <xsl:if test="starts-with(enclosure/@type, 'image') and enclosure/@url != ''">
+            <xsl:choose>
+              <xsl:when test="media:thumbnail/@url != ''">
+                <img class="thumbnail">
+                  <xsl:attribute name="src">
+                    <xsl:value-of select="media:thumbnail/@url"/>
+                  </xsl:attribute>
+                </img>
+              </xsl:when>
+              <xsl:when test="starts-with(enclosure/@type, 'image') and enclosure/@url != ''">
                 <img class="thumbnail">
                   <xsl:attribute name="src">
                     <xsl:value-of select="enclosure/@url"/>
                   </xsl:attribute>
                 </img>
-              </xsl:if>
+              </xsl:when>
+            </xsl:choose>
               <a class="details" target="_blank" rel="noopener noreferrer">
                 <xsl:attribute name="href">
                   <xsl:value-of select="link"/>
@@ -159,6 +169,7 @@ body {
 var AtomStyle = `<xsl:stylesheet
 								xmlns:atom="http://www.w3.org/2005/Atom"
                 xmlns:xsl="http://www.w3.org/1999/XSL/Transform"
+                xmlns:media="http://search.yahoo.com/mrss/"
                 xmlns:fo="http://www.w3.org/1999/XSL/Format"
                 version="1.0">
   <xsl:output method="html"/>
@@ -277,13 +288,22 @@ body {
           <div class="item-list">
             <xsl:for-each select="atom:entry">
             <div class="item">
-            <xsl:if test="starts-with(atom:link[@rel='enclosure']/@type, 'image') and atom:link[@rel='enclosure']/@href != ''">
+            <xsl:choose>
+              <xsl:when test="media:thumbnail/@url != ''">
+                <img class="thumbnail">
+                  <xsl:attribute name="src">
+                    <xsl:value-of select="media:thumbnail/@url"/>
+                  </xsl:attribute>
+                </img>
+              </xsl:when>
+              <xsl:when test="starts-with(atom:link[@rel='enclosure']/@type, 'image') and atom:link[@rel='enclosure']/@href != ''">
                 <img class="thumbnail">
                   <xsl:attribute name="src">
                     <xsl:value-of select="atom:link[@rel='enclosure']/@href"/>
                   </xsl:attribute>
                 </img>
-              </xsl:if>
+              </xsl:when>
+            </xsl:choose>
               <a class="details" target="_blank" rel="noopener noreferrer">
                 <xsl:attribute name="href">
                   <xsl:value-of select="atom:link/@href"/>
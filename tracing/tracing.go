@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+const TRACER_NAME = "github.com/nbr23/rss-banquet"
+
+const SERVICE_NAME = "rss-banquet"
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "otlp":
+		// configured through the standard OTEL_EXPORTER_OTLP_* environment variables
+		return otlptracehttp.New(ctx)
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %s", name)
+	}
+}
+
+// Init sets up the global tracer provider with the TRACING_EXPORTER, returning
+// the function flushing the pending spans. Tracing is disabled without exporter.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	name := config.GetConfigOption("TRACING_EXPORTER")
+	if name == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newExporter(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	ratio, err := strconv.ParseFloat(config.GetConfigOption("TRACING_SAMPLE_RATIO"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: %w", err)
+	}
+
+	spanProcessor := sdktrace.WithBatcher(exporter)
+	if name == "stdout" {
+		spanProcessor = sdktrace.WithSyncer(exporter)
+	}
+	provider := sdktrace.NewTracerProvider(
+		spanProcessor,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(SERVICE_NAME))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a span per request, continuing the trace of the
+// traceparent header, and adds the trace id to the request logs
+func Middleware() gin.HandlerFunc {
+	tracer := otel.Tracer(TRACER_NAME)
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("url.query", parser.RedactUrl(c.Request.URL).RawQuery),
+				attribute.String("banquet.request_id", parser.GetRequestId(ctx)),
+			),
+		)
+		defer span.End()
+		if span.SpanContext().IsValid() {
+			ctx = parser.WithLogFields(ctx, map[string]string{"trace_id": span.SpanContext().TraceID().String()})
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
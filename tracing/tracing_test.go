@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	upstream string
+}
+
+func (p testParser) Parse(o *parser.Options) (*feeds.Feed, error) {
+	resp, err := parser.HttpGetWithContext(o.Context(), p.upstream, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := parser.DecodeHTML(o.Context(), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &feeds.Feed{Title: doc.Find("title").Text(), Link: &feeds.Link{Href: p.upstream}}, nil
+}
+func (testParser) String() string { return "tracingtest" }
+func (p testParser) GetOptions() parser.Options {
+	return parser.Options{OptionsList: parser.OptionsList{{Flag: "id", Type: "string", Required: true}}, Parser: p}
+}
+
+func TestMiddleware(t *testing.T) {
+	config.SetValues(map[string]string{"BASELINES_FILE": ""})
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+	otel.SetTracerProvider(provider)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><head><title>upstream</title></head></html>"))
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	p := testParser{upstream: upstream.URL}
+	parser.Route(r, p, parser.GetFullOptions(p))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feed/tracingtest/42", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, s := range exporter.GetSpans() {
+		spans[s.Name] = s
+	}
+	request, ok := spans["GET /feed/tracingtest/:id"]
+	if !ok {
+		t.Fatalf("missing request span in %v", spans)
+	}
+	parse := spans["parse tracingtest"]
+	if parse.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("parse span isn't a child of the request span")
+	}
+	for _, name := range []string{"GET " + upstream.Listener.Addr().String(), "decode html"} {
+		if s, ok := spans[name]; !ok || s.Parent.SpanID() != parse.SpanContext.SpanID() {
+			t.Errorf("span %s missing or not a child of the parse span", name)
+		}
+	}
+	var id string
+	for _, attr := range parse.Attributes {
+		if attr.Key == "banquet.option.id" {
+			id = attr.Value.AsString()
+		}
+	}
+	if id != "42" {
+		t.Errorf("parse span option id = %q, want 42", id)
+	}
+}
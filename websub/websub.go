@@ -0,0 +1,380 @@
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/nbr23/rss-banquet/parser"
+	"github.com/nbr23/rss-banquet/utils"
+)
+
+const HUB_PATH = "/websub/hub"
+
+const DEFAULT_LEASE = 10 * 24 * time.Hour
+const MIN_LEASE = time.Hour
+const MAX_LEASE = 30 * 24 * time.Hour
+
+const DELIVERY_ATTEMPTS = 3
+
+// SEEN_GUIDS_MAX bounds the number of guids remembered per topic
+const SEEN_GUIDS_MAX = 1000
+
+type Subscription struct {
+	Callback  string    `json:"callback"`
+	Secret    string    `json:"secret,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type topicState struct {
+	Subscriptions []*Subscription `json:"subscriptions"`
+	Seen          []string        `json:"seen"`
+	// Polled tells whether Seen holds the items of the topic
+	Polled bool `json:"polled"`
+}
+
+type Hub struct {
+	matcher    *parser.RouteMatcher
+	client     *http.Client
+	interval   time.Duration
+	stateFile  string
+	retryDelay time.Duration
+
+	mu     sync.Mutex
+	topics map[string]*topicState
+	// pending verifications
+	wg sync.WaitGroup
+}
+
+func NewHub(matcher *parser.RouteMatcher, interval time.Duration, stateFile string) (*Hub, error) {
+	h := &Hub{
+		matcher:    matcher,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		interval:   interval,
+		stateFile:  stateFile,
+		retryDelay: 5 * time.Second,
+		topics:     make(map[string]*topicState),
+	}
+	if err := utils.ReadJSONFile(stateFile, &h.topics); err != nil {
+		return nil, fmt.Errorf("unable to read websub state %s: %w", stateFile, err)
+	}
+	return h, nil
+}
+
+// RegisterRoutes adds the hub endpoint and the hub/self links to the served feeds
+func (h *Hub) RegisterRoutes(r *gin.Engine) {
+	r.Use(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/feed/") {
+			baseUrl := parser.GetBaseUrl(c)
+			parser.AddContextFeedLink(c, "hub", baseUrl+HUB_PATH)
+			parser.AddContextFeedLink(c, "self", baseUrl+parser.RedactUrl(c.Request.URL).RequestURI())
+		}
+		c.Next()
+	})
+	r.POST(HUB_PATH, h.handleRequest)
+}
+
+func getLease(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return DEFAULT_LEASE
+	}
+	lease := time.Duration(seconds) * time.Second
+	return min(max(lease, MIN_LEASE), MAX_LEASE)
+}
+
+func (h *Hub) handleRequest(c *gin.Context) {
+	mode := c.PostForm("hub.mode")
+	topic := c.PostForm("hub.topic")
+	callback := c.PostForm("hub.callback")
+
+	if mode != "subscribe" && mode != "unsubscribe" {
+		c.String(400, "hub.mode must be subscribe or unsubscribe")
+		return
+	}
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.String(400, "invalid hub.callback")
+		return
+	}
+	t, err := url.Parse(topic)
+	if err != nil || !t.IsAbs() {
+		c.String(400, "invalid hub.topic: absolute url expected")
+		return
+	}
+	// the subscriptions are stored, logged and pushed without the access token of the topic
+	requested, topic := topic, parser.RedactUrl(t).String()
+	if _, err := h.matcher.Match(topic); err != nil {
+		c.String(400, fmt.Sprintf("invalid hub.topic: %s", err))
+		return
+	}
+	secret := c.PostForm("hub.secret")
+	if len(secret) >= 200 {
+		c.String(400, "hub.secret must be less than 200 bytes")
+		return
+	}
+	lease := getLease(c.PostForm("hub.lease_seconds"))
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.verify(mode, requested, callback, lease); err != nil {
+			log.Warn().Msgf("websub: %s of %s to %s not verified: %s", mode, callback, topic, err)
+			return
+		}
+		if mode == "subscribe" {
+			h.subscribe(topic, &Subscription{Callback: callback, Secret: secret, ExpiresAt: time.Now().Add(lease)})
+		} else {
+			h.unsubscribe(topic, callback)
+		}
+		log.Info().Msgf("websub: %s of %s to %s verified", mode, callback, topic)
+	}()
+	c.Status(http.StatusAccepted)
+}
+
+// verify checks the intent of the subscriber, which must echo the challenge
+func (h *Hub) verify(mode string, topic string, callback string, lease time.Duration) error {
+	challenge := make([]byte, 16)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	u, err := url.Parse(callback)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	query.Set("hub.mode", mode)
+	query.Set("hub.topic", topic)
+	query.Set("hub.challenge", hex.EncodeToString(challenge))
+	if mode == "subscribe" {
+		query.Set("hub.lease_seconds", strconv.Itoa(int(lease.Seconds())))
+	}
+	u.RawQuery = query.Encode()
+
+	resp, err := h.client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	if strings.TrimSpace(string(body)) != hex.EncodeToString(challenge) {
+		return fmt.Errorf("callback didn't echo the challenge")
+	}
+	return nil
+}
+
+func (h *Hub) subscribe(topic string, s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	state, ok := h.topics[topic]
+	if !ok {
+		state = &topicState{}
+		h.topics[topic] = state
+	}
+	// subscribing again renews the lease
+	state.Subscriptions = slices.DeleteFunc(state.Subscriptions, func(sub *Subscription) bool {
+		return sub.Callback == s.Callback
+	})
+	state.Subscriptions = append(state.Subscriptions, s)
+	h.save()
+}
+
+func (h *Hub) unsubscribe(topic string, callback string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeSubscription(topic, callback)
+	h.save()
+}
+
+func (h *Hub) removeSubscription(topic string, callback string) {
+	state, ok := h.topics[topic]
+	if !ok {
+		return
+	}
+	state.Subscriptions = slices.DeleteFunc(state.Subscriptions, func(sub *Subscription) bool {
+		return sub.Callback == callback
+	})
+	if len(state.Subscriptions) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// save must be called with the lock held
+func (h *Hub) save() {
+	if err := utils.WriteJSONFile(h.stateFile, h.topics); err != nil {
+		log.Error().Msgf("websub: unable to save state %s: %s", h.stateFile, err)
+	}
+}
+
+// Run polls the subscribed topics every interval until the context is done,
+// then waits for the pending verifications to save their subscriptions
+func (h *Hub) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			h.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll expires the subscriptions past their lease, then parses every
+// subscribed topic and pushes its new items to the subscribers
+func (h *Hub) Poll(ctx context.Context) {
+	h.mu.Lock()
+	now := time.Now()
+	topics := make([]string, 0, len(h.topics))
+	for topic, state := range h.topics {
+		state.Subscriptions = slices.DeleteFunc(state.Subscriptions, func(sub *Subscription) bool {
+			if sub.ExpiresAt.Before(now) {
+				log.Info().Msgf("websub: subscription of %s to %s expired", sub.Callback, topic)
+				return true
+			}
+			return false
+		})
+		if len(state.Subscriptions) == 0 {
+			delete(h.topics, topic)
+			continue
+		}
+		topics = append(topics, topic)
+	}
+	h.mu.Unlock()
+
+	for _, topic := range topics {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := h.pollTopic(ctx, topic); err != nil {
+			log.Error().Msgf("websub: topic %s: %s", topic, err)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.save()
+}
+
+func (h *Hub) pollTopic(ctx context.Context, topic string) error {
+	match, err := h.matcher.Match(topic)
+	if err != nil {
+		return err
+	}
+	feed, o, err := match.ParseWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	state, ok := h.topics[topic]
+	if !ok {
+		h.mu.Unlock()
+		return nil
+	}
+	seen := state.Seen
+	polled := state.Polled
+	subscriptions := slices.Clone(state.Subscriptions)
+	h.mu.Unlock()
+
+	newItems, seen := parser.GetNewItems(feed, seen, SEEN_GUIDS_MAX)
+
+	h.mu.Lock()
+	if state, ok := h.topics[topic]; ok {
+		state.Seen = seen
+		state.Polled = true
+	}
+	h.mu.Unlock()
+
+	// the first poll of a topic only records its current items
+	if !polled || len(newItems) == 0 {
+		return nil
+	}
+
+	// the hub is served next to the topic
+	u, err := url.Parse(topic)
+	if err != nil {
+		return err
+	}
+	hubUrl := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, HUB_PATH)
+	o.AddFeedLink("hub", hubUrl)
+	o.AddFeedLink("self", topic)
+	feed.Items = newItems
+	parser.SortFeedEntries(feed)
+	contentType, body, err := parser.RenderFeed(feed, o, match.Format())
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("websub: topic %s: pushing %d new item(s) to %d subscriber(s)", topic, len(newItems), len(subscriptions))
+	for _, sub := range subscriptions {
+		h.deliver(ctx, topic, hubUrl, sub, contentType, []byte(body))
+	}
+	return nil
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
+
+func (h *Hub) deliver(ctx context.Context, topic string, hubUrl string, sub *Subscription, contentType string, body []byte) {
+	delay := h.retryDelay
+	for attempt := 1; attempt <= DELIVERY_ATTEMPTS; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Callback, bytes.NewReader(body))
+		if err != nil {
+			log.Error().Msgf("websub: invalid callback %s: %s", sub.Callback, err)
+			return
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Link", fmt.Sprintf(`<%s>; rel="hub", <%s>; rel="self"`, hubUrl, topic))
+		if sub.Secret != "" {
+			req.Header.Set("X-Hub-Signature", sign(sub.Secret, body))
+		}
+		resp, err := h.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			switch {
+			case resp.StatusCode >= 200 && resp.StatusCode < 300:
+				return
+			case resp.StatusCode == http.StatusGone:
+				log.Info().Msgf("websub: %s is gone, removing its subscription to %s", sub.Callback, topic)
+				h.unsubscribe(topic, sub.Callback)
+				return
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		log.Warn().Msgf("websub: delivery to %s failed (attempt %d): %s", sub.Callback, attempt, err)
+		if attempt < DELIVERY_ATTEMPTS {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+	}
+}
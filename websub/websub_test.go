@@ -0,0 +1,163 @@
+package websub
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/feeds"
+
+	"github.com/nbr23/rss-banquet/config"
+	"github.com/nbr23/rss-banquet/parser"
+)
+
+type testParser struct {
+	items []*feeds.Item
+}
+
+func (p *testParser) Parse(*parser.Options) (*feeds.Feed, error) {
+	return &feeds.Feed{Title: "test", Link: &feeds.Link{Href: "https://example.com"}, Items: p.items}, nil
+}
+func (p *testParser) String() string { return "test" }
+func (p *testParser) GetOptions() parser.Options {
+	return parser.Options{Parser: p}
+}
+
+func newItem(id string) *feeds.Item {
+	return &feeds.Item{Id: id, Title: "title " + id, Link: &feeds.Link{Href: "https://example.com/" + id}, Created: time.Now()}
+}
+
+type subscriber struct {
+	mu     sync.Mutex
+	pushes []*http.Request
+	bodies []string
+}
+
+func (s *subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Write([]byte(r.URL.Query().Get("hub.challenge")))
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushes = append(s.pushes, r)
+	s.bodies = append(s.bodies, string(body))
+}
+
+func TestHub(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.SetValues(map[string]string{"BASELINES_FILE": ""})
+	p := &testParser{items: []*feeds.Item{newItem("1")}}
+	stateFile := filepath.Join(t.TempDir(), "websub.json")
+	hub, err := NewHub(parser.NewRouteMatcher(map[string]parser.Parser{"test": p}), time.Hour, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	hub.RegisterRoutes(r)
+	parser.Route(r, p, parser.GetFullOptions(p))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	sub := &subscriber{}
+	subServer := httptest.NewServer(sub)
+	defer subServer.Close()
+
+	resp, err := http.Get(server.URL + "/feed/test?feedFormat=atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, link := range []string{
+		`<link href="` + server.URL + `/websub/hub" rel="hub"></link>`,
+		`<link href="` + server.URL + `/feed/test?feedFormat=atom" rel="self"></link>`,
+	} {
+		if !strings.Contains(string(body), link) {
+			t.Errorf("feed is missing %s:\n%s", link, body)
+		}
+	}
+
+	topic := server.URL + "/feed/test?feedFormat=json"
+	resp, err = http.PostForm(server.URL+HUB_PATH, url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {topic + "&token=feedtoken"},
+		"hub.callback": {subServer.URL + "/callback"},
+		"hub.secret":   {"secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	// stopping the hub waits for the pending verification
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	hub.Run(ctx)
+
+	resp, _ = http.PostForm(server.URL+HUB_PATH, url.Values{
+		"hub.mode":     {"subscribe"},
+		"hub.topic":    {server.URL + "/feed/missing"},
+		"hub.callback": {subServer.URL + "/callback"},
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("subscribing to an unknown feed should fail, got %d", resp.StatusCode)
+	}
+
+	// subscriptions are persisted, without the access token of the topic
+	state, err := os.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(state), "feedtoken") || hub.topics[topic] == nil {
+		t.Errorf("the topic should be stored without its token:\n%s", state)
+	}
+	hub, err = NewHub(hub.matcher, time.Hour, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Poll(context.Background())
+	if len(sub.pushes) != 0 {
+		t.Fatalf("the first poll should not push, got %d", len(sub.pushes))
+	}
+
+	p.items = append([]*feeds.Item{newItem("2")}, p.items...)
+	hub.Poll(context.Background())
+	if len(sub.pushes) != 1 {
+		t.Fatalf("expected 1 push, got %d", len(sub.pushes))
+	}
+	push := sub.pushes[0]
+	if push.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected content type %s", push.Header.Get("Content-Type"))
+	}
+	if got, want := push.Header.Get("X-Hub-Signature"), sign("secret", []byte(sub.bodies[0])); got != want {
+		t.Errorf("X-Hub-Signature = %s, want %s", got, want)
+	}
+	if !strings.Contains(sub.bodies[0], `"title": "title 2"`) || strings.Contains(sub.bodies[0], `"title": "title 1"`) {
+		t.Errorf("the push should only hold the new item:\n%s", sub.bodies[0])
+	}
+	if !strings.Contains(sub.bodies[0], `"feed_url": "`+topic+`"`) {
+		t.Errorf("the push should hold the topic url:\n%s", sub.bodies[0])
+	}
+
+	hub.mu.Lock()
+	hub.topics[topic].Subscriptions[0].ExpiresAt = time.Now().Add(-time.Minute)
+	hub.mu.Unlock()
+	hub.Poll(context.Background())
+	if len(hub.topics) != 0 {
+		t.Errorf("expired subscriptions should be removed")
+	}
+}